@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/zsystm/solizard/internal/history"
+	"github.com/zsystm/solizard/internal/log"
+	"github.com/zsystm/solizard/internal/prompt"
+	"github.com/zsystm/solizard/internal/safe"
+	"github.com/zsystm/solizard/internal/step"
+)
+
+// batchEntry is the json output of a transaction of the Safe batch
+type batchEntry struct {
+	Index    int    `json:"index"`
+	To       string `json:"to"`
+	Value    string `json:"value"`
+	Method   string `json:"method,omitempty"`
+	Calldata string `json:"calldata,omitempty"`
+}
+
+// addToBatch adds the call of the write method to the Safe batch, the batch only holds calls of one chain
+func (s *session) addToBatch(call history.Call, method abi.Method, input []byte, value *big.Int) (step.Step, error) {
+	sctx := s.sctx
+	chainId := sctx.ChainId().Uint64()
+	if s.batch != nil && s.batch.ChainId != fmt.Sprintf("%d", chainId) {
+		if len(s.batch.Transactions) > 0 {
+			log.Error(fmt.Sprintf("the Safe batch holds calls on chain id %s, export or clear it before adding calls on chain id %d\n", s.batch.ChainId, chainId))
+			return step.StepSelectStep, nil
+		}
+		s.batch = nil
+	}
+	if s.batch == nil {
+		s.batch = safe.NewBatch(chainId)
+	}
+	if err := s.batch.Add(*sctx.ContractAddress(), value, method, input); err != nil {
+		log.Error(fmt.Sprintf("failed to add %s to the Safe batch (reason: %v)\n", method.Name, err))
+		return step.StepSelectMethod, nil
+	}
+	s.remember(call)
+	log.Info(fmt.Sprintf("added %s of %s (%s) to the Safe batch, it holds %d transactions\n", method.Sig, s.contractName, sctx.ContractAddress().Hex(), len(s.batch.Transactions)))
+	return step.StepSelectStep, nil
+}
+
+// safeBatch lists, exports or clears the calls of the Safe batch
+func (s *session) safeBatch() (step.Step, error) {
+	if s.batch == nil {
+		s.batch = safe.NewBatch(s.sctx.ChainId().Uint64())
+	}
+	for {
+		switch prompt.MustSelectBatchAction(s.batch.ChainId, len(s.batch.Transactions)) {
+		case prompt.BatchActionList:
+			listBatch(s.batch)
+		case prompt.BatchActionExport:
+			if len(s.batch.Transactions) == 0 {
+				log.Error("the Safe batch is empty, add calls with the \"Add to Safe batch\" method type first\n")
+				continue
+			}
+			if safeAddress := prompt.MustInputSafeAddress(); safeAddress != "" {
+				s.batch.Meta.CreatedFromSafeAddress = common.HexToAddress(safeAddress).Hex()
+			}
+			path := prompt.MustInputFilePath("Enter the file to export the batch to (e.g. batch.json)")
+			if err := s.batch.Write(path); err != nil {
+				log.Error(fmt.Sprintf("failed to export the Safe batch (reason: %v)\n", err))
+				continue
+			}
+			log.Info(fmt.Sprintf("exported %d transactions to %s, import it in the Transaction Builder of the Safe\n", len(s.batch.Transactions), path))
+		case prompt.BatchActionClear:
+			s.batch = safe.NewBatch(s.sctx.ChainId().Uint64())
+			log.Info("cleared the Safe batch\n")
+		case prompt.BatchActionBack:
+			return step.StepSelectStep, nil
+		}
+	}
+}
+
+func listBatch(batch *safe.Batch) {
+	if len(batch.Transactions) == 0 {
+		log.Info("the Safe batch is empty\n")
+		return
+	}
+	for i, tx := range batch.Transactions {
+		entry := batchEntry{Index: i, To: tx.To, Value: tx.Value}
+		call := ""
+		if tx.ContractMethod != nil {
+			entry.Method = tx.ContractMethod.Name
+			args := make([]string, len(tx.ContractMethod.Inputs))
+			for j, in := range tx.ContractMethod.Inputs {
+				args[j] = fmt.Sprintf("%s: %s", in.Name, tx.ContractInputsValues[in.Name])
+			}
+			call = fmt.Sprintf("%s(%s)", entry.Method, strings.Join(args, ", "))
+		} else if tx.Data != nil {
+			entry.Calldata = *tx.Data
+			call = entry.Calldata
+		}
+		log.Result("batch_transaction", fmt.Sprintf("%d: %s value: %s %s\n", i, tx.To, tx.Value, call), entry)
+	}
+}
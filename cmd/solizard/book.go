@@ -0,0 +1,259 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+
+	"github.com/zsystm/solizard/internal/config"
+	"github.com/zsystm/solizard/internal/ctx"
+	"github.com/zsystm/solizard/internal/log"
+	"github.com/zsystm/solizard/internal/prompt"
+)
+
+const bookUsage = `usage:
+  solizard book list [--chain <id>] [--abi <name>] [--tag <tag>]
+  solizard book add --chain <id> --abi <name> --address <address> [--label <label>] [--tags <a,b>] [--notes <notes>]
+  solizard book rename --chain <id> [--abi <name>] --address <address> --label <label>
+  solizard book remove --chain <id> [--abi <name>] --address <address>
+  solizard book import <file.json|file.csv>
+  solizard book export [--format json|csv] [--out <file>]`
+
+func runBookCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing book subcommand\n%s", bookUsage)
+	}
+	fs := newFlagSet("book " + args[0])
+	chainId := fs.Uint64("chain", 0, "chain id of the entry, 0 for the entries without a known chain")
+	address := fs.String("address", "", "contract address")
+	label := fs.String("label", "", "label of the entry")
+
+	switch args[0] {
+	case "list":
+		abiName := fs.String("abi", "", "only list entries of the abi")
+		tag := fs.String("tag", "", "only list entries with the tag")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		filterChain := isFlagSet(fs, "chain")
+		listAddressBook(func(id uint64, ci config.ContractInfo) bool {
+			return (!filterChain || id == *chainId) &&
+				(*abiName == "" || ci.Abi == *abiName) &&
+				(*tag == "" || hasTag(ci, *tag))
+		})
+		return nil
+	case "add":
+		tags := fs.String("tags", "", "comma separated tags")
+		notes := fs.String("notes", "", "notes of the entry")
+		abiName := fs.String("abi", "", "name of the abi file without .abi")
+		if err := parseBookEdit(fs, args[1:]); err != nil {
+			return err
+		}
+		ci := config.ContractInfo{Abi: *abiName, Address: *address, Label: *label, Notes: *notes}
+		if *tags != "" {
+			ci.Tags = strings.Split(*tags, ",")
+		}
+		return addBookEntry(*chainId, ci)
+	case "rename":
+		abiName := fs.String("abi", "", "abi of the entry, required if the address is saved with more than one abi")
+		if err := parseBookEdit(fs, args[1:]); err != nil {
+			return err
+		}
+		return renameBookEntry(*chainId, *abiName, *address, *label)
+	case "remove":
+		abiName := fs.String("abi", "", "abi of the entry, required if the address is saved with more than one abi")
+		if err := parseBookEdit(fs, args[1:]); err != nil {
+			return err
+		}
+		return removeBookEntry(*chainId, *abiName, *address)
+	case "import":
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			return fmt.Errorf("missing file to import\n%s", bookUsage)
+		}
+		return importAddressBook(fs.Arg(0))
+	case "export":
+		format := fs.String("format", "", "json or csv (default: by --out extension, json for stdout)")
+		out := fs.String("out", "", "file to write to (default: stdout)")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		f := config.BookFormat(*format)
+		if f == "" {
+			f = config.BookFormatFromPath(*out)
+		}
+		return exportAddressBook(*out, f)
+	default:
+		return fmt.Errorf("unknown book subcommand %q\n%s", args[0], bookUsage)
+	}
+}
+
+// parseBookEdit parses the flags of a subcommand editing the address book, which requires --chain.
+// The entries without a known chain are only edited with an explicit --chain 0.
+func parseBookEdit(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !isFlagSet(fs, "chain") {
+		return fmt.Errorf("missing --chain\n%s", bookUsage)
+	}
+	return nil
+}
+
+func hasTag(ci config.ContractInfo, tag string) bool {
+	for _, t := range ci.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// listAddressBook prints the entries of the address book accepted by filter
+func listAddressBook(filter func(chainId uint64, ci config.ContractInfo) bool) {
+	count := 0
+	for _, chainId := range AddressBook.ChainIds() {
+		for _, ci := range AddressBook[chainId] {
+			if !filter(chainId, ci) {
+				continue
+			}
+			text := fmt.Sprintf("chain %d: %s %s", chainId, ci.Abi, ci)
+			if ci.Notes != "" {
+				text += " (" + ci.Notes + ")"
+			}
+			log.Result("address_book_entry", text+"\n", bookEntry{ChainId: chainId, ContractInfo: ci})
+			count++
+		}
+	}
+	if count == 0 {
+		log.Result("address_book_empty", "no address book entries found\n", nil)
+	}
+}
+
+func addBookEntry(chainId uint64, ci config.ContractInfo) error {
+	if err := ci.Validate(); err != nil {
+		return err
+	}
+	AddressBook.Add(chainId, ci)
+	if err := config.WriteAddressBook(ContractInfosPath, AddressBook); err != nil {
+		return err
+	}
+	log.Result("address_book_added", fmt.Sprintf("saved %s %s on chain %d\n", ci.Abi, ci.Address, chainId), bookEntry{ChainId: chainId, ContractInfo: ci})
+	return nil
+}
+
+func renameBookEntry(chainId uint64, abiName, address, label string) error {
+	if err := AddressBook.Rename(chainId, abiName, address, label); err != nil {
+		return err
+	}
+	if err := config.WriteAddressBook(ContractInfosPath, AddressBook); err != nil {
+		return err
+	}
+	log.Result("address_book_renamed", fmt.Sprintf("renamed %s on chain %d to %q\n", address, chainId, label), map[string]interface{}{
+		"chainId": chainId, "address": address, "label": label,
+	})
+	return nil
+}
+
+func removeBookEntry(chainId uint64, abiName, address string) error {
+	if err := AddressBook.Remove(chainId, abiName, address); err != nil {
+		return err
+	}
+	if err := config.WriteAddressBook(ContractInfosPath, AddressBook); err != nil {
+		return err
+	}
+	log.Result("address_book_removed", fmt.Sprintf("removed %s from chain %d\n", address, chainId), map[string]interface{}{
+		"chainId": chainId, "address": address,
+	})
+	return nil
+}
+
+// importAddressBook merges the entries of the file into the address book, invalid entries are reported and skipped
+func importAddressBook(path string) error {
+	imported, err := config.ImportAddressBook(path)
+	if err != nil {
+		return fmt.Errorf("failed to import %s: %v", path, err)
+	}
+	skipped := imported.Sanitize()
+	for _, err := range skipped {
+		log.Error(fmt.Sprintf("skipping invalid address book entry (reason: %v)\n", err))
+	}
+	count := 0
+	for _, infos := range imported {
+		count += len(infos)
+	}
+	AddressBook.Merge(imported)
+	if err = config.WriteAddressBook(ContractInfosPath, AddressBook); err != nil {
+		return err
+	}
+	log.Result("address_book_imported", fmt.Sprintf("imported %d entries from %s (skipped: %d)\n", count, path, len(skipped)), map[string]interface{}{
+		"path": path, "imported": count, "skipped": len(skipped),
+	})
+	return nil
+}
+
+// exportAddressBook writes the address book to the file, or to stdout if path is empty or "-"
+func exportAddressBook(path string, format config.BookFormat) error {
+	if path == "" || path == "-" {
+		return config.ExportAddressBook(os.Stdout, AddressBook, format)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err = config.ExportAddressBook(f, AddressBook, format); err != nil {
+		return err
+	}
+	log.Result("address_book_exported", fmt.Sprintf("exported the address book to %s\n", path), map[string]string{
+		"path": path, "format": string(format),
+	})
+	return nil
+}
+
+// manageAddressBook is the interactive address book step, actions apply to the current chain
+func manageAddressBook(sctx *ctx.Context, abis map[string]abi.ABI) {
+	chainId := sctx.ChainId().Uint64()
+	for {
+		var err error
+		switch prompt.MustSelectBookAction(chainId) {
+		case prompt.BookActionList:
+			listAddressBook(func(id uint64, _ config.ContractInfo) bool {
+				return id == chainId || id == config.AnyChain
+			})
+		case prompt.BookActionAdd:
+			abiName, _ := prompt.MustSelectContractABI(abis)
+			address := prompt.MustInputContractAddress()
+			err = addBookEntry(chainId, config.ContractInfo{
+				Abi:     abiName,
+				Address: address,
+				Label:   prompt.MustInputLabel(address),
+				Tags:    prompt.MustInputTags(),
+				Notes:   prompt.MustInputNotes(),
+			})
+		case prompt.BookActionRename:
+			if ci := prompt.MustSelectBookEntry(AddressBook[chainId]); ci != nil {
+				err = renameBookEntry(chainId, ci.Abi, ci.Address, prompt.MustInputLabel(ci.Address))
+			}
+		case prompt.BookActionRemove:
+			if ci := prompt.MustSelectBookEntry(AddressBook[chainId]); ci != nil {
+				err = removeBookEntry(chainId, ci.Abi, ci.Address)
+			}
+		case prompt.BookActionImport:
+			err = importAddressBook(prompt.MustInputFilePath("Enter the json or csv file to import"))
+		case prompt.BookActionExport:
+			path := prompt.MustInputFilePath("Enter the file to export to (.json or .csv)")
+			err = exportAddressBook(path, config.BookFormatFromPath(path))
+		case prompt.BookActionBack:
+			return
+		}
+		if err != nil {
+			log.Error(fmt.Sprintf("address book action failed (reason: %v)\n", err))
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zsystm/solizard/internal/config"
+)
+
+func TestBookCommandRequiresChain(t *testing.T) {
+	if err := setup(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	const address = "0x000000000000000000000000000000000000dEaD"
+	AddressBook = config.AddressBook{config.AnyChain: {{Abi: "TetherToken", Address: address}}}
+
+	for _, args := range [][]string{
+		{"add", "--abi", "TetherToken", "--address", address, "--label", "dead"},
+		{"rename", "--address", address, "--label", "dead"},
+		{"remove", "--address", address},
+	} {
+		if err := runBookCommand(args); err == nil || !strings.Contains(err.Error(), "missing --chain") {
+			t.Errorf("book %s without --chain: expected an error, got %v", args[0], err)
+		}
+	}
+	if ci := AddressBook[config.AnyChain][0]; ci.Label != "" || len(AddressBook) != 1 {
+		t.Fatalf("the address book changed: %+v", AddressBook)
+	}
+
+	// the entries without a known chain are edited with an explicit --chain 0
+	if err := runBookCommand([]string{"rename", "--chain", "0", "--address", address, "--label", "dead"}); err != nil {
+		t.Fatal(err)
+	}
+	if ci := AddressBook[config.AnyChain][0]; ci.Label != "dead" {
+		t.Errorf("unexpected entry: %+v", ci)
+	}
+	if err := runBookCommand([]string{"remove", "--chain", "0", "--address", address}); err != nil {
+		t.Fatal(err)
+	}
+	if len(AddressBook) != 0 {
+		t.Errorf("unexpected address book: %+v", AddressBook)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/zsystm/solizard/internal/log"
+	"github.com/zsystm/solizard/lib"
+)
+
+const chainsUsage = `usage:
+  solizard chains list [--search <name, short name or chain id>]
+  solizard chains import [--custom] <chainlist.json>
+  solizard chains add --chain-id <id> --name <name> --rpc <url> [--short-name <name>] [--symbol <symbol>] [--decimals <n>] [--explorer <url>]`
+
+func runChainsCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing chains subcommand\n%s", chainsUsage)
+	}
+	fs := newFlagSet("chains " + args[0])
+
+	switch args[0] {
+	case "list":
+		search := fs.String("search", "", "only list chains whose name, short name or chain id contains the text")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		for _, c := range ChainInfos.Chains() {
+			if *search != "" && !strings.Contains(strings.ToLower(c.String()), strings.ToLower(*search)) {
+				continue
+			}
+			log.Result("chain", c.String()+"\n", c)
+		}
+		return nil
+	case "import":
+		custom := fs.Bool("custom", false, "import into the custom chains file instead of the chain registry")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if fs.NArg() != 1 {
+			return fmt.Errorf("missing file to import\n%s", chainsUsage)
+		}
+		chains, err := lib.ReadChainsJSON(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		target := ChainInfosPath
+		if *custom {
+			target = CustomChainsPath
+		}
+		return importChains(target, chains)
+	case "add":
+		chainId := fs.Uint64("chain-id", 0, "chain id")
+		name := fs.String("name", "", "chain name")
+		shortName := fs.String("short-name", "", "short name of the chain")
+		rpc := fs.String("rpc", "", "comma separated rpc urls")
+		symbol := fs.String("symbol", "ETH", "symbol of the native currency")
+		decimals := fs.Int("decimals", 18, "decimals of the native currency")
+		explorer := fs.String("explorer", "", "block explorer url")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		chain := &lib.ChainInfo{
+			Name:           *name,
+			ChainID:        *chainId,
+			ShortName:      *shortName,
+			NetworkID:      *chainId,
+			NativeCurrency: lib.NativeCurrency{Name: *symbol, Symbol: *symbol, Decimals: *decimals},
+			RPC:            strings.Split(*rpc, ","),
+		}
+		if *rpc == "" {
+			chain.RPC = nil
+		}
+		if *explorer != "" {
+			chain.Explorers = []lib.Explorer{{Name: *explorer, URL: *explorer}}
+		}
+		return importChains(CustomChainsPath, []*lib.ChainInfo{chain})
+	default:
+		return fmt.Errorf("unknown chains subcommand %q\n%s", args[0], chainsUsage)
+	}
+}
+
+// importChains merges the chains into the chains file at target, invalid chains are reported and skipped.
+// The target file is created if it doesn't exist.
+func importChains(target string, chains []*lib.ChainInfo) error {
+	valid, skipped := lib.SanitizeChains(chains)
+	for _, err := range skipped {
+		log.Error(fmt.Sprintf("skipping invalid chain (reason: %v)\n", err))
+	}
+
+	var existing []*lib.ChainInfo
+	if _, err := os.Stat(target); err == nil {
+		if existing, err = lib.ReadChainsJSON(target); err != nil {
+			return err
+		}
+	}
+	registry := lib.NewChainRegistry(existing)
+	added, replaced := registry.Merge(valid)
+	if err := lib.WriteChainsJSON(target, registry.Chains()); err != nil {
+		return fmt.Errorf("failed to write %s: %v", target, err)
+	}
+	log.Result("chains_imported", fmt.Sprintf("imported chains into %s (added: %d, updated: %d, skipped: %d)\n", target, added, replaced, len(skipped)), map[string]interface{}{
+		"path": target, "added": added, "updated": replaced, "skipped": len(skipped),
+	})
+	return nil
+}
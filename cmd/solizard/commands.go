@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// command is a non-interactive subcommand, e.g. `solizard book list`
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{name: "book", usage: "manage the address book (list, add, rename, remove, import, export)", run: runBookCommand},
+	{name: "decode", usage: "decode calldata or the input of a transaction with the abis", run: runDecodeCommand},
+	{name: "sign", usage: "build and sign a transaction offline, print or write the raw transaction", run: runSignCommand},
+	{name: "broadcast", usage: "send a raw signed transaction and wait for its receipt", run: runBroadcastCommand},
+	{name: "history", usage: "list the sent transactions, re-check their receipts, speed up or cancel them (list, check, speed-up, cancel)", run: runHistoryCommand},
+	{name: "config", usage: "show the effective configuration and where each value is set (show)", run: runConfigCommand},
+	{name: "chains", usage: "manage the chain registry (list, import, add custom chains)", run: runChainsCommand},
+}
+
+var errUnknownCommand = errors.New("unknown command")
+
+// runCommand runs the subcommand named by the first argument
+func runCommand(args []string) error {
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(args[1:])
+		}
+	}
+	return fmt.Errorf("%w %q\n%s", errUnknownCommand, args[0], commandsUsage())
+}
+
+func commandsUsage() string {
+	var sb strings.Builder
+	sb.WriteString("Usage: solizard [flags] [command]\n\nCommands:\n")
+	for _, c := range commands {
+		sb.WriteString(fmt.Sprintf("  %-10s %s\n", c.name, c.usage))
+	}
+	sb.WriteString("\nRun solizard without a command to start the interactive shell.\n\nFlags:\n")
+	return sb.String()
+}
+
+func printUsage() {
+	fmt.Fprint(os.Stderr, commandsUsage())
+	flag.PrintDefaults()
+}
+
+// newFlagSet returns a flag set for the subcommand whose errors are returned instead of exiting
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("solizard "+name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	return fs
+}
+
+// isFlagSet returns true if the flag was given on the command line
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
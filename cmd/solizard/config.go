@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zsystm/solizard/internal/config"
+	"github.com/zsystm/solizard/internal/ctx"
+	"github.com/zsystm/solizard/internal/log"
+	"github.com/zsystm/solizard/lib"
+)
+
+const configUsage = `usage:
+  solizard config show [--profile <name>]`
+
+func runConfigCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing config subcommand\n%s", configUsage)
+	}
+	fs := newFlagSet("config " + args[0])
+
+	switch args[0] {
+	case "show":
+		profile := fs.String("profile", ProfileName, "also show the effective settings of the profile")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		return showConfig(*profile)
+	default:
+		return fmt.Errorf("unknown config subcommand %q\n%s", args[0], configUsage)
+	}
+}
+
+// showConfig prints the effective config with the source of each value,
+// and the effective settings and failover endpoints of the profile if given
+func showConfig(profile string) error {
+	fields := maskSecrets(Conf.Fields())
+	var profileFields []config.Field
+	var failover []string
+	if profile != "" {
+		var err error
+		if profileFields, err = Conf.ProfileFields(profile); err != nil {
+			return err
+		}
+		profileFields = maskSecrets(profileFields)
+		if failover, err = failoverEndpoints(profile); err != nil {
+			return err
+		}
+	}
+
+	if log.IsJSON() {
+		out := map[string]interface{}{"home": HomeDir, "configFile": ConfigPath, "abiDir": AbiDir, "fields": fields, "profiles": Conf.ProfileNames()}
+		if profile != "" {
+			out["profile"] = map[string]interface{}{"name": profile, "fields": profileFields, "failover": failover}
+		}
+		log.Emit("config", out)
+		return nil
+	}
+
+	fmt.Printf("# solizard directory: %s\n", HomeDir)
+	fmt.Printf("# config file: %s\n", ConfigPath)
+	fmt.Printf("# abi directory: %s\n", AbiDir)
+	fmt.Printf("# profiles: %s\n", strings.Join(Conf.ProfileNames(), ", "))
+	printFields(fields)
+	if profile != "" {
+		fmt.Printf("\n# effective settings of the profile\n[profiles.%s]\n", profile)
+		printFields(profileFields)
+		if len(failover) > 0 {
+			fmt.Println("# failover endpoints of the chain registry")
+			for _, endpoint := range failover {
+				fmt.Printf("#   %s\n", endpoint)
+			}
+		}
+	}
+	return nil
+}
+
+// failoverEndpoints returns the failover endpoints of the profile with their secrets hidden
+func failoverEndpoints(profile string) ([]string, error) {
+	p, err := Conf.Profile(profile)
+	if err != nil {
+		return nil, err
+	}
+	if p.DisableFailover {
+		return []string{}, nil
+	}
+	endpoints := make([]string, 0)
+	for _, endpoint := range ctx.FailoverEndpoints(ChainInfos, p.ChainId) {
+		endpoints = append(endpoints, lib.RedactRPC(endpoint))
+	}
+	return endpoints, nil
+}
+
+// printFields prints the fields in toml syntax, annotated with their sources
+func printFields(fields []config.Field) {
+	width := 0
+	lines := make([]string, len(fields))
+	for i, f := range fields {
+		if s, ok := f.Value.(string); ok {
+			lines[i] = fmt.Sprintf("%s = %q", f.Key, s)
+		} else {
+			lines[i] = fmt.Sprintf("%s = %v", f.Key, f.Value)
+		}
+		width = max(width, len(lines[i]))
+	}
+	for i, f := range fields {
+		fmt.Printf("%-*s  # %s\n", width, lines[i], f.Source)
+	}
+}
+
+// maskSecrets hides the private key, only whether it's set is shown, and the api keys of the rpc url
+func maskSecrets(fields []config.Field) []config.Field {
+	masked := make([]config.Field, len(fields))
+	for i, f := range fields {
+		if f.Key == "private_key" && f.Value != "" {
+			f.Value = "********"
+		}
+		if rpcURL, ok := f.Value.(string); ok && f.Key == "rpc_url" {
+			f.Value = lib.RedactRPC(rpcURL)
+		}
+		masked[i] = f
+	}
+	return masked
+}
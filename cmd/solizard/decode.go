@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	internalabi "github.com/zsystm/solizard/internal/abi"
+	"github.com/zsystm/solizard/internal/client"
+	"github.com/zsystm/solizard/internal/config"
+	"github.com/zsystm/solizard/internal/ctx"
+	"github.com/zsystm/solizard/internal/log"
+)
+
+const decodeUsage = `usage:
+  solizard decode <calldata>
+  solizard [--profile <name>] [--rpc-url <url>] decode <tx hash>`
+
+// txInfo is the json output of a decoded transaction
+type txInfo struct {
+	Hash    string `json:"hash"`
+	From    string `json:"from,omitempty"`
+	To      string `json:"to"`
+	Value   string `json:"value"`
+	Pending bool   `json:"pending"`
+}
+
+func runDecodeCommand(args []string) error {
+	fs := newFlagSet("decode")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("missing calldata or tx hash\n%s", decodeUsage)
+	}
+	abis, err := internalabi.LoadABIs(AbiDir)
+	if err != nil {
+		return err
+	}
+	input := fs.Arg(0)
+	if !isTxHash(input) {
+		return decodeInput(context.Background(), nil, abis, input)
+	}
+
+	// the transaction is fetched from the network of the profile
+	name := ProfileName
+	if name == "" {
+		name = config.DefaultProfileName
+	}
+	p, err := Conf.Profile(name)
+	if err != nil {
+		return err
+	}
+	sctx := ctx.NewCtx(p, ChainInfos)
+	if sctx.EthClient() == nil {
+		return fmt.Errorf("no rpc connection to fetch the transaction, check the rpc url of profile %s", name)
+	}
+	return decodeInput(context.Background(), sctx.EthClient(), abis, input)
+}
+
+// isTxHash returns true if the input is a 32 bytes hex string, calldata is never 32 bytes long
+func isTxHash(input string) bool {
+	b, err := hexutil.Decode(strings.TrimSpace(input))
+	return err == nil && len(b) == common.HashLength
+}
+
+// decodeInput prints the calls of the calldata, or of the input of the transaction if input is a tx hash
+func decodeInput(reqCtx context.Context, cli client.EthClient, abis map[string]abi.ABI, input string) error {
+	input = strings.TrimSpace(input)
+	var data []byte
+	if isTxHash(input) {
+		hash := common.HexToHash(input)
+		tx, pending, err := cli.TransactionByHash(reqCtx, hash)
+		if err != nil {
+			return fmt.Errorf("failed to get transaction %s (reason: %v)", hash.Hex(), err)
+		}
+		if tx.To() == nil {
+			return fmt.Errorf("transaction %s creates a contract, only calls can be decoded", hash.Hex())
+		}
+		info := txInfo{Hash: hash.Hex(), To: tx.To().Hex(), Value: tx.Value().String(), Pending: pending}
+		if from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx); err == nil {
+			info.From = from.Hex()
+		}
+		status := ""
+		if pending {
+			status = " (pending)"
+		}
+		log.Result("transaction", fmt.Sprintf("transaction %s%s from %s to %s, value: %s wei\n", info.Hash, status, info.From, info.To, info.Value), info)
+		data = tx.Data()
+	} else {
+		var err error
+		if data, err = hexutil.Decode(input); err != nil {
+			return fmt.Errorf("invalid calldata, expected 0x prefixed hex (reason: %v)", err)
+		}
+	}
+
+	calls, err := internalabi.DecodeCalldata(abis, data)
+	if err != nil {
+		return err
+	}
+	for _, call := range calls {
+		log.Result("decoded_call", call.String(), call.JSON())
+	}
+	return nil
+}
@@ -0,0 +1,1387 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/asm"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	internalabi "github.com/zsystm/solizard/internal/abi"
+	"github.com/zsystm/solizard/internal/client"
+	"github.com/zsystm/solizard/internal/config"
+	"github.com/zsystm/solizard/internal/ctx"
+	"github.com/zsystm/solizard/internal/events"
+	"github.com/zsystm/solizard/internal/history"
+	"github.com/zsystm/solizard/internal/log"
+	"github.com/zsystm/solizard/internal/prompt"
+	"github.com/zsystm/solizard/internal/step"
+	"github.com/zsystm/solizard/lib"
+)
+
+// simulatedChainId is the chain id of go-ethereum's simulated backend
+const simulatedChainId = 1337
+
+// initialSupply is the token balance of the deployer, 1,000,000 USDT with 6 decimals
+var initialSupply = big.NewInt(1_000_000_000_000)
+
+// autoMiningClient mines a block after each sent transaction,
+// like a dev node does, so receipts are available when solizard asks for them
+type autoMiningClient struct {
+	simulated.Client
+	backend *simulated.Backend
+}
+
+func (c autoMiningClient) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	if err := c.Client.SendTransaction(ctx, tx); err != nil {
+		return err
+	}
+	c.backend.Commit()
+	return nil
+}
+
+type testEnv struct {
+	backend *simulated.Backend
+	key     *ecdsa.PrivateKey
+	from    common.Address
+	token   common.Address
+	abis    map[string]abi.ABI
+}
+
+// tokenAddress is the address of the token in the genesis block of the simulated chain
+var tokenAddress = common.HexToAddress("0x000000000000000000000000000000000000dac1")
+
+// newTestEnv sets up solizard in a temporary home and puts the token on a simulated chain.
+// The token is testdata/TetherToken.evm, which implements the bundled TetherToken abi with the semantics of USDT,
+// its storage is set in the genesis block as if the deployer had deployed it with the initial supply.
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	if err := setup(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	abis, err := internalabi.LoadABIs(AbiDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	backend := simulated.NewBackend(types.GenesisAlloc{
+		// the deployment of the token used nonce 0
+		from: {Balance: new(big.Int).Mul(big.NewInt(100), big.NewInt(1e18)), Nonce: 1},
+		tokenAddress: {
+			Code: assemble(t, "TetherToken.evm"),
+			Storage: map[common.Hash]common.Hash{
+				common.BigToHash(big.NewInt(0)): common.BytesToHash(from.Bytes()),
+				common.BigToHash(big.NewInt(1)): common.BigToHash(initialSupply),
+				mappingSlot(from, 2):            common.BigToHash(initialSupply),
+				common.BigToHash(big.NewInt(4)): shortString("Tether USD"),
+				common.BigToHash(big.NewInt(5)): shortString("USDT"),
+				common.BigToHash(big.NewInt(6)): common.BigToHash(big.NewInt(6)),
+			},
+		},
+	})
+	t.Cleanup(func() { backend.Close() })
+
+	return &testEnv{backend: backend, key: key, from: from, token: tokenAddress, abis: abis}
+}
+
+// assemble returns the bytecode of the go-ethereum core/asm source in testdata
+func assemble(t *testing.T, name string) []byte {
+	t.Helper()
+	src, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	compiler := asm.NewCompiler(false)
+	compiler.Feed(asm.Lex(src, false))
+	code, errs := compiler.Compile()
+	if len(errs) > 0 {
+		t.Fatalf("failed to assemble %s: %v", name, errs)
+	}
+	return common.FromHex(code)
+}
+
+// mappingSlot returns the storage slot of the key in the solidity mapping at slot
+func mappingSlot(key common.Address, slot int64) common.Hash {
+	return crypto.Keccak256Hash(common.LeftPadBytes(key.Bytes(), 32), common.BigToHash(big.NewInt(slot)).Bytes())
+}
+
+// shortString returns the storage word of a solidity string of less than 32 bytes
+func shortString(s string) common.Hash {
+	var word common.Hash
+	copy(word[:], s)
+	word[31] = byte(len(s) * 2)
+	return word
+}
+
+// ctx returns a ctx of a profile with the given chain id, connected to the simulated chain
+func (e *testEnv) ctx(chainId uint64) *ctx.Context {
+	sctx := ctx.NewCtx(&config.Profile{Name: "test", ChainId: chainId, WaitTime: "0s"}, ChainInfos)
+	sctx.SetEthClient(autoMiningClient{Client: e.backend.Client(), backend: e.backend})
+	return sctx
+}
+
+func (e *testEnv) privateKeyHex() string {
+	return hex.EncodeToString(crypto.FromECDSA(e.key))
+}
+
+// run runs a session answering the prompts with the script lines
+// and returns the json records printed by solizard
+func (e *testEnv) run(t *testing.T, sctx *ctx.Context, lines ...string) []record {
+	t.Helper()
+	return runScript(t, func() error {
+		return newSession(sctx, e.abis).machine().Run(step.StepChangeContract)
+	}, lines...)
+}
+
+// runScript runs fn answering the prompts with the script lines
+// and returns the json records printed by solizard
+func runScript(t *testing.T, fn func() error, lines ...string) []record {
+	t.Helper()
+	return parseRecords(runScriptOutput(t, fn, lines...))
+}
+
+// runScriptOutput runs fn answering the prompts with the script lines in json mode
+// and returns what solizard printed to stdout
+func runScriptOutput(t *testing.T, fn func() error, lines ...string) string {
+	t.Helper()
+	script, err := prompt.NewScript(strings.NewReader(strings.Join(lines, "\n")), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prompt.SetPrompter(script)
+	log.SetFormat(log.FormatJSON)
+	defer func() {
+		prompt.SetPrompter(prompt.Promptui{})
+		log.SetFormat(log.FormatText)
+	}()
+
+	out := captureStdout(t, func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("%v", r)
+			}
+		}()
+		err = fn()
+	})
+	if err != nil {
+		t.Fatalf("failed: %v\noutput:\n%s", err, out)
+	}
+	if !script.Done() {
+		t.Fatalf("ended before the script\noutput:\n%s", out)
+	}
+	return out
+}
+
+// record is a json output line
+type record struct {
+	Type string                 `json:"type"`
+	Data map[string]interface{} `json:"data"`
+}
+
+// parseRecords returns the json records of the output
+func parseRecords(out string) []record {
+	var records []record
+	for _, line := range strings.Split(out, "\n") {
+		var r record
+		if json.Unmarshal([]byte(line), &r) == nil && r.Type != "" {
+			records = append(records, r)
+		}
+	}
+	return records
+}
+
+func (e *testEnv) balanceOf(t *testing.T, account common.Address) *big.Int {
+	t.Helper()
+	input, err := e.tokenABI().Pack("balanceOf", account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := e.backend.Client().CallContract(context.Background(), ethereum.CallMsg{To: &e.token, Data: input}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return new(big.Int).SetBytes(output)
+}
+
+func TestReadAndWrite(t *testing.T) {
+	env := newTestEnv(t)
+	to := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+
+	records := env.run(t, env.ctx(simulatedChainId),
+		"TetherToken",
+		env.token.Hex(),
+		"test usdt",
+		// read the balance of the deployer
+		"Read",
+		"balanceOf",
+		env.from.Hex(),
+		// transfer 1.5 USDT
+		string(step.StepSelectMethod),
+		"Write",
+		env.privateKeyHex(),
+		"transfer",
+		to.Hex(),
+		"1500000",
+		"y",
+		string(step.StepExit),
+	)
+
+	result := findRecord(t, records, "call_result")
+	outputs := result["outputs"].([]interface{})
+	balance := outputs[0].(map[string]interface{})
+	if balance["value"] != initialSupply.String() || balance["formatted"] != "1,000,000 USDT" {
+		t.Errorf("unexpected balanceOf output: %v", balance)
+	}
+
+	sent := findRecord(t, records, "tx_sent")
+	if sent["method"] != "transfer" || sent["from"] != env.from.Hex() {
+		t.Errorf("unexpected tx_sent: %v", sent)
+	}
+	receipt := findRecord(t, records, "receipt")
+	if receipt["status"] != "0x1" {
+		t.Errorf("transaction failed: %v", receipt)
+	}
+	event := findRecord(t, records, "event")
+	if event["event"] != "Transfer" {
+		t.Errorf("unexpected event: %v", event)
+	}
+
+	if got := env.balanceOf(t, to); got.Cmp(big.NewInt(1_500_000)) != 0 {
+		t.Errorf("balance of recipient = %v, want 1500000", got)
+	}
+	// the validated address is saved in the address book of the chain
+	deployments := AddressBook.Deployments(simulatedChainId, "TetherToken")
+	if len(deployments) != 1 || deployments[0].Address != env.token.Hex() || deployments[0].Label != "test usdt" {
+		t.Errorf("unexpected address book deployments: %v", deployments)
+	}
+}
+
+func TestSaveKnownAddress(t *testing.T) {
+	env := newTestEnv(t)
+	AddressBook.Add(simulatedChainId, config.ContractInfo{Abi: "TetherToken", Address: env.token.Hex(), Label: "test usdt"})
+
+	// the typed address is in the address book, it isn't labeled again
+	records := env.run(t, env.ctx(simulatedChainId),
+		"TetherToken",
+		prompt.NewAddress,
+		env.token.Hex(),
+		"Read",
+		"decimals",
+		string(step.StepExit),
+	)
+	findRecord(t, records, "call_result")
+	if deployments := AddressBook.Deployments(simulatedChainId, "TetherToken"); len(deployments) != 1 || deployments[0].Label != "test usdt" {
+		t.Errorf("unexpected address book deployments: %v", deployments)
+	}
+}
+
+func TestRefuseToSignOnChainIdMismatch(t *testing.T) {
+	env := newTestEnv(t)
+	to := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+
+	// the profile is for Ethereum mainnet but the node serves the simulated chain
+	records := env.run(t, env.ctx(1),
+		"TetherToken",
+		// skip the mainnet USDT of the address book
+		prompt.NewAddress,
+		env.token.Hex(),
+		"",
+		"Write",
+		env.privateKeyHex(),
+		"transfer",
+		to.Hex(),
+		"1500000",
+		// refused before the confirmation, back to the method selection
+		"Read",
+		"decimals",
+		string(step.StepExit),
+	)
+
+	refused := false
+	for _, r := range records {
+		if r.Type == "tx_sent" {
+			t.Fatalf("transaction was sent: %v", r)
+		}
+		if r.Type == "error" && strings.Contains(r.Data["message"].(string), "refusing to sign") {
+			refused = true
+		}
+	}
+	if !refused {
+		t.Errorf("signing was not refused, records: %v", records)
+	}
+	if got := env.balanceOf(t, to); got.Sign() != 0 {
+		t.Errorf("balance of recipient = %v, want 0", got)
+	}
+}
+
+func TestRejectAddressWithoutCode(t *testing.T) {
+	env := newTestEnv(t)
+
+	records := env.run(t, env.ctx(simulatedChainId),
+		"TetherToken",
+		// an account without code is asked again
+		env.from.Hex(),
+		env.token.Hex(),
+		"",
+		"Read",
+		"symbol",
+		string(step.StepExit),
+	)
+
+	if err := findRecord(t, records, "error"); !strings.Contains(err["message"].(string), "Invalid contract address") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	result := findRecord(t, records, "call_result")
+	if output := result["outputs"].([]interface{})[0].(map[string]interface{}); output["value"] != "USDT" {
+		t.Errorf("unexpected symbol output: %v", output)
+	}
+}
+
+// interruptedClient fails the code requests as if the user pressed Ctrl-C while waiting for them
+type interruptedClient struct {
+	simulated.Client
+}
+
+func (interruptedClient) CodeAt(context.Context, common.Address, *big.Int) ([]byte, error) {
+	return nil, fmt.Errorf("Post \"http://localhost:8545\": %w", context.Canceled)
+}
+
+func TestInterruptAddressValidation(t *testing.T) {
+	env := newTestEnv(t)
+	sctx := ctx.NewCtx(&config.Profile{Name: "test", ChainId: simulatedChainId, WaitTime: "0s"}, ChainInfos)
+	sctx.SetEthClient(interruptedClient{Client: env.backend.Client()})
+
+	records := env.run(t, sctx,
+		"TetherToken",
+		env.token.Hex(),
+		// back to the step picker rather than the address prompt
+		string(step.StepExit),
+	)
+
+	if err := findRecord(t, records, "error"); err["message"] != "Invalid contract address (interrupted)" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// tokenABI returns the bundled TetherToken abi of the token
+func (e *testEnv) tokenABI() abi.ABI {
+	return e.abis["TetherToken.abi"]
+}
+
+// findRecord returns the first json record of the given type
+func findRecord(t *testing.T, records []record, typ string) map[string]interface{} {
+	t.Helper()
+	for _, r := range records {
+		if r.Type == typ {
+			return r.Data
+		}
+	}
+	t.Fatalf("no %s record in %v", typ, records)
+	return nil
+}
+
+// captureStdout returns what fn prints to stdout
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	done := make(chan string)
+	go func() {
+		var sb strings.Builder
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+		for scanner.Scan() {
+			sb.WriteString(scanner.Text() + "\n")
+		}
+		done <- sb.String()
+	}()
+	defer func() {
+		os.Stdout = stdout
+	}()
+	fn()
+	w.Close()
+	return <-done
+}
+
+func TestRecoverFromFailedCall(t *testing.T) {
+	env := newTestEnv(t)
+
+	records := env.run(t, env.ctx(simulatedChainId),
+		"TetherToken",
+		env.token.Hex(),
+		"",
+		// the token doesn't implement upgradedAddress(), the call fails
+		"Read",
+		"upgradedAddress",
+		// back to the method picker
+		"Read",
+		"symbol",
+		string(step.StepExit),
+	)
+
+	if err := findRecord(t, records, "error"); !strings.Contains(err["message"].(string), "failed to call contract") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	result := findRecord(t, records, "call_result")
+	if result["method"] != "symbol" {
+		t.Errorf("unexpected call result: %v", result)
+	}
+	// the failed call isn't repeated by the repeat last call step
+	calls, err := history.ReadRecentCalls(RecentCallsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recent := calls.Of(simulatedChainId, env.token); len(recent) != 1 || recent[0].Method != "symbol" {
+		t.Errorf("unexpected recent calls: %+v", recent)
+	}
+}
+
+func TestSignWithKeystore(t *testing.T) {
+	env := newTestEnv(t)
+	to := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	dir := t.TempDir()
+	if _, err := keystore.NewKeyStore(dir, keystore.LightScryptN, keystore.LightScryptP).ImportECDSA(env.key, "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	sctx := ctx.NewCtx(&config.Profile{Name: "test", ChainId: simulatedChainId, WaitTime: "0s", Keystore: dir}, ChainInfos)
+	sctx.SetEthClient(autoMiningClient{Client: env.backend.Client(), backend: env.backend})
+	records := env.run(t, sctx,
+		"TetherToken",
+		env.token.Hex(),
+		"",
+		"Write",
+		// a wrong password is asked again
+		"wrong",
+		"secret",
+		"transfer",
+		to.Hex(),
+		"1500000",
+		"y",
+		string(step.StepExit),
+	)
+
+	if sent := findRecord(t, records, "tx_sent"); sent["from"] != env.from.Hex() {
+		t.Errorf("unexpected tx_sent: %v", sent)
+	}
+	if got := env.balanceOf(t, to); got.Cmp(big.NewInt(1_500_000)) != 0 {
+		t.Errorf("balance of recipient = %v, want 1500000", got)
+	}
+}
+
+func TestOverridesAreNotSaved(t *testing.T) {
+	dir := t.TempDir()
+	err := setup(dir,
+		config.Override{Key: "rpc_url", Value: "http://localhost:8545", Source: "flag --rpc-url"},
+		config.Override{Key: "gas_limit", Value: "500000", Source: "env SOLIZARD_GAS_LIMIT"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := Conf.Profile(config.DefaultProfileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.RpcURL != "http://localhost:8545" || p.Gas() != 500000 {
+		t.Errorf("overrides are not applied to the profile: %+v", p)
+	}
+	if source := Conf.Source("gas_limit"); source != "env SOLIZARD_GAS_LIMIT" {
+		t.Errorf("source of gas_limit = %q", source)
+	}
+
+	// saving a profile keeps the values of the config file
+	Conf.SetProfile("local", &config.Profile{RpcURL: "http://localhost:8545", ChainId: 31337})
+	if err = config.WriteConfig(ConfigPath, Conf); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := config.ReadConfig(ConfigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.RpcURL != "https://eth.llamarpc.com" || saved.GasLimit != 0 || saved.Profiles["local"] == nil {
+		t.Errorf("unexpected saved config: %+v", saved)
+	}
+}
+
+func TestConfigShowHidesSecrets(t *testing.T) {
+	const apiKey = "84842078b09946638c03157f83405213"
+	err := setup(t.TempDir(),
+		config.Override{Key: "rpc_url", Value: "https://mainnet.infura.io/v3/" + apiKey, Source: "flag --rpc-url"},
+		config.Override{Key: "chain_id", Value: "1", Source: "flag --chain-id"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("RPC_KEY", "query-secret")
+	ChainInfos.Merge([]*lib.ChainInfo{{
+		Name:    "Keyed",
+		ChainID: 1,
+		RPC:     lib.RPCs{"https://eth.example.com/" + apiKey, "https://rpc.example.com?key=${RPC_KEY}"},
+	}})
+
+	for _, format := range []log.Format{log.FormatText, log.FormatJSON} {
+		log.SetFormat(format)
+		var showErr error
+		out := captureStdout(t, func() { showErr = showConfig(config.DefaultProfileName) })
+		log.SetFormat(log.FormatText)
+		if showErr != nil {
+			t.Fatal(showErr)
+		}
+		if strings.Contains(out, apiKey) || strings.Contains(out, "query-secret") {
+			t.Errorf("config show prints a secret:\n%s", out)
+		}
+		// the api keys are masked, the placeholders are kept
+		for _, want := range []string{"https://mainnet.infura.io/v3/****", "https://eth.example.com/****", "https://rpc.example.com?key=${RPC_KEY}"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("config show doesn't print %s:\n%s", want, out)
+			}
+		}
+	}
+}
+
+// chainIdService serves eth_chainId
+type chainIdService struct{}
+
+func (chainIdService) ChainId() hexutil.Uint64 {
+	return simulatedChainId
+}
+
+func TestSaveProfileWithPlaceholder(t *testing.T) {
+	if err := setup(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	const secret = "s3cr3t"
+	t.Setenv("SOLIZARD_TEST_RPC_KEY", secret)
+	server := rpc.NewServer()
+	if err := server.RegisterName("eth", chainIdService{}); err != nil {
+		t.Fatal(err)
+	}
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/"+secret {
+			http.NotFound(w, r)
+			return
+		}
+		server.ServeHTTP(w, r)
+	}))
+	defer endpoint.Close()
+	defer server.Stop()
+
+	// the endpoint of the chain registry is offered with its placeholder
+	rpcURL := endpoint.URL + "/${SOLIZARD_TEST_RPC_KEY}"
+	ChainInfos.Merge([]*lib.ChainInfo{{Name: "Simulated", ChainID: simulatedChainId, RPC: lib.RPCs{rpcURL}}})
+	sctx := ctx.NewCtx(&config.Profile{Name: "manual", ChainId: simulatedChainId}, ChainInfos)
+	out := runScriptOutput(t, func() error {
+		_, err := newSession(sctx, nil).inputRpcUrl(context.Background())
+		return err
+	}, rpcURL, "keyed")
+
+	if strings.Contains(out, secret) {
+		t.Errorf("the api key is printed:\n%s", out)
+	}
+	if sctx.RpcURL() != rpcURL {
+		t.Errorf("connected to %s, want %s", sctx.RpcURL(), rpcURL)
+	}
+	// the profile is saved with the placeholder
+	data, err := os.ReadFile(ConfigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), secret) {
+		t.Errorf("the api key is saved in the config file:\n%s", data)
+	}
+	saved, err := config.ReadConfig(ConfigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := saved.Profiles["keyed"]; p == nil || p.RpcURL != rpcURL {
+		t.Errorf("unexpected saved profile: %+v", p)
+	}
+}
+
+// rangeLimitedClient refuses log queries of more than maxRange blocks like public endpoints do
+type rangeLimitedClient struct {
+	autoMiningClient
+	maxRange uint64
+	queries  *int
+}
+
+func (c rangeLimitedClient) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	*c.queries++
+	if q.ToBlock.Uint64()-q.FromBlock.Uint64()+1 > c.maxRange {
+		return nil, fmt.Errorf("%w (reason: block range too large)", client.ErrRangeLimit)
+	}
+	return c.autoMiningClient.FilterLogs(ctx, q)
+}
+
+func TestEventHistory(t *testing.T) {
+	env := newTestEnv(t)
+	dead := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	other := common.HexToAddress("0x00000000000000000000000000000000000000Aa")
+	tokenAbi := env.tokenABI()
+	opts, err := bind.NewKeyedTransactorWithChainID(env.key, big.NewInt(simulatedChainId))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := bind.NewBoundContract(env.token, tokenAbi, env.backend.Client(), env.backend.Client(), env.backend.Client())
+	for _, to := range []common.Address{dead, other, dead} {
+		if _, err = token.Transact(opts, "transfer", to, big.NewInt(1_000_000)); err != nil {
+			t.Fatal(err)
+		}
+		env.backend.Commit()
+	}
+
+	queries := 0
+	sctx := env.ctx(simulatedChainId)
+	sctx.SetEthClient(rangeLimitedClient{
+		autoMiningClient: autoMiningClient{Client: env.backend.Client(), backend: env.backend},
+		maxRange:         2,
+		queries:          &queries,
+	})
+	records := env.run(t, sctx,
+		"TetherToken",
+		env.token.Hex(),
+		"",
+		"Read",
+		"symbol",
+		string(step.StepEvents),
+		"Transfer",
+		// any sender, to the dead address
+		"",
+		dead.Hex(),
+		"earliest",
+		"latest",
+		string(step.StepExit),
+	)
+
+	var logs []map[string]interface{}
+	for _, r := range records {
+		if r.Type == "log" {
+			logs = append(logs, r.Data)
+		}
+	}
+	if len(logs) != 2 {
+		t.Fatalf("got %d logs, want 2: %v", len(logs), records)
+	}
+	for _, l := range logs {
+		args := l["args"].([]interface{})
+		if args[1].(map[string]interface{})["value"] != dead.Hex() || l["timestamp"] == nil || l["txHash"] == "" {
+			t.Errorf("unexpected log: %v", l)
+		}
+		if value := args[2].(map[string]interface{}); value["formatted"] != "1 USDT" {
+			t.Errorf("unexpected transfer value: %v", value)
+		}
+	}
+	if queries < 3 {
+		t.Errorf("the block range was not split, %d queries", queries)
+	}
+}
+
+// watchingClient signals ready once solizard watches the logs,
+// by subscription or, if subscriptions are unsupported, by polling
+type watchingClient struct {
+	autoMiningClient
+	unsupported bool
+	watching    *bool
+	ready       chan struct{}
+	once        *sync.Once
+}
+
+func (c watchingClient) SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	*c.watching = true
+	if c.unsupported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	sub, err := c.autoMiningClient.SubscribeFilterLogs(ctx, q, ch)
+	c.once.Do(func() { close(c.ready) })
+	return sub, err
+}
+
+func (c watchingClient) BlockNumber(ctx context.Context) (uint64, error) {
+	n, err := c.autoMiningClient.BlockNumber(ctx)
+	if *c.watching {
+		c.once.Do(func() { close(c.ready) })
+	}
+	return n, err
+}
+
+func TestWatchEvents(t *testing.T) {
+	watchPollInterval = 20 * time.Millisecond
+	defer func() { watchPollInterval = events.DefaultPollInterval }()
+
+	for _, tc := range []struct {
+		name        string
+		unsupported bool
+	}{
+		{name: "subscription"},
+		{name: "polling", unsupported: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			dead := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+			cli := watchingClient{
+				autoMiningClient: autoMiningClient{Client: env.backend.Client(), backend: env.backend},
+				unsupported:      tc.unsupported,
+				watching:         new(bool),
+				ready:            make(chan struct{}),
+				once:             new(sync.Once),
+			}
+			sctx := env.ctx(simulatedChainId)
+			sctx.SetEthClient(cli)
+
+			// transfer while solizard watches, then stop watching with Ctrl-C
+			go func() {
+				<-cli.ready
+				tokenAbi := env.tokenABI()
+				opts, _ := bind.NewKeyedTransactorWithChainID(env.key, big.NewInt(simulatedChainId))
+				token := bind.NewBoundContract(env.token, tokenAbi, env.backend.Client(), env.backend.Client(), env.backend.Client())
+				for i := 0; i < 2; i++ {
+					if _, err := token.Transact(opts, "transfer", dead, big.NewInt(1_000_000)); err != nil {
+						t.Error(err)
+					}
+					env.backend.Commit()
+				}
+				time.Sleep(500 * time.Millisecond)
+				interrupts.interrupt()
+			}()
+			records := env.run(t, sctx,
+				"TetherToken",
+				env.token.Hex(),
+				"",
+				"Read",
+				"symbol",
+				string(step.StepWatch),
+				"Transfer",
+				"",
+				dead.Hex(),
+				string(step.StepExit),
+			)
+
+			logs := 0
+			for _, r := range records {
+				if r.Type == "log" {
+					logs++
+					if r.Data["event"] != "Transfer" {
+						t.Errorf("unexpected log: %v", r)
+					}
+				}
+			}
+			if logs != 2 {
+				t.Errorf("got %d logs, want 2: %v", logs, records)
+			}
+		})
+	}
+}
+
+const multicallABI = `[
+	{"type":"function","name":"multicall","stateMutability":"nonpayable","inputs":[{"name":"data","type":"bytes[]"}],"outputs":[{"name":"results","type":"bytes[]"}]},
+	{"type":"function","name":"aggregate3","stateMutability":"payable","inputs":[{"name":"calls","type":"tuple[]","components":[{"name":"target","type":"address"},{"name":"allowFailure","type":"bool"},{"name":"callData","type":"bytes"}]}],"outputs":[]}
+]`
+
+func TestDecode(t *testing.T) {
+	env := newTestEnv(t)
+	dead := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	tokenAbi := env.tokenABI()
+	multicall, err := abi.JSON(strings.NewReader(multicallABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	abis := map[string]abi.ABI{"Multicall.abi": multicall}
+	for name, a := range env.abis {
+		abis[name] = a
+	}
+	log.SetFormat(log.FormatJSON)
+	defer log.SetFormat(log.FormatText)
+
+	decode := func(input string) []record {
+		t.Helper()
+		out := captureStdout(t, func() {
+			if err = decodeInput(context.Background(), env.backend.Client(), abis, input); err != nil {
+				t.Errorf("failed to decode %s: %v", input, err)
+			}
+		})
+		return parseRecords(out)
+	}
+
+	t.Run("tx hash", func(t *testing.T) {
+		opts, _ := bind.NewKeyedTransactorWithChainID(env.key, big.NewInt(simulatedChainId))
+		token := bind.NewBoundContract(env.token, tokenAbi, env.backend.Client(), env.backend.Client(), env.backend.Client())
+		tx, err := token.Transact(opts, "transfer", dead, big.NewInt(1_000_000))
+		if err != nil {
+			t.Fatal(err)
+		}
+		env.backend.Commit()
+
+		records := decode(tx.Hash().Hex())
+		if info := findRecord(t, records, "transaction"); info["from"] != env.from.Hex() || info["to"] != env.token.Hex() {
+			t.Errorf("unexpected transaction: %v", info)
+		}
+		call := findRecord(t, records, "decoded_call")
+		args := call["args"].([]interface{})
+		if call["signature"] != "transfer(address,uint256)" || args[0].(map[string]interface{})["value"] != dead.Hex() {
+			t.Errorf("unexpected call: %v", call)
+		}
+	})
+
+	t.Run("nested calls", func(t *testing.T) {
+		transfer, _ := tokenAbi.Pack("transfer", dead, big.NewInt(1))
+		symbol, _ := tokenAbi.Pack("symbol")
+		inner, err := multicall.Pack("multicall", [][]byte{transfer, symbol})
+		if err != nil {
+			t.Fatal(err)
+		}
+		type call3 struct {
+			Target       common.Address
+			AllowFailure bool
+			CallData     []byte
+		}
+		data, err := multicall.Pack("aggregate3", []call3{{Target: env.token, CallData: inner}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		call := findRecord(t, decode(hexutil.Encode(data)), "decoded_call")
+		if call["method"] != "aggregate3" {
+			t.Fatalf("unexpected call: %v", call)
+		}
+		outer := call["calls"].([]interface{})[0].(map[string]interface{})
+		if outer["path"] != "calls[0].callData" || outer["method"] != "multicall" {
+			t.Fatalf("unexpected nested call: %v", outer)
+		}
+		nested := outer["calls"].([]interface{})
+		if len(nested) != 2 || nested[0].(map[string]interface{})["path"] != "data[0]" || nested[1].(map[string]interface{})["method"] != "symbol" {
+			t.Errorf("unexpected calls of the multicall: %v", nested)
+		}
+	})
+}
+
+func TestBuildCalldata(t *testing.T) {
+	env := newTestEnv(t)
+	dead := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	want, err := env.tokenABI().Pack("transfer", dead, big.NewInt(1_500_000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := env.run(t, env.ctx(simulatedChainId),
+		"TetherToken",
+		env.token.Hex(),
+		"",
+		// no private key is needed to build the calldata
+		"Build calldata",
+		"transfer",
+		dead.Hex(),
+		"1500000",
+		"calldata",
+		string(step.StepSelectMethod),
+		"Build calldata",
+		"transfer",
+		dead.Hex(),
+		"1500000",
+		"json transaction",
+		string(step.StepExit),
+	)
+
+	built := findRecord(t, records, "calldata")
+	if built["calldata"] != hexutil.Encode(want) || built["selector"] != "0xa9059cbb" || built["to"] != env.token.Hex() {
+		t.Errorf("unexpected calldata: %v", built)
+	}
+	req := findRecord(t, records, "tx_request")
+	if req["data"] != hexutil.Encode(want) || req["to"] != env.token.Hex() || req["value"] != "0x0" || req["chainId"] != "0x539" {
+		t.Errorf("unexpected transaction request: %v", req)
+	}
+	for _, r := range records {
+		if r.Type == "tx_sent" {
+			t.Fatalf("transaction was sent: %v", r)
+		}
+	}
+}
+
+func TestSafeBatch(t *testing.T) {
+	env := newTestEnv(t)
+	dead := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	safeAddress := common.HexToAddress("0x00000000000000000000000000000000000005aF")
+	out := filepath.Join(t.TempDir(), "batch.json")
+
+	env.run(t, env.ctx(simulatedChainId),
+		"TetherToken",
+		env.token.Hex(),
+		"",
+		// no private key is needed to add calls to the batch
+		"Add to Safe batch",
+		"transfer",
+		dead.Hex(),
+		"1500000",
+		string(step.StepSelectMethod),
+		"Add to Safe batch",
+		"approve",
+		dead.Hex(),
+		"42",
+		string(step.StepSafeBatch),
+		string(prompt.BatchActionList),
+		string(prompt.BatchActionExport),
+		safeAddress.Hex(),
+		out,
+		string(prompt.BatchActionBack),
+		string(step.StepExit),
+	)
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var batch struct {
+		ChainId string `json:"chainId"`
+		Meta    struct {
+			CreatedFromSafeAddress string `json:"createdFromSafeAddress"`
+		} `json:"meta"`
+		Transactions []struct {
+			To             string `json:"to"`
+			Value          string `json:"value"`
+			ContractMethod struct {
+				Name   string `json:"name"`
+				Inputs []struct {
+					Name string `json:"name"`
+					Type string `json:"type"`
+				} `json:"inputs"`
+			} `json:"contractMethod"`
+			ContractInputsValues map[string]string `json:"contractInputsValues"`
+		} `json:"transactions"`
+	}
+	if err = json.Unmarshal(data, &batch); err != nil {
+		t.Fatal(err)
+	}
+	if batch.ChainId != "1337" || batch.Meta.CreatedFromSafeAddress != safeAddress.Hex() || len(batch.Transactions) != 2 {
+		t.Fatalf("unexpected batch: %s", data)
+	}
+	transfer := batch.Transactions[0]
+	if transfer.To != env.token.Hex() || transfer.Value != "0" || transfer.ContractMethod.Name != "transfer" || len(transfer.ContractMethod.Inputs) != 2 {
+		t.Errorf("unexpected transfer: %+v", transfer)
+	}
+	if to := transfer.ContractMethod.Inputs[0].Name; transfer.ContractInputsValues[to] != dead.Hex() {
+		t.Errorf("unexpected transfer inputs: %v", transfer.ContractInputsValues)
+	}
+	if amount := transfer.ContractMethod.Inputs[1].Name; transfer.ContractInputsValues[amount] != "1500000" {
+		t.Errorf("unexpected transfer inputs: %v", transfer.ContractInputsValues)
+	}
+	if approve := batch.Transactions[1]; approve.ContractMethod.Name != "approve" {
+		t.Errorf("unexpected approve: %+v", approve)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	env := newTestEnv(t)
+	dead := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+
+	records := env.run(t, env.ctx(simulatedChainId),
+		"TetherToken",
+		env.token.Hex(),
+		"",
+		"Write",
+		env.privateKeyHex(),
+		"transfer",
+		dead.Hex(),
+		"1500000",
+		"y",
+		string(step.StepHistory),
+		string(prompt.HistoryActionList),
+		"transfer",
+		string(prompt.HistoryActionCheck),
+		// items start with the tx hash, the only one of the history
+		"0x",
+		string(prompt.HistoryActionBack),
+		string(step.StepExit),
+	)
+
+	sent := findRecord(t, records, "tx_sent")
+	entries, err := history.Read(HistoryPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("history has %d entries, want 1", len(entries))
+	}
+	e := entries[0]
+	if e.Hash != sent["hash"] || e.ChainId != simulatedChainId || e.From != env.from.Hex() || e.To != env.token.Hex() ||
+		e.Contract != "TetherToken" || e.Method != "transfer" || e.Status != history.StatusSuccess || e.GasUsed == 0 || e.Timestamp == 0 {
+		t.Errorf("unexpected history entry: %+v", e)
+	}
+	if len(e.Args) != 2 || e.Args[0].Value != dead.Hex() || e.Args[1].Value != "1500000" {
+		t.Errorf("unexpected history args: %+v", e.Args)
+	}
+	var listed int
+	for _, r := range records {
+		if r.Type == "history_entry" {
+			listed++
+			if r.Data["hash"] != e.Hash || r.Data["status"] != string(history.StatusSuccess) {
+				t.Errorf("unexpected history record: %v", r)
+			}
+		}
+	}
+	// listed once, then printed again when its receipt is checked
+	if listed != 2 {
+		t.Errorf("got %d history records, want 2", listed)
+	}
+
+	// the command filters the journal
+	records = runScript(t, func() error { return runHistoryCommand([]string{"--method", "transfer", "--status", "success"}) })
+	if r := findRecord(t, records, "history_entry"); r["hash"] != e.Hash {
+		t.Errorf("unexpected history record: %v", r)
+	}
+	records = runScript(t, func() error { return runHistoryCommand([]string{"list", "--status", "pending"}) })
+	for _, r := range records {
+		if r.Type == "history_entry" {
+			t.Errorf("unexpected pending transaction: %v", r)
+		}
+	}
+}
+
+func TestJSONOutputOnly(t *testing.T) {
+	env := newTestEnv(t)
+
+	sctx := env.ctx(simulatedChainId)
+	// nothing to remove or check yet, the messages are json records too
+	out := runScriptOutput(t, func() error {
+		manageAddressBook(sctx, env.abis)
+		return nil
+	}, string(prompt.BookActionRemove), string(prompt.BookActionBack))
+	out += runScriptOutput(t, func() error {
+		return newSession(sctx, env.abis).machine().Run(step.StepHistory)
+	},
+		string(prompt.HistoryActionCheck),
+		string(prompt.HistoryActionBack),
+		string(step.StepChangeContract),
+		"TetherToken",
+		env.token.Hex(),
+		"",
+		"Read",
+		"balanceOf",
+		env.from.Hex(),
+		string(step.StepExit),
+	)
+
+	var records int
+	for _, line := range strings.Split(out, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Errorf("stdout line is not json: %q", line)
+		}
+		records++
+	}
+	if records == 0 {
+		t.Error("no json records")
+	}
+}
+
+func TestRepeatAndEditCalls(t *testing.T) {
+	env := newTestEnv(t)
+	dead := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+
+	records := env.run(t, env.ctx(simulatedChainId),
+		"TetherToken",
+		env.token.Hex(),
+		"",
+		"Read",
+		"balanceOf",
+		env.from.Hex(),
+		string(step.StepRepeatCall),
+		string(step.StepSelectMethod),
+		"Write",
+		env.privateKeyHex(),
+		"transfer",
+		dead.Hex(),
+		"1500000",
+		"y",
+		// the same transfer again
+		string(step.StepRepeatCall),
+		"y",
+		// keep the recipient, change the amount
+		string(step.StepEditCall),
+		"transfer",
+		"",
+		"500000",
+		"y",
+		// a declined call isn't recorded
+		string(step.StepEditCall),
+		"transfer("+dead.Hex()+", 500000)",
+		"",
+		"999",
+		"n",
+		"Read",
+		"balanceOf",
+		dead.Hex(),
+		string(step.StepExit),
+	)
+
+	var results, sent int
+	for _, r := range records {
+		switch r.Type {
+		case "call_result":
+			results++
+		case "tx_sent":
+			sent++
+		}
+	}
+	if results != 3 || sent != 3 {
+		t.Errorf("got %d call results and %d sent transactions, want 3 and 3", results, sent)
+	}
+	if got := env.balanceOf(t, dead); got.Cmp(big.NewInt(3_500_000)) != 0 {
+		t.Errorf("balance of recipient = %v, want 3500000", got)
+	}
+
+	// identical calls are recorded once, the most recent first
+	calls, err := history.ReadRecentCalls(RecentCallsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recent := calls.Of(simulatedChainId, env.token)
+	if len(recent) != 4 || recent[0].Method != "balanceOf" || recent[1].Method != "transfer" || recent[1].Args[1] != "500000" ||
+		recent[2].Args[1] != "1500000" || recent[3].Method != "balanceOf" {
+		t.Errorf("unexpected recent calls: %+v", recent)
+	}
+}
+
+// sendPending sends a transfer of the token which stays pending until the next block, and records it in the history
+func (e *testEnv) sendPending(t *testing.T, to common.Address, amount int64) history.Entry {
+	t.Helper()
+	cli := e.backend.Client()
+	input, err := e.tokenABI().Pack("transfer", to, big.NewInt(amount))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce, err := cli.PendingNonceAt(context.Background(), e.from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gasPrice, err := cli.SuggestGasPrice(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := types.SignTx(types.NewTx(&types.LegacyTx{Nonce: nonce, GasPrice: gasPrice, Gas: 100_000, To: &e.token, Data: input}), types.LatestSignerForChainID(big.NewInt(simulatedChainId)), e.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = cli.SendTransaction(context.Background(), tx); err != nil {
+		t.Fatal(err)
+	}
+	return recordSent(simulatedChainId, tx, e.from, "TetherToken", "transfer", nil)
+}
+
+func TestSpeedUpAndCancel(t *testing.T) {
+	env := newTestEnv(t)
+	dead := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	// the replacements are mined when they are sent
+	sctx := env.ctx(simulatedChainId)
+	sctx.SetPrivateKey(env.key)
+	runHistory := func(lines ...string) []record {
+		return runScript(t, func() error {
+			return newSession(sctx, env.abis).machine().Run(step.StepHistory)
+		}, append(lines, string(prompt.HistoryActionBack), string(step.StepExit))...)
+	}
+
+	slow := env.sendPending(t, dead, 1_500_000)
+	records := runHistory(string(prompt.HistoryActionSpeedUp), slow.Hash, "y")
+	findRecord(t, records, "receipt")
+
+	stuck := env.sendPending(t, dead, 700_000)
+	records = runHistory(string(prompt.HistoryActionCancel), stuck.Hash, "y")
+	findRecord(t, records, "receipt")
+
+	if got := env.balanceOf(t, dead); got.Cmp(big.NewInt(1_500_000)) != 0 {
+		t.Errorf("balance of recipient = %v, want 1500000", got)
+	}
+	entries, err := history.Read(HistoryPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("history has %d entries, want 4: %+v", len(entries), entries)
+	}
+	speedUp, cancel := entries[1], entries[3]
+	if entries[0].Status != history.StatusReplaced || speedUp.Replaces != slow.Hash || speedUp.Status != history.StatusSuccess ||
+		speedUp.Nonce != slow.Nonce || speedUp.Method != "transfer" {
+		t.Errorf("unexpected speed up: %+v, replaced: %+v", speedUp, entries[0])
+	}
+	if entries[2].Status != history.StatusReplaced || cancel.Replaces != stuck.Hash || cancel.Status != history.StatusSuccess ||
+		cancel.Nonce != stuck.Nonce || cancel.To != env.from.Hex() || cancel.Method != "" {
+		t.Errorf("unexpected cancel: %+v, replaced: %+v", cancel, entries[2])
+	}
+
+	// the nonce set to replace a pending transaction isn't used again once it's replaced from the history
+	pending := env.sendPending(t, dead, 1)
+	s := newSession(sctx, env.abis)
+	runScript(t, func() error { return s.machine().Run(step.StepSetNonce) },
+		fmt.Sprint(pending.Nonce),
+		string(step.StepHistory),
+		string(prompt.HistoryActionSpeedUp),
+		pending.Hash,
+		"y",
+		string(prompt.HistoryActionBack),
+		string(step.StepExit),
+	)
+	if n, err := s.nonces.Next(context.Background(), env.backend.Client(), simulatedChainId, env.from); err != nil || n != pending.Nonce+1 {
+		t.Errorf("next nonce after the replacement = %d (%v), want %d", n, err, pending.Nonce+1)
+	}
+}
+
+// laggingClient doesn't mine the sent transactions and reports the confirmed nonce as the pending one,
+// like load balanced endpoints whose nodes haven't seen the last transactions yet
+type laggingClient struct {
+	simulated.Client
+}
+
+func (c laggingClient) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return c.Client.NonceAt(ctx, account, nil)
+}
+
+func TestQueuedWritesAndNonceOverride(t *testing.T) {
+	env := newTestEnv(t)
+	dead := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	sctx := ctx.NewCtx(&config.Profile{Name: "test", ChainId: simulatedChainId, WaitTime: "0s"}, ChainInfos)
+	sctx.SetEthClient(laggingClient{Client: env.backend.Client()})
+
+	// the writes are sent back to back before any is mined
+	records := env.run(t, sctx,
+		"TetherToken",
+		env.token.Hex(),
+		"",
+		"Write without waiting",
+		env.privateKeyHex(),
+		"transfer",
+		dead.Hex(),
+		"100",
+		"y",
+		string(step.StepSelectMethod),
+		"Write without waiting",
+		"transfer",
+		dead.Hex(),
+		"200",
+		"y",
+		string(step.StepExit),
+	)
+	var nonces []interface{}
+	for _, r := range records {
+		if r.Type == "tx_sent" {
+			nonces = append(nonces, r.Data["nonce"])
+		}
+	}
+	if len(nonces) != 2 || nonces[0] != float64(1) || nonces[1] != float64(2) {
+		t.Fatalf("unexpected nonces of the queued writes: %v", nonces)
+	}
+	// the call is saved as a write queued without waiting
+	calls, err := history.ReadRecentCalls(RecentCallsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recent := calls.Of(simulatedChainId, env.token); len(recent) != 2 || recent[0].Kind != internalabi.WriteMethod || recent[0].Action != step.ActionQueue {
+		t.Errorf("unexpected recent calls: %+v", recent)
+	}
+	env.backend.Commit()
+	if got := env.balanceOf(t, dead); got.Cmp(big.NewInt(300)) != 0 {
+		t.Errorf("balance of recipient = %v, want 300", got)
+	}
+
+	// the nonce set by the user is used by the next write
+	records = runScript(t, func() error {
+		return newSession(sctx, env.abis).machine().Run(step.StepSetNonce)
+	},
+		"7",
+		string(step.StepChangeContract),
+		"TetherToken",
+		env.token.Hex(),
+		"Write without waiting",
+		"transfer",
+		dead.Hex(),
+		"1",
+		"y",
+		string(step.StepExit),
+	)
+	if sent := findRecord(t, records, "tx_sent"); sent["nonce"] != float64(7) {
+		t.Errorf("unexpected nonce: %v", sent)
+	}
+}
+
+func TestOfflineSignAndBroadcast(t *testing.T) {
+	env := newTestEnv(t)
+	dead := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	for _, o := range []config.Override{
+		{Key: "chain_id", Value: "1337", Source: "test"},
+		{Key: "private_key", Value: env.privateKeyHex(), Source: "test"},
+	} {
+		if err := Conf.Apply(o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := filepath.Join(t.TempDir(), "transfer.tx")
+
+	// the deployment used nonce 0, the gas price is asked
+	records := runScript(t, func() error {
+		return runSignCommand([]string{"--nonce", "1", "--gas-limit", "100000", "--out", out})
+	},
+		"TetherToken",
+		env.token.Hex(),
+		"transfer",
+		dead.Hex(),
+		"1500000",
+		"10000000000",
+		"y",
+	)
+	signed := findRecord(t, records, "signed_tx")
+	if signed["from"] != env.from.Hex() || signed["file"] != out {
+		t.Errorf("unexpected signed tx: %v", signed)
+	}
+	if got := env.balanceOf(t, dead); got.Sign() != 0 {
+		t.Fatalf("transaction was sent while signing, balance of recipient = %v", got)
+	}
+
+	raw, err := readRawTx(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli := autoMiningClient{Client: env.backend.Client(), backend: env.backend}
+	records = runScript(t, func() error {
+		return broadcast(context.Background(), cli, env.abis, raw, true)
+	})
+	if sent := findRecord(t, records, "tx_sent"); sent["hash"] != signed["hash"] || sent["method"] != "transfer" {
+		t.Errorf("unexpected tx_sent: %v", sent)
+	}
+	if receipt := findRecord(t, records, "receipt"); receipt["status"] != "0x1" {
+		t.Errorf("transaction failed: %v", receipt)
+	}
+	if got := env.balanceOf(t, dead); got.Cmp(big.NewInt(1_500_000)) != 0 {
+		t.Errorf("balance of recipient = %v, want 1500000", got)
+	}
+}
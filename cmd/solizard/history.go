@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	internalabi "github.com/zsystm/solizard/internal/abi"
+	"github.com/zsystm/solizard/internal/client"
+	"github.com/zsystm/solizard/internal/ctx"
+	"github.com/zsystm/solizard/internal/history"
+	"github.com/zsystm/solizard/internal/log"
+	"github.com/zsystm/solizard/internal/prompt"
+	"github.com/zsystm/solizard/internal/step"
+)
+
+const historyUsage = `usage:
+  solizard history [list] [--chain <id>] [--contract <name>] [--method <name>] [--address <address>] [--status pending|success|failed|replaced] [--limit <n>]
+  solizard [--profile <name>] [--rpc-url <url>] history check [<tx hash>]
+  solizard [--profile <name>] [--rpc-url <url>] history speed-up|cancel <tx hash>`
+
+// DefaultHistoryLimit is the number of most recent transactions listed by default
+const DefaultHistoryLimit = 20
+
+func runHistoryCommand(args []string) error {
+	sub := "list"
+	if len(args) > 0 && (args[0] == "list" || args[0] == "check" || args[0] == "speed-up" || args[0] == "cancel") {
+		sub, args = args[0], args[1:]
+	}
+	fs := newFlagSet("history " + sub)
+	switch sub {
+	case "check", "speed-up", "cancel":
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() > 1 {
+			return fmt.Errorf("too many arguments\n%s", historyUsage)
+		}
+		if sub != "check" && fs.NArg() != 1 {
+			return fmt.Errorf("missing tx hash\n%s", historyUsage)
+		}
+		name := commandProfileName()
+		p, err := Conf.Profile(name)
+		if err != nil {
+			return err
+		}
+		sctx := ctx.NewCtx(p, ChainInfos)
+		if sctx.EthClient() == nil {
+			return fmt.Errorf("no rpc connection, check the rpc url of profile %s", name)
+		}
+		// Ctrl-C stops waiting for the receipts
+		reqCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		if sub == "check" {
+			return checkHistory(reqCtx, sctx.EthClient(), fs.Arg(0))
+		}
+		e, err := historyEntry(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		key, err := profileSigner(p)
+		if err != nil {
+			return err
+		}
+		return replaceTx(reqCtx, sctx.EthClient(), nil, key, e, sub == "cancel")
+	default:
+		var f history.Filter
+		fs.Uint64Var(&f.ChainId, "chain", 0, "only list transactions of the chain id")
+		fs.StringVar(&f.Contract, "contract", "", "only list calls of the contract")
+		fs.StringVar(&f.Method, "method", "", "only list calls of the method")
+		fs.StringVar(&f.Address, "address", "", "only list transactions from or to the address")
+		status := fs.String("status", "", "only list transactions with the status (pending, success, failed or replaced)")
+		limit := fs.Int("limit", DefaultHistoryLimit, "number of most recent transactions listed, 0 lists all")
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() > 0 {
+			return fmt.Errorf("unexpected argument %q\n%s", fs.Arg(0), historyUsage)
+		}
+		switch f.Status = history.Status(*status); f.Status {
+		case "", history.StatusPending, history.StatusSuccess, history.StatusFailed, history.StatusReplaced:
+		default:
+			return fmt.Errorf("invalid status %q, expected pending, success, failed or replaced", *status)
+		}
+		return listHistory(f, *limit)
+	}
+}
+
+// listHistory prints the most recent transactions of the journal matching the filter
+func listHistory(f history.Filter, limit int) error {
+	entries, err := history.Read(HistoryPath)
+	if err != nil {
+		return fmt.Errorf("failed to read history (reason: %v)", err)
+	}
+	selected := history.Select(entries, f)
+	if len(selected) == 0 {
+		log.Info("no transactions in the history\n")
+		return nil
+	}
+	if limit > 0 && len(selected) > limit {
+		log.Info(fmt.Sprintf("showing the %d most recent of %d transactions\n", limit, len(selected)))
+		selected = selected[:limit]
+	}
+	for _, e := range selected {
+		printHistoryEntry(e)
+	}
+	return nil
+}
+
+func printHistoryEntry(e history.Entry) {
+	log.Result("history_entry", fmt.Sprintf("%s %s %s\n", formatTime(uint64(e.Timestamp)), chainLabel(e.ChainId), e), e)
+}
+
+// checkHistory fetches the receipts of the pending transactions on the chain of the client,
+// or of the transaction with the hash, and updates the journal
+func checkHistory(reqCtx context.Context, cli client.EthClient, hash string) error {
+	chainId, err := cli.ChainID(reqCtx)
+	if err != nil {
+		return fmt.Errorf("failed to get chain id from the node (reason: %v)", err)
+	}
+	entries, err := history.Read(HistoryPath)
+	if err != nil {
+		return fmt.Errorf("failed to read history (reason: %v)", err)
+	}
+	f := history.Filter{ChainId: chainId.Uint64(), Status: history.StatusPending}
+	if hash != "" {
+		f = history.Filter{ChainId: chainId.Uint64()}
+	}
+	var checked []history.Entry
+	for _, e := range history.Select(entries, f) {
+		if hash != "" && common.HexToHash(e.Hash) != common.HexToHash(hash) {
+			continue
+		}
+		if err = e.Refresh(reqCtx, cli); err != nil {
+			return fmt.Errorf("failed to get receipt of %s (reason: %w)", e.Hash, err)
+		}
+		if e.Status == history.StatusPending {
+			// the nonce of a transaction without receipt is used by another one if it's confirmed
+			confirmed, err := cli.NonceAt(reqCtx, common.HexToAddress(e.From), nil)
+			if err != nil {
+				return fmt.Errorf("failed to get nonce of %s (reason: %w)", e.From, err)
+			}
+			if confirmed > e.Nonce {
+				e.Status = history.StatusReplaced
+			}
+		}
+		printHistoryEntry(e)
+		checked = append(checked, e)
+	}
+	if len(checked) == 0 {
+		if hash != "" {
+			return fmt.Errorf("transaction %s is not in the history of chain id %d", hash, chainId)
+		}
+		log.Info(fmt.Sprintf("no pending transactions on chain id %d\n", chainId))
+		return nil
+	}
+	if err = history.Update(HistoryPath, checked...); err != nil {
+		return fmt.Errorf("failed to update history (reason: %v)", err)
+	}
+	return nil
+}
+
+// historyEntry returns the entry of the transaction in the history
+func historyEntry(hash string) (history.Entry, error) {
+	entries, err := history.Read(HistoryPath)
+	if err != nil {
+		return history.Entry{}, fmt.Errorf("failed to read history (reason: %v)", err)
+	}
+	for _, e := range entries {
+		if common.HexToHash(e.Hash) == common.HexToHash(hash) {
+			return e, nil
+		}
+	}
+	return history.Entry{}, fmt.Errorf("transaction %s is not in the history", hash)
+}
+
+// recordSent adds the sent transaction to the journal, failing to write it doesn't fail the transaction
+func recordSent(chainId uint64, tx *types.Transaction, from common.Address, contract, method string, args []internalabi.NamedValue) history.Entry {
+	return recordEntry(history.NewEntry(chainId, tx, from, contract, method, args))
+}
+
+func recordEntry(e history.Entry) history.Entry {
+	if err := history.Append(HistoryPath, e); err != nil {
+		log.Error(fmt.Sprintf("failed to record the transaction in the history (reason: %v)\n", err))
+	}
+	return e
+}
+
+// recordReceipt updates the journal entry of the mined transaction
+func recordReceipt(e history.Entry, receipt *types.Receipt) {
+	e.SetReceipt(receipt)
+	if err := history.Update(HistoryPath, e); err != nil {
+		log.Error(fmt.Sprintf("failed to update the history (reason: %v)\n", err))
+	}
+}
+
+// browseHistory lists the transactions sent on the chain of the session, re-checks their receipts,
+// or speeds up or cancels the pending ones
+func (s *session) browseHistory(opCtx context.Context) (step.Step, error) {
+	chainId := s.sctx.ChainId().Uint64()
+	for {
+		var err error
+		switch action := prompt.MustSelectHistoryAction(chainId); action {
+		case prompt.HistoryActionList:
+			err = listHistory(history.Filter{ChainId: chainId, Text: prompt.MustInputHistoryFilter()}, DefaultHistoryLimit)
+		case prompt.HistoryActionCheck:
+			var entries []history.Entry
+			if entries, err = history.Read(HistoryPath); err != nil {
+				break
+			}
+			if e := prompt.MustSelectHistoryEntry(history.Select(entries, history.Filter{ChainId: chainId})); e != nil {
+				err = checkHistory(opCtx, s.sctx.EthClient(), e.Hash)
+			}
+		case prompt.HistoryActionSpeedUp, prompt.HistoryActionCancel:
+			var entries []history.Entry
+			if entries, err = history.Read(HistoryPath); err != nil {
+				break
+			}
+			e := prompt.MustSelectHistoryEntry(history.Select(entries, history.Filter{ChainId: chainId, Status: history.StatusPending}))
+			if e != nil && s.ensureSigner() {
+				err = replaceTx(opCtx, s.sctx.EthClient(), s.nonces, s.sctx.PrivateKey(), *e, action == prompt.HistoryActionCancel)
+			}
+		case prompt.HistoryActionBack:
+			return step.StepSelectStep, nil
+		}
+		if err != nil {
+			return failed("history action failed", err, step.StepHistory)
+		}
+	}
+}
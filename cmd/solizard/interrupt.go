@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// interrupter cancels the running operation when the user presses Ctrl-C.
+// Prompts read Ctrl-C themselves, so the signal is only received while an operation
+// (rpc requests, waiting for a receipt) is running or when there's nothing to cancel.
+type interrupter struct {
+	mu     sync.Mutex
+	root   context.Context
+	cancel context.CancelFunc
+}
+
+// interrupts cancels the operations of the interactive mode
+var interrupts = newInterrupter(context.Background())
+
+func newInterrupter(root context.Context) *interrupter {
+	return &interrupter{root: root}
+}
+
+// operation returns the context of a new operation, which is cancelled by interrupt.
+// done must be called when the operation finishes.
+func (i *interrupter) operation() (ctx context.Context, done func()) {
+	ctx, cancel := context.WithCancel(i.root)
+	i.mu.Lock()
+	i.cancel = cancel
+	i.mu.Unlock()
+	return ctx, func() {
+		i.mu.Lock()
+		i.cancel = nil
+		i.mu.Unlock()
+		cancel()
+	}
+}
+
+// interrupt cancels the running operation and returns false if there was none,
+// so a second Ctrl-C exits
+func (i *interrupter) interrupt() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.cancel == nil {
+		return false
+	}
+	i.cancel()
+	i.cancel = nil
+	return true
+}
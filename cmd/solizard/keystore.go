@@ -0,0 +1,113 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/zsystm/solizard/internal/config"
+	"github.com/zsystm/solizard/internal/log"
+	"github.com/zsystm/solizard/internal/prompt"
+)
+
+// inputSigner unlocks the key of the keystore at path, or asks the user for the private key if path is empty
+func inputSigner(path string) (*ecdsa.PrivateKey, error) {
+	if path == "" {
+		return prompt.MustInputPrivateKey(), nil
+	}
+	pk, err := unlockKeystore(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unlock keystore %s (reason: %v)", path, err)
+	}
+	return pk, nil
+}
+
+// profileSigner returns the private key of the profile, or unlocks its keystore or asks the private key
+func profileSigner(p *config.Profile) (*ecdsa.PrivateKey, error) {
+	if p.PrivateKey != "" && p.Keystore == "" {
+		pk, err := crypto.HexToECDSA(p.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid private key of profile %s (reason: %v)", p.Name, err)
+		}
+		return pk, nil
+	}
+	return inputSigner(p.Keystore)
+}
+
+// unlockKeystore decrypts the key of the keystore file with the password input by the user.
+// If path is a keystore directory, the user picks one of its accounts.
+func unlockKeystore(path string) (*ecdsa.PrivateKey, error) {
+	file, err := keystoreFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keystore file (reason: %v)", err)
+	}
+	for {
+		key, err := keystore.DecryptKey(data, prompt.MustInputPassword(filepath.Base(file)))
+		if err == nil {
+			return key.PrivateKey, nil
+		}
+		if !errors.Is(err, keystore.ErrDecrypt) {
+			return nil, fmt.Errorf("failed to decrypt keystore file (reason: %v)", err)
+		}
+		log.Error("wrong password, please try again\n")
+	}
+}
+
+// keystoreFile returns the keystore file of path, asking the user to pick an account if path is a directory
+func keystoreFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open keystore (reason: %v)", err)
+	}
+	if !info.IsDir() {
+		return path, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read keystore directory (reason: %v)", err)
+	}
+	var files, accounts []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		file := filepath.Join(path, e.Name())
+		if address, ok := keystoreAddress(file); ok {
+			files = append(files, file)
+			accounts = append(accounts, address)
+		}
+	}
+	switch len(files) {
+	case 0:
+		return "", fmt.Errorf("no keystore files in %s", path)
+	case 1:
+		return files[0], nil
+	}
+	return files[prompt.MustSelectAccount(accounts)], nil
+}
+
+// keystoreAddress returns the checksummed address of the keystore file, ok is false if it's not a keystore file
+func keystoreAddress(file string) (string, bool) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", false
+	}
+	var k struct {
+		Address string `json:"address"`
+	}
+	if err = json.Unmarshal(data, &k); err != nil || !common.IsHexAddress(k.Address) {
+		return "", false
+	}
+	return common.HexToAddress(k.Address).Hex(), true
+}
@@ -1,39 +1,187 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+
+	"github.com/zsystm/solizard/internal/config"
+	"github.com/zsystm/solizard/internal/log"
+	"github.com/zsystm/solizard/internal/prompt"
+)
+
+// exit codes of solizard
+const (
+	// exitOK is returned when the user exits or a command succeeds
+	exitOK = 0
+	// exitFatal is returned when solizard can't continue, e.g. the config or a command failed
+	exitFatal = 1
+	// exitUsage is returned for invalid flags or unknown commands
+	exitUsage = 2
+	// exitInterrupted is returned when the user interrupts solizard with Ctrl-C or Ctrl-D
+	exitInterrupted = 130
 )
 
 func main() {
+	output := flag.String("output", string(log.FormatText), "output format, one of text or json")
+	flag.StringVar(&ProfileName, "profile", "", "name of the network profile in config.toml to use, skips the profile picker (env: "+ProfileEnv+")")
+	script := flag.String("script", "", "file of answers to replay, the remaining questions are asked interactively")
+	record := flag.String("record", "", "file to record the answers to, replay it with --script")
+	home := flag.String("home", "", "solizard directory holding the config, the address book and the chain registry (env: "+HomeEnv+")")
+	// flags overriding config fields, keyed by toml key
+	configFlags := map[string]*string{
+		"rpc_url":  flag.String("rpc-url", "", "rpc url, overrides rpc_url of the config and the profile"),
+		"chain_id": flag.String("chain-id", "", "chain id, overrides chain_id of the config and the profile"),
+		"abi_dir":  flag.String("abi-dir", "", "directory of the abi files, overrides abi_dir of the config"),
+		"keystore": flag.String("keystore", "", "encrypted keystore file or directory to sign with, overrides keystore of the config"),
+	}
+	flag.Usage = printUsage
+	flag.Parse()
+	if ProfileName == "" {
+		ProfileName = os.Getenv(ProfileEnv)
+	}
+	format, err := log.ParseFormat(*output)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(exitUsage)
+	}
+	log.SetFormat(format)
+
+	dir, err := solizardDir(*home)
+	if err != nil {
+		log.Error(fmt.Sprintf("%v\n", err))
+		os.Exit(exitFatal)
+	}
+	// flags take precedence over environment variables
+	overrides := config.EnvOverrides(os.Environ())
+	for _, key := range config.Keys() {
+		if f, ok := configFlags[key]; ok && isFlagSet(flag.CommandLine, flagName(key)) {
+			overrides = append(overrides, config.Override{Key: key, Value: *f, Source: "flag --" + flagName(key)})
+		}
+	}
+	if err = setup(dir, overrides...); err != nil {
+		log.Error(fmt.Sprintf("%v\n", err))
+		if errors.Is(err, errInvalidOverride) {
+			os.Exit(exitUsage)
+		}
+		os.Exit(exitFatal)
+	}
+
+	// commands like sign ask for their missing arguments, so they use the prompter too
+	if err = setupPrompter(*script, *record); err != nil {
+		log.Error(fmt.Sprintf("%v\n", err))
+		os.Exit(exitFatal)
+	}
+
+	// run a command instead of the interactive shell
+	if flag.NArg() > 0 {
+		os.Exit(runCommandExitCode(flag.Args()))
+	}
+
 	// create signal channel for handling program termination
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
-	done := make(chan bool, 1)
+	exitCode := make(chan int, 1)
 
-	// catch signals and handle program termination
+	// catch signals and handle program termination,
+	// the first Ctrl-C cancels the running rpc requests and returns to the step picker
 	go func() {
-		sig := <-sigs
-		fmt.Printf("terminating program... (reason: %v)\n", sig)
-		done <- true
+		for sig := range sigs {
+			if sig == syscall.SIGINT && interrupts.interrupt() {
+				continue
+			}
+			printTermination(sig)
+			exitCode <- exitInterrupted
+			return
+		}
 	}()
-	// run the main program
+	// run the main program, recoverable errors are handled by the steps,
+	// so it only ends when the user exits or on fatal errors
 	go func() {
 		defer func() {
 			if r := recover(); r != nil {
-				fmt.Printf("terminating program... (reason: %v)\n", r)
-				done <- true
+				printTermination(r)
+				exitCode <- panicExitCode(r)
 			}
 		}()
-		err := Run()
-		if err != nil {
-			fmt.Printf("terminating program... (reason: %v)\n", err)
-			done <- true
+		if err := Run(); err != nil {
+			printTermination(err)
+			exitCode <- exitFatal
+			return
 		}
+		printTermination("exit")
+		exitCode <- exitOK
 	}()
 
-	<-done
-	fmt.Println("terminated")
+	code := <-exitCode
+	if !log.IsJSON() {
+		fmt.Println("terminated")
+	}
+	os.Exit(code)
+}
+
+// flagName returns the flag of the config field with the toml key
+func flagName(key string) string {
+	return strings.ReplaceAll(key, "_", "-")
+}
+
+// runCommandExitCode runs the command and returns the exit code,
+// prompts of interactive commands panic when the user interrupts them
+func runCommandExitCode(args []string) (code int) {
+	defer func() {
+		if r := recover(); r != nil {
+			printTermination(r)
+			code = panicExitCode(r)
+		}
+	}()
+	if err := runCommand(args); err != nil {
+		log.Error(fmt.Sprintf("%v\n", err))
+		if errors.Is(err, errUnknownCommand) {
+			return exitUsage
+		}
+		return exitFatal
+	}
+	return exitOK
+}
+
+// panicExitCode returns the exit code of a panic, prompts panic when the user interrupts them
+func panicExitCode(r interface{}) int {
+	if err, ok := r.(error); ok && (errors.Is(err, prompt.ErrInterrupt) || errors.Is(err, prompt.ErrEOF)) {
+		return exitInterrupted
+	}
+	return exitFatal
+}
+
+func printTermination(reason interface{}) {
+	if log.IsJSON() {
+		log.Emit("terminated", map[string]string{"reason": fmt.Sprint(reason)})
+		return
+	}
+	fmt.Printf("terminating program... (reason: %v)\n", reason)
+}
+
+// setupPrompter replays the answers of the script file and records the answers to the record file
+func setupPrompter(script, record string) error {
+	var prompter prompt.Prompter = prompt.Promptui{}
+	if script != "" {
+		s, err := prompt.LoadScript(script, prompter)
+		if err != nil {
+			return fmt.Errorf("failed to read script (reason: %v)", err)
+		}
+		prompter = s
+	}
+	if record != "" {
+		// the file is closed when the program exits
+		f, err := os.Create(record)
+		if err != nil {
+			return fmt.Errorf("failed to create record file (reason: %v)", err)
+		}
+		prompter = prompt.NewRecorder(prompter, f)
+	}
+	prompt.SetPrompter(prompter)
+	return nil
 }
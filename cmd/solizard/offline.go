@@ -0,0 +1,365 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pelletier/go-toml"
+
+	internalabi "github.com/zsystm/solizard/internal/abi"
+	"github.com/zsystm/solizard/internal/client"
+	"github.com/zsystm/solizard/internal/config"
+	"github.com/zsystm/solizard/internal/ctx"
+	"github.com/zsystm/solizard/internal/log"
+	"github.com/zsystm/solizard/internal/prompt"
+)
+
+// ReceiptTimeout is the time broadcast waits for the receipt of the transaction
+const ReceiptTimeout = 5 * time.Minute
+
+const signUsage = `usage:
+  solizard [--profile <name>] [--chain-id <id>] sign [--nonce <n>] [--gas-limit <n>] [--gas-price <wei> | --max-fee <wei> [--priority-fee <wei>]] [--out <file>]`
+
+const broadcastUsage = `usage:
+  solizard [--profile <name>] [--rpc-url <url>] broadcast [--no-wait] <raw tx | file>`
+
+// signedTx is the json output of an offline signed transaction
+type signedTx struct {
+	Hash    string `json:"hash"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	ChainId uint64 `json:"chainId"`
+	Nonce   uint64 `json:"nonce"`
+	Method  string `json:"method"`
+	Raw     string `json:"raw,omitempty"`
+	File    string `json:"file,omitempty"`
+}
+
+// signOptions are the transaction fields given as flags, the missing ones are asked
+type signOptions struct {
+	nonce       *uint64
+	gasLimit    *uint64
+	gasPrice    *big.Int
+	maxFee      *big.Int
+	priorityFee *big.Int
+	out         string
+}
+
+func runSignCommand(args []string) error {
+	fs := newFlagSet("sign")
+	nonce := fs.Uint64("nonce", 0, "nonce of the transaction (asked if not set)")
+	gasLimit := fs.Uint64("gas-limit", 0, "gas limit of the transaction (asked if not set)")
+	gasPrice := fs.String("gas-price", "", "gas price in wei of a legacy transaction (asked if no fee is set)")
+	maxFee := fs.String("max-fee", "", "max fee per gas in wei, signs a dynamic fee (EIP-1559) transaction")
+	priorityFee := fs.String("priority-fee", "0", "max priority fee per gas in wei of a dynamic fee transaction")
+	out := fs.String("out", "", "file to write the raw transaction to (default: stdout)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("unexpected arguments %v\n%s", fs.Args(), signUsage)
+	}
+
+	opts := signOptions{out: *out}
+	if isFlagSet(fs, "nonce") {
+		opts.nonce = nonce
+	}
+	if isFlagSet(fs, "gas-limit") {
+		opts.gasLimit = gasLimit
+	}
+	var err error
+	if *gasPrice != "" && *maxFee != "" {
+		return fmt.Errorf("--gas-price and --max-fee can't be used together\n%s", signUsage)
+	}
+	if opts.gasPrice, err = parseWeiFlag("gas-price", *gasPrice); err != nil {
+		return err
+	}
+	if opts.maxFee, err = parseWeiFlag("max-fee", *maxFee); err != nil {
+		return err
+	}
+	if opts.priorityFee, err = parseWeiFlag("priority-fee", *priorityFee); err != nil {
+		return err
+	}
+	if opts.maxFee != nil && opts.priorityFee.Cmp(opts.maxFee) > 0 {
+		return fmt.Errorf("--priority-fee %s is higher than --max-fee %s", opts.priorityFee, opts.maxFee)
+	}
+
+	p, err := Conf.Profile(commandProfileName())
+	if err != nil {
+		return err
+	}
+	abis, err := internalabi.LoadABIs(AbiDir)
+	if err != nil {
+		return err
+	}
+	return signOffline(p, abis, opts)
+}
+
+// commandProfileName returns the profile of the --profile flag, or the default profile
+func commandProfileName() string {
+	if ProfileName == "" {
+		return config.DefaultProfileName
+	}
+	return ProfileName
+}
+
+func parseWeiFlag(name, value string) (*big.Int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	wei, ok := new(big.Int).SetString(value, 10)
+	if !ok || wei.Sign() < 0 {
+		return nil, fmt.Errorf("invalid --%s %q, expected an amount in wei", name, value)
+	}
+	return wei, nil
+}
+
+// explicitSigner checks the profile signs with a private key or a keystore set by the user,
+// not with the dummy key of the bundled config.toml or the key generated without a config file
+func explicitSigner(p *config.Profile) error {
+	if p.Keystore != "" {
+		return nil
+	}
+	generated := Conf.Source("private_key") == config.SourceDefault && p.PrivateKey == Conf.PrivateKey
+	if p.PrivateKey == "" || p.PrivateKey == bundledPrivateKey() || generated {
+		return fmt.Errorf("no private key or keystore of profile %s to sign with, set private_key or keystore in config.toml, SOLIZARD_PRIVATE_KEY or --keystore", p.Name)
+	}
+	return nil
+}
+
+// bundledPrivateKey returns the dummy private key of the bundled config.toml
+func bundledPrivateKey() string {
+	var bundled struct {
+		PrivateKey string `toml:"private_key"`
+	}
+	if data, err := embeddedFiles.ReadFile("embeds/config.toml"); err == nil {
+		_ = toml.Unmarshal(data, &bundled)
+	}
+	return bundled.PrivateKey
+}
+
+// signOffline builds a transaction calling a write method without connecting to the network,
+// signs it and prints or writes the raw transaction
+func signOffline(p *config.Profile, abis map[string]abi.ABI, opts signOptions) error {
+	if p.ChainId == 0 {
+		return fmt.Errorf("the chain id of profile %s is not set, set it with --chain-id", p.Name)
+	}
+	if err := explicitSigner(p); err != nil {
+		return err
+	}
+	chainId := new(big.Int).SetUint64(p.ChainId)
+
+	contractName, contractAbi := prompt.MustSelectContractABI(abis)
+	var address string
+	if known := prompt.MustSelectContractAddress(AddressBook.Deployments(p.ChainId, contractName)); known != nil {
+		address = known.Address
+	} else {
+		address = prompt.MustInputContractAddress()
+	}
+	to := common.HexToAddress(address)
+	methodName, method := prompt.MustSelectMethod(contractAbi, internalabi.WriteMethod)
+	input, err := prompt.InputDataForMethod(method)
+	if err != nil {
+		return fmt.Errorf("invalid arguments for %s (reason: %v)", methodName, err)
+	}
+	value := common.Big0
+	if method.IsPayable() {
+		value = prompt.MustInputValue("")
+	}
+
+	var nonce, gasLimit uint64
+	if opts.nonce != nil {
+		nonce = *opts.nonce
+	} else {
+		nonce = prompt.MustInputUint("Enter the nonce of the sender", "")
+	}
+	if opts.gasLimit != nil {
+		gasLimit = *opts.gasLimit
+	} else {
+		gasLimit = prompt.MustInputUint("Enter the gas limit", strconv.FormatUint(p.Gas(), 10))
+	}
+	var unsignedTx *types.Transaction
+	if opts.maxFee != nil {
+		unsignedTx = types.NewTx(&types.DynamicFeeTx{
+			ChainID:   chainId,
+			Nonce:     nonce,
+			GasTipCap: opts.priorityFee,
+			GasFeeCap: opts.maxFee,
+			Gas:       gasLimit,
+			To:        &to,
+			Value:     value,
+			Data:      input,
+		})
+	} else {
+		gasPrice := opts.gasPrice
+		if gasPrice == nil {
+			defaultPrice := ""
+			if fixed := p.FixedGasPrice(); fixed != nil {
+				defaultPrice = fixed.String()
+			}
+			gasPrice = prompt.MustInputWei("Enter the gas price in wei", defaultPrice)
+		}
+		unsignedTx = types.NewTx(&types.LegacyTx{
+			Nonce:    nonce,
+			GasPrice: gasPrice,
+			Gas:      gasLimit,
+			To:       &to,
+			Value:    value,
+			Data:     input,
+		})
+	}
+
+	pk, err := profileSigner(p)
+	if err != nil {
+		return err
+	}
+	from := crypto.PubkeyToAddress(pk.PublicKey)
+
+	if !prompt.MustConfirm(fmt.Sprintf("Sign %s to %s (%s) from %s with nonce %d on %s?", methodName, contractName, to.Hex(), from.Hex(), nonce, chainLabel(p.ChainId))) {
+		log.Info("transaction is not signed\n")
+		return nil
+	}
+	tx, err := types.SignTx(unsignedTx, types.LatestSignerForChainID(chainId), pk)
+	if err != nil {
+		return fmt.Errorf("failed to sign transaction (reason: %v)", err)
+	}
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("failed to encode transaction (reason: %v)", err)
+	}
+
+	out := signedTx{Hash: tx.Hash().Hex(), From: from.Hex(), To: to.Hex(), ChainId: p.ChainId, Nonce: nonce, Method: method.Sig}
+	if opts.out != "" {
+		if err = os.WriteFile(opts.out, []byte(hexutil.Encode(raw)+"\n"), 0600); err != nil {
+			return fmt.Errorf("failed to write raw transaction (reason: %v)", err)
+		}
+		out.File = opts.out
+		log.Result("signed_tx", fmt.Sprintf("signed transaction %s written to %s, send it with `solizard broadcast %s`\n", out.Hash, opts.out, opts.out), out)
+		return nil
+	}
+	out.Raw = hexutil.Encode(raw)
+	log.Result("signed_tx", fmt.Sprintf("signed transaction %s, send it with `solizard broadcast <raw tx>`:\n%s\n", out.Hash, out.Raw), out)
+	return nil
+}
+
+func runBroadcastCommand(args []string) error {
+	fs := newFlagSet("broadcast")
+	noWait := fs.Bool("no-wait", false, "don't wait for the receipt")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("missing raw transaction\n%s", broadcastUsage)
+	}
+	raw, err := readRawTx(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	abis, err := internalabi.LoadABIs(AbiDir)
+	if err != nil {
+		return err
+	}
+
+	name := commandProfileName()
+	p, err := Conf.Profile(name)
+	if err != nil {
+		return err
+	}
+	sctx := ctx.NewCtx(p, ChainInfos)
+	if sctx.EthClient() == nil {
+		return fmt.Errorf("no rpc connection to broadcast the transaction, check the rpc url of profile %s", name)
+	}
+	// Ctrl-C stops waiting for the receipt
+	reqCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return broadcast(reqCtx, sctx.EthClient(), abis, raw, !*noWait)
+}
+
+// readRawTx returns the raw transaction given as hex or as a file holding the hex
+func readRawTx(arg string) ([]byte, error) {
+	text := arg
+	if data, err := os.ReadFile(arg); err == nil {
+		text = string(data)
+	}
+	raw, err := hexutil.Decode(strings.TrimSpace(text))
+	if err != nil {
+		return nil, fmt.Errorf("invalid raw transaction, expected 0x prefixed hex or a file holding it (reason: %v)", err)
+	}
+	return raw, nil
+}
+
+// broadcast sends the signed raw transaction and waits for its receipt if wait is true
+func broadcast(reqCtx context.Context, cli client.EthClient, abis map[string]abi.ABI, raw []byte, wait bool) error {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		return fmt.Errorf("failed to decode raw transaction (reason: %v)", err)
+	}
+	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return fmt.Errorf("invalid signature of the transaction (reason: %v)", err)
+	}
+	if tx.To() == nil {
+		return fmt.Errorf("contract creations are not supported")
+	}
+	chainId, err := cli.ChainID(reqCtx)
+	if err != nil {
+		return fmt.Errorf("failed to get chain id from the node (reason: %v)", err)
+	}
+	if chainId.Cmp(tx.ChainId()) != 0 {
+		return fmt.Errorf("the transaction is signed for chain id %d but the node serves chain id %d", tx.ChainId(), chainId)
+	}
+
+	sent := txSent{Hash: tx.Hash().Hex(), From: from.Hex(), To: tx.To().Hex(), Nonce: tx.Nonce()}
+	var args []internalabi.NamedValue
+	if calls, err := internalabi.DecodeCalldata(abis, tx.Data()); err == nil {
+		sent.Contract = strings.Join(calls[0].Contracts, ", ")
+		sent.Method = calls[0].Method
+		args = calls[0].Args
+		log.Result("decoded_call", calls[0].String(), calls[0].JSON())
+	}
+	if err = cli.SendTransaction(reqCtx, tx); err != nil {
+		return fmt.Errorf("failed to send transaction (reason: %v)", err)
+	}
+	entry := recordSent(chainId.Uint64(), tx, from, sent.Contract, sent.Method, args)
+	log.Result("tx_sent", fmt.Sprintf("transaction sent (txHash %v).\n", sent.Hash), sent)
+	if !wait {
+		return nil
+	}
+
+	log.Info("waiting for transaction to be mined... (Ctrl-C to stop waiting)\n")
+	waitCtx, cancel := context.WithTimeout(reqCtx, ReceiptTimeout)
+	defer cancel()
+	receipt, err := bind.WaitMined(waitCtx, cli, tx)
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			log.Error(fmt.Sprintf("stopped waiting for transaction %s, it may still be mined\n", sent.Hash))
+			return nil
+		}
+		return fmt.Errorf("failed to get transaction receipt (reason: %v)", err)
+	}
+	recordReceipt(entry, receipt)
+	jsonReceipt, _ := receipt.MarshalJSON()
+	log.Result("receipt", fmt.Sprintf("transaction receipt: %s\n", string(jsonReceipt)), receipt)
+	for _, l := range receipt.Logs {
+		for _, contractAbi := range abis {
+			if event, err := internalabi.DecodeLog(contractAbi, *l); err == nil {
+				log.Result("event", fmt.Sprintf("event: %s\n", event), event.JSON())
+				break
+			}
+		}
+	}
+	return nil
+}
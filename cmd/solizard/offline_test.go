@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zsystm/solizard/internal/config"
+)
+
+func TestSignFeeFlags(t *testing.T) {
+	if err := setup(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{name: "priority fee above the max fee", args: []string{"--max-fee", "100", "--priority-fee", "101"}, wantErr: "--priority-fee 101 is higher than --max-fee 100"},
+		{name: "gas price with max fee", args: []string{"--gas-price", "1", "--max-fee", "100"}, wantErr: "can't be used together"},
+		{name: "negative max fee", args: []string{"--max-fee", "-1"}, wantErr: "invalid --max-fee"},
+		// the fees are valid, the dummy key of the bundled config isn't used to sign
+		{name: "priority fee equal to the max fee", args: []string{"--max-fee", "100", "--priority-fee", "100"}, wantErr: "no private key or keystore"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := runSignCommand(tt.args); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("runSignCommand(%v) error = %v, want %q", tt.args, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignRequiresExplicitSigner(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    string
+		overrides []config.Override
+		wantErr   bool
+	}{
+		{name: "dummy key of the bundled config", wantErr: true},
+		{name: "generated key without private_key in the config file", config: "chain_id = 1\n", wantErr: true},
+		{
+			name:      "private key override",
+			overrides: []config.Override{{Key: "private_key", Value: strings.Repeat("ab", 32), Source: "env SOLIZARD_PRIVATE_KEY"}},
+		},
+		{
+			name:      "keystore",
+			overrides: []config.Override{{Key: "keystore", Value: "/keystore", Source: "flag --keystore"}},
+		},
+		{name: "private key of the config file", config: "chain_id = 1\nprivate_key = \"" + strings.Repeat("cd", 32) + "\"\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.config != "" {
+				if err := os.WriteFile(filepath.Join(dir, "config.toml"), []byte(tt.config), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := setup(dir, tt.overrides...); err != nil {
+				t.Fatal(err)
+			}
+			p, err := Conf.Profile(config.DefaultProfileName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = explicitSigner(p); (err != nil) != tt.wantErr {
+				t.Errorf("explicitSigner() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
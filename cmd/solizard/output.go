@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	internalabi "github.com/zsystm/solizard/internal/abi"
+	"github.com/zsystm/solizard/internal/config"
+)
+
+// callResult is the json output of a read method call
+type callResult struct {
+	Contract string                   `json:"contract"`
+	Address  string                   `json:"address"`
+	Method   string                   `json:"method"`
+	Outputs  []internalabi.NamedValue `json:"outputs"`
+}
+
+// txSent is the json output of a sent transaction
+type txSent struct {
+	Hash     string `json:"hash"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Contract string `json:"contract"`
+	Method   string `json:"method"`
+	Nonce    uint64 `json:"nonce"`
+}
+
+// builtCalldata is the json output of the calldata built without calling or sending it
+type builtCalldata struct {
+	Contract string `json:"contract"`
+	Method   string `json:"method"`
+	Selector string `json:"selector"`
+	To       string `json:"to"`
+	Calldata string `json:"calldata"`
+	Value    string `json:"value"`
+	ChainId  uint64 `json:"chainId"`
+}
+
+// txRequest is a transaction object as accepted by eth_sendTransaction and wallets, quantities are hex encoded
+type txRequest struct {
+	To      string `json:"to"`
+	Data    string `json:"data"`
+	Value   string `json:"value"`
+	ChainId string `json:"chainId"`
+}
+
+// bookEntry is the json output of an address book entry
+type bookEntry struct {
+	ChainId uint64 `json:"chainId"`
+	config.ContractInfo
+}
+
+// balanceOutput is the json output of a native balance
+type balanceOutput struct {
+	Address   string `json:"address"`
+	Wei       string `json:"wei"`
+	Formatted string `json:"formatted,omitempty"`
+}
+
+// formatNativeUnits returns the wei amount in the native currency of the chain,
+// or an empty string if token formatting is disabled or the chain is unknown.
+func formatNativeUnits(chainId uint64, wei *big.Int) string {
+	if !Conf.TokenFormat {
+		return ""
+	}
+	chainInfo, err := ChainInfos.GetChainInfoByID(chainId)
+	if err != nil {
+		return ""
+	}
+	return chainInfo.NativeCurrency.Format(wei)
+}
+
+// formatNative returns the wei amount followed by the formatted native amount if available,
+// e.g. "1500000000000000000 wei (1.5 ETH)"
+func formatNative(chainId uint64, wei *big.Int) string {
+	if formatted := formatNativeUnits(chainId, wei); formatted != "" {
+		return fmt.Sprintf("%s wei (%s)", wei, formatted)
+	}
+	return fmt.Sprintf("%s wei", wei)
+}
+
+// chainLabel returns the chain name from the chain registry with the chain id, e.g. "Ethereum Mainnet (eth, chain id: 1)"
+func chainLabel(chainId uint64) string {
+	chainInfo, err := ChainInfos.GetChainInfoByID(chainId)
+	if err != nil {
+		return fmt.Sprintf("unknown chain (chain id: %d)", chainId)
+	}
+	return chainInfo.String()
+}
+
+// formatTime returns the unix time as UTC date and time, or "-" if unknown
+func formatTime(unix uint64) string {
+	if unix == 0 {
+		return "-"
+	}
+	return time.Unix(int64(unix), 0).UTC().Format("2006-01-02 15:04:05 UTC")
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/zsystm/solizard/lib"
+)
+
+func TestChainLabel(t *testing.T) {
+	registry := ChainInfos
+	defer func() { ChainInfos = registry }()
+	ChainInfos = lib.NewChainRegistry([]*lib.ChainInfo{
+		{Name: "Ethereum Mainnet", ChainID: 1, ShortName: "eth", NativeCurrency: lib.NativeCurrency{Name: "Ether", Symbol: "ETH", Decimals: 18}},
+	})
+
+	if got := chainLabel(1); got != "Ethereum Mainnet (eth, chain id: 1)" {
+		t.Errorf("chainLabel(1) = %s", got)
+	}
+	// chains missing in the registry still get a label
+	if got := chainLabel(424242); got != "unknown chain (chain id: 424242)" {
+		t.Errorf("chainLabel(424242) = %s", got)
+	}
+	ChainInfos = nil
+	if got := chainLabel(1); got != "unknown chain (chain id: 1)" {
+		t.Errorf("chainLabel(1) without registry = %s", got)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+
+	"github.com/zsystm/solizard/internal/client"
+	"github.com/zsystm/solizard/internal/history"
+	"github.com/zsystm/solizard/internal/log"
+	"github.com/zsystm/solizard/internal/nonce"
+	"github.com/zsystm/solizard/internal/prompt"
+)
+
+// ReplacementBump is the fee increase in percent of a replacement transaction,
+// nodes refuse to replace a pending transaction with a smaller bump
+const ReplacementBump = 10
+
+// replacementPollInterval is the interval of checking which of the replaced and replacement transactions is mined
+var replacementPollInterval = 2 * time.Second
+
+// replaceTx sends a transaction with the nonce of the pending transaction of the entry and bumped fees:
+// the same call to speed it up, or a 0 value transfer to the sender to cancel it.
+// The replacement is recorded in the nonce manager of the session if nonces isn't nil.
+// It then waits until one of the transactions using the nonce is mined and updates the history.
+func replaceTx(reqCtx context.Context, cli client.EthClient, nonces *nonce.Manager, key *ecdsa.PrivateKey, e history.Entry, cancel bool) error {
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	if !strings.EqualFold(from.Hex(), e.From) {
+		return fmt.Errorf("transaction %s is sent from %s, not from the signer %s", e.Hash, e.From, from.Hex())
+	}
+	chainId, err := cli.ChainID(reqCtx)
+	if err != nil {
+		return fmt.Errorf("failed to get chain id from the node (reason: %w)", err)
+	}
+	if chainId.Uint64() != e.ChainId {
+		return fmt.Errorf("transaction %s is sent on chain id %d but the node serves chain id %d", e.Hash, e.ChainId, chainId)
+	}
+	tx, pending, err := cli.TransactionByHash(reqCtx, common.HexToHash(e.Hash))
+	if errors.Is(err, ethereum.NotFound) {
+		return fmt.Errorf("transaction %s is unknown to the node, it may have been dropped or replaced, check its receipt", e.Hash)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get transaction %s (reason: %w)", e.Hash, err)
+	}
+	if !pending {
+		return fmt.Errorf("transaction %s is already mined, check its receipt", e.Hash)
+	}
+
+	replacement, err := bumpedTx(reqCtx, cli, tx, from, cancel)
+	if err != nil {
+		return err
+	}
+	action := "speed up"
+	if cancel {
+		action = "cancel"
+	}
+	if !prompt.MustConfirm(fmt.Sprintf("Sign and send a %s of %s with nonce %d on %s, %s?", action, e.Hash, tx.Nonce(), chainLabel(e.ChainId), feesLabel(e.ChainId, replacement))) {
+		log.Info("transaction is not replaced\n")
+		return nil
+	}
+	signed, err := types.SignTx(replacement, types.LatestSignerForChainID(chainId), key)
+	if err != nil {
+		return fmt.Errorf("failed to sign transaction (reason: %v)", err)
+	}
+	if err = cli.SendTransaction(reqCtx, signed); err != nil {
+		return fmt.Errorf("failed to send transaction (reason: %w)", err)
+	}
+	if nonces != nil {
+		// a nonce set to replace the transaction isn't used again
+		nonces.Sent(e.ChainId, from, signed.Nonce())
+	}
+	sent := history.NewEntry(e.ChainId, signed, from, e.Contract, e.Method, nil)
+	sent.Args = e.Args
+	if cancel {
+		sent.Contract, sent.Method, sent.Args = "", "", nil
+	}
+	sent.Replaces = e.Hash
+	recordEntry(sent)
+	log.Result("tx_sent", fmt.Sprintf("%s sent (txHash %v).\n", action, sent.Hash), txSent{
+		Hash:     sent.Hash,
+		From:     sent.From,
+		To:       sent.To,
+		Contract: sent.Contract,
+		Method:   sent.Method,
+		Nonce:    signed.Nonce(),
+	})
+	return waitNonce(reqCtx, cli, e.ChainId, from, signed.Nonce())
+}
+
+// bumpedTx returns the unsigned replacement of the pending transaction, its fees are bumped by ReplacementBump percent
+// or raised to the current fees if they are higher. The replacement has the type of the transaction,
+// a speed up keeps its access list.
+func bumpedTx(reqCtx context.Context, cli client.EthClient, tx *types.Transaction, from common.Address, cancel bool) (*types.Transaction, error) {
+	to, value, gas, data, accessList := tx.To(), tx.Value(), tx.Gas(), tx.Data(), tx.AccessList()
+	if cancel {
+		// the access list is dropped since its gas isn't covered by the gas of a transfer
+		to, value, gas, data, accessList = &from, common.Big0, params.TxGas, nil, nil
+	}
+	if tx.Type() == types.DynamicFeeTxType {
+		tip, err := cli.SuggestGasTipCap(reqCtx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get gas tip cap (reason: %w)", err)
+		}
+		tip = bigMax(bump(tx.GasTipCap()), tip)
+		feeCap := bump(tx.GasFeeCap())
+		if head, err := cli.HeaderByNumber(reqCtx, nil); err == nil && head.BaseFee != nil {
+			// the fee cap covers a doubling of the base fee, like the wallets do
+			feeCap = bigMax(feeCap, new(big.Int).Add(new(big.Int).Mul(head.BaseFee, big.NewInt(2)), tip))
+		}
+		feeCap = bigMax(feeCap, tip)
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:    tx.ChainId(),
+			Nonce:      tx.Nonce(),
+			GasTipCap:  tip,
+			GasFeeCap:  feeCap,
+			Gas:        gas,
+			To:         to,
+			Value:      value,
+			Data:       data,
+			AccessList: accessList,
+		}), nil
+	}
+	gasPrice, err := cli.SuggestGasPrice(reqCtx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get gas price (reason: %w)", err)
+	}
+	gasPrice = bigMax(bump(tx.GasPrice()), gasPrice)
+	if tx.Type() == types.AccessListTxType {
+		return types.NewTx(&types.AccessListTx{
+			ChainID:    tx.ChainId(),
+			Nonce:      tx.Nonce(),
+			GasPrice:   gasPrice,
+			Gas:        gas,
+			To:         to,
+			Value:      value,
+			Data:       data,
+			AccessList: accessList,
+		}), nil
+	}
+	return types.NewTx(&types.LegacyTx{
+		Nonce:    tx.Nonce(),
+		GasPrice: gasPrice,
+		Gas:      gas,
+		To:       to,
+		Value:    value,
+		Data:     data,
+	}), nil
+}
+
+// bump returns the fee increased by ReplacementBump percent, rounded up
+func bump(fee *big.Int) *big.Int {
+	bumped := new(big.Int).Mul(fee, big.NewInt(100+ReplacementBump))
+	return bumped.Add(bumped, big.NewInt(99)).Div(bumped, big.NewInt(100))
+}
+
+func bigMax(a, b *big.Int) *big.Int {
+	if a.Cmp(b) >= 0 {
+		return a
+	}
+	return b
+}
+
+func feesLabel(chainId uint64, tx *types.Transaction) string {
+	if tx.Type() == types.DynamicFeeTxType {
+		return fmt.Sprintf("max fee: %s, priority fee: %s", formatNative(chainId, tx.GasFeeCap()), formatNative(chainId, tx.GasTipCap()))
+	}
+	return fmt.Sprintf("gas price: %s", formatNative(chainId, tx.GasPrice()))
+}
+
+// waitNonce waits until one of the pending transactions of the history with the nonce is mined,
+// it updates the mined one with its receipt and the others as replaced
+func waitNonce(reqCtx context.Context, cli client.EthClient, chainId uint64, from common.Address, nonce uint64) error {
+	log.Info(fmt.Sprintf("waiting for a transaction with nonce %d to be mined... (Ctrl-C to stop waiting)\n", nonce))
+	waitCtx, cancel := context.WithTimeout(reqCtx, ReceiptTimeout)
+	defer cancel()
+	ticker := time.NewTicker(replacementPollInterval)
+	defer ticker.Stop()
+	for {
+		entries, err := history.Read(HistoryPath)
+		if err != nil {
+			return fmt.Errorf("failed to read history (reason: %v)", err)
+		}
+		var candidates []history.Entry
+		for _, e := range history.Select(entries, history.Filter{ChainId: chainId, Status: history.StatusPending}) {
+			if e.Nonce == nonce && strings.EqualFold(e.From, from.Hex()) {
+				candidates = append(candidates, e)
+			}
+		}
+		// the nonce is read before the receipts, a candidate mined in between is found next time
+		confirmed, nonceErr := cli.NonceAt(waitCtx, from, nil)
+		mined, receipt, err := minedCandidate(waitCtx, cli, candidates)
+		if err != nil {
+			if waitCtx.Err() != nil {
+				return stoppedWaiting(nonce)
+			}
+			return err
+		}
+		if mined != nil {
+			markReplaced(candidates, mined.Hash)
+			jsonReceipt, _ := receipt.MarshalJSON()
+			log.Result("receipt", fmt.Sprintf("transaction %s with nonce %d is mined, receipt: %s\n", mined.Hash, nonce, string(jsonReceipt)), receipt)
+			return nil
+		}
+		if nonceErr == nil && confirmed > nonce {
+			// a transaction sent by another tool used the nonce
+			markReplaced(candidates, "")
+			return fmt.Errorf("nonce %d of %s is used by a transaction which is not in the history", nonce, from.Hex())
+		}
+		select {
+		case <-ticker.C:
+		case <-waitCtx.Done():
+			return stoppedWaiting(nonce)
+		}
+	}
+}
+
+func stoppedWaiting(nonce uint64) error {
+	log.Error(fmt.Sprintf("stopped waiting for a transaction with nonce %d, check the receipts later with the history\n", nonce))
+	return nil
+}
+
+// minedCandidate returns the transaction of the candidates which is mined with its receipt, nil if none is mined yet
+func minedCandidate(reqCtx context.Context, cli client.EthClient, candidates []history.Entry) (*history.Entry, *types.Receipt, error) {
+	for i, e := range candidates {
+		receipt, err := cli.TransactionReceipt(reqCtx, common.HexToHash(e.Hash))
+		if errors.Is(err, ethereum.NotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get receipt of %s (reason: %w)", e.Hash, err)
+		}
+		candidates[i].SetReceipt(receipt)
+		return &candidates[i], receipt, nil
+	}
+	return nil, nil, nil
+}
+
+// markReplaced updates the candidates in the history, the ones other than the mined transaction are replaced
+func markReplaced(candidates []history.Entry, mined string) {
+	for i := range candidates {
+		if candidates[i].Hash != mined {
+			candidates[i].Status = history.StatusReplaced
+		}
+	}
+	if err := history.Update(HistoryPath, candidates...); err != nil {
+		log.Error(fmt.Sprintf("failed to update the history (reason: %v)\n", err))
+	}
+}
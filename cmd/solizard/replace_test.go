@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+
+	"github.com/zsystm/solizard/internal/client"
+)
+
+// feeClient suggests fixed fees, the other requests aren't expected
+type feeClient struct {
+	client.EthClient
+	gasPrice, tip, baseFee *big.Int
+}
+
+func (c feeClient) SuggestGasPrice(context.Context) (*big.Int, error) {
+	return c.gasPrice, nil
+}
+
+func (c feeClient) SuggestGasTipCap(context.Context) (*big.Int, error) {
+	return c.tip, nil
+}
+
+func (c feeClient) HeaderByNumber(context.Context, *big.Int) (*types.Header, error) {
+	return &types.Header{BaseFee: c.baseFee}, nil
+}
+
+func TestBumpedTx(t *testing.T) {
+	from := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	token := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	data := []byte{0xa9, 0x05, 0x9c, 0xbb}
+	legacy := types.NewTx(&types.LegacyTx{Nonce: 4, GasPrice: big.NewInt(1000), Gas: 60000, To: &token, Value: big.NewInt(5), Data: data})
+	accessList := types.AccessList{{Address: token, StorageKeys: []common.Hash{common.HexToHash("0x01")}}}
+	dynamic := types.NewTx(&types.DynamicFeeTx{ChainID: big.NewInt(1), Nonce: 4, GasTipCap: big.NewInt(100), GasFeeCap: big.NewInt(1000), Gas: 60000, To: &token, Data: data, AccessList: accessList})
+	withAccessList := types.NewTx(&types.AccessListTx{ChainID: big.NewInt(10), Nonce: 4, GasPrice: big.NewInt(1000), Gas: 60000, To: &token, Value: big.NewInt(5), Data: data, AccessList: accessList})
+
+	tests := []struct {
+		name   string
+		tx     *types.Transaction
+		cli    feeClient
+		cancel bool
+		// wantPrice is the gas price of a legacy transaction, the fee cap of a dynamic fee transaction
+		wantPrice, wantTip int64
+	}{
+		{name: "legacy bumped", tx: legacy, cli: feeClient{gasPrice: big.NewInt(900)}, wantPrice: 1100},
+		{name: "legacy at the current price", tx: legacy, cli: feeClient{gasPrice: big.NewInt(2000)}, wantPrice: 2000},
+		{name: "legacy rounded up", tx: types.NewTx(&types.LegacyTx{Nonce: 4, GasPrice: big.NewInt(15), Gas: 60000, To: &token}), cli: feeClient{gasPrice: big.NewInt(1)}, wantPrice: 17},
+		{name: "legacy cancel", tx: legacy, cli: feeClient{gasPrice: big.NewInt(900)}, cancel: true, wantPrice: 1100},
+		{name: "dynamic bumped", tx: dynamic, cli: feeClient{tip: big.NewInt(50), baseFee: big.NewInt(100)}, wantPrice: 1100, wantTip: 110},
+		{name: "dynamic at the current tip", tx: dynamic, cli: feeClient{tip: big.NewInt(300), baseFee: big.NewInt(100)}, wantPrice: 1100, wantTip: 300},
+		// the fee cap covers twice the base fee and the tip
+		{name: "dynamic base fee raised", tx: dynamic, cli: feeClient{tip: big.NewInt(50), baseFee: big.NewInt(1000)}, wantPrice: 2110, wantTip: 110},
+		{name: "dynamic cancel", tx: dynamic, cli: feeClient{tip: big.NewInt(50), baseFee: big.NewInt(100)}, cancel: true, wantPrice: 1100, wantTip: 110},
+		{name: "access list bumped", tx: withAccessList, cli: feeClient{gasPrice: big.NewInt(900)}, wantPrice: 1100},
+		{name: "access list at the current price", tx: withAccessList, cli: feeClient{gasPrice: big.NewInt(2000)}, wantPrice: 2000},
+		{name: "access list cancel", tx: withAccessList, cli: feeClient{gasPrice: big.NewInt(900)}, cancel: true, wantPrice: 1100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := bumpedTx(context.Background(), tt.cli, tt.tx, from, tt.cancel)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Type() != tt.tx.Type() || got.Nonce() != tt.tx.Nonce() {
+				t.Fatalf("replacement of type %d with nonce %d, want type %d with nonce %d", got.Type(), got.Nonce(), tt.tx.Type(), tt.tx.Nonce())
+			}
+			if got.Type() == types.DynamicFeeTxType {
+				if got.GasFeeCap().Int64() != tt.wantPrice || got.GasTipCap().Int64() != tt.wantTip {
+					t.Errorf("fee cap %v and tip %v, want %d and %d", got.GasFeeCap(), got.GasTipCap(), tt.wantPrice, tt.wantTip)
+				}
+			} else if got.GasPrice().Int64() != tt.wantPrice {
+				t.Errorf("gas price %v, want %d", got.GasPrice(), tt.wantPrice)
+			}
+			if got.Type() != types.LegacyTxType && got.ChainId().Cmp(tt.tx.ChainId()) != 0 {
+				t.Errorf("chain id %v, want %v", got.ChainId(), tt.tx.ChainId())
+			}
+			if tt.cancel {
+				if *got.To() != from || got.Value().Sign() != 0 || got.Gas() != params.TxGas || len(got.Data()) != 0 || len(got.AccessList()) != 0 {
+					t.Errorf("the cancel isn't an empty transfer to the sender: to %v, value %v, gas %d, data %x, access list %v", got.To(), got.Value(), got.Gas(), got.Data(), got.AccessList())
+				}
+				return
+			}
+			if *got.To() != token || got.Value().Cmp(tt.tx.Value()) != 0 || got.Gas() != tt.tx.Gas() || string(got.Data()) != string(tt.tx.Data()) {
+				t.Errorf("the speed up changed the call: to %v, value %v, gas %d, data %x", got.To(), got.Value(), got.Gas(), got.Data())
+			}
+			if !reflect.DeepEqual(got.AccessList(), tt.tx.AccessList()) {
+				t.Errorf("access list %v, want %v", got.AccessList(), tt.tx.AccessList())
+			}
+		})
+	}
+}
@@ -0,0 +1,623 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+
+	internalabi "github.com/zsystm/solizard/internal/abi"
+	"github.com/zsystm/solizard/internal/client"
+	"github.com/zsystm/solizard/internal/config"
+	"github.com/zsystm/solizard/internal/ctx"
+	"github.com/zsystm/solizard/internal/events"
+	"github.com/zsystm/solizard/internal/history"
+	"github.com/zsystm/solizard/internal/log"
+	"github.com/zsystm/solizard/internal/nonce"
+	"github.com/zsystm/solizard/internal/prompt"
+	"github.com/zsystm/solizard/internal/safe"
+	"github.com/zsystm/solizard/internal/step"
+	"github.com/zsystm/solizard/internal/token"
+	"github.com/zsystm/solizard/internal/validation"
+)
+
+// watchPollInterval is the interval of polling new logs when the endpoint can't push them
+var watchPollInterval = events.DefaultPollInterval
+
+// session is the state shared by the steps of the interactive mode
+type session struct {
+	sctx *ctx.Context
+	abis map[string]abi.ABI
+
+	contractName string
+	contractAbi  abi.ABI
+	tokenMeta    *token.Metadata
+
+	// batch collects the calls added to the Safe batch, it's nil until the first call is added
+	batch *safe.Batch
+	// nonces tracks the nonces of the transactions sent in the session
+	nonces *nonce.Manager
+}
+
+func newSession(sctx *ctx.Context, abis map[string]abi.ABI) *session {
+	return &session{sctx: sctx, abis: abis, nonces: nonce.NewManager()}
+}
+
+// machine returns the state machine running the steps of the session
+func (s *session) machine() *step.Machine {
+	m := step.NewMachine()
+	m.Handle(step.StepChangeContract, s.selectContract)
+	m.Handle(step.StepInputRpcUrl, operation(s.inputRpcUrl))
+	m.Handle(step.StepChangeContractAddress, operation(s.inputContractAddress))
+	m.Handle(step.StepSelectMethod, operation(s.selectMethod))
+	m.Handle(step.StepRepeatCall, operation(s.repeatCall))
+	m.Handle(step.StepEditCall, operation(s.editCall))
+	m.Handle(step.StepEvents, operation(s.events))
+	m.Handle(step.StepWatch, operation(s.watch))
+	m.Handle(step.StepSelectStep, s.selectStep)
+	m.Handle(step.StepAddressBook, s.addressBook)
+	m.Handle(step.StepSafeBatch, s.safeBatch)
+	m.Handle(step.StepHistory, operation(s.browseHistory))
+	m.Handle(step.StepSetNonce, operation(s.setNonce))
+	m.Handle(step.StepSwitchNetwork, s.switchNetwork)
+	return m
+}
+
+// operation runs the step as an operation which is cancelled when the user presses Ctrl-C
+func operation(handler func(opCtx context.Context) (step.Step, error)) step.Handler {
+	return func() (step.Step, error) {
+		opCtx, done := interrupts.operation()
+		defer done()
+		return handler(opCtx)
+	}
+}
+
+// failed logs the failure and returns the step to continue with,
+// interrupted requests return to the step picker
+func failed(msg string, err error, next step.Step) (step.Step, error) {
+	if errors.Is(err, context.Canceled) {
+		log.Error(fmt.Sprintf("%s (interrupted)\n", msg))
+		return step.StepSelectStep, nil
+	}
+	log.Error(fmt.Sprintf("%s (reason: %v)\n", msg, err))
+	return next, nil
+}
+
+func (s *session) selectContract() (step.Step, error) {
+	s.contractName, s.contractAbi = prompt.MustSelectContractABI(s.abis)
+	return step.StepInputRpcUrl, nil
+}
+
+// inputRpcUrl connects to the rpc url if the network has no client yet
+func (s *session) inputRpcUrl(opCtx context.Context) (step.Step, error) {
+	sctx := s.sctx
+	if sctx.EthClient() == nil {
+		if sctx.ChainId().Sign() == 0 {
+			// pick the chain to suggest its rpc endpoints
+			if chainInfo := prompt.MustSelectChain(ChainInfos.Chains()); chainInfo != nil {
+				sctx.SetChainId(new(big.Int).SetUint64(chainInfo.ChainID))
+			}
+		}
+		var endpoints []string
+		if !sctx.Profile().DisableFailover {
+			endpoints = client.Healthy(opCtx, ctx.FailoverEndpoints(ChainInfos, sctx.ChainId().Uint64()), sctx.ChainId().Uint64())
+		}
+		rpcURL := prompt.MustSelectRpcUrl(endpoints)
+		// the other healthy endpoints are used for failover
+		cli, err := client.Dial(append([]string{rpcURL}, endpoints...)...)
+		if err != nil {
+			log.Error(fmt.Sprintf("failed to connect to given rpc url: %v, please input valid one\n", err))
+			return step.StepInputRpcUrl, nil
+		}
+		cli.SetTimeout(sctx.Profile().Timeout())
+		if cli.URL() != rpcURL {
+			log.Error(fmt.Sprintf("failed to connect to %s, using %s from the chain registry instead\n", rpcURL, cli.URL()))
+		}
+		sctx.SetEthClient(cli)
+		// the chain id is detected from the node rather than typed
+		if sctx.ChainId().Sign() != 0 && sctx.ChainId().Cmp(cli.ChainId()) != 0 {
+			log.Error(fmt.Sprintf("WARNING: the rpc url serves chain id %d instead of the selected %d\n", cli.ChainId(), sctx.ChainId()))
+		}
+		sctx.SetChainId(cli.ChainId())
+		ctx.PrintContext(sctx, ChainInfos)
+		if ConfigExist {
+			if name := prompt.MustInputProfileName(); name != "" {
+				p := *sctx.Profile()
+				p.RpcURL = rpcURL
+				p.ChainId = cli.ChainId().Uint64()
+				Conf.SetProfile(name, &p)
+				if err = config.WriteConfig(ConfigPath, Conf); err != nil {
+					log.Error(fmt.Sprintf("failed to write config file (reason: %v)\n", err))
+				}
+			}
+		}
+	}
+	if sctx.ChainId().Sign() == 0 {
+		// the profile has no chain id
+		chainId, err := sctx.EthClient().ChainID(opCtx)
+		if err != nil {
+			log.Error(fmt.Sprintf("failed to get chain id (reason: %v), please select another network\n", err))
+			return step.StepSwitchNetwork, nil
+		}
+		sctx.SetChainId(chainId)
+	}
+	return step.StepChangeContractAddress, nil
+}
+
+func (s *session) inputContractAddress(opCtx context.Context) (step.Step, error) {
+	sctx := s.sctx
+	// pick one of the known deployments of the contract on the current chain
+	var contractInfo *config.ContractInfo
+	if ContractInfoExist {
+		contractInfo = prompt.MustSelectContractAddress(AddressBook.Deployments(sctx.ChainId().Uint64(), s.contractName))
+	}
+	var contractAddress string
+	if contractInfo != nil {
+		contractAddress = contractInfo.Address
+	} else {
+		contractAddress = prompt.MustInputContractAddress()
+	}
+	if err := validation.ValidateContractAddress(opCtx, sctx, contractAddress); err != nil {
+		return failed("Invalid contract address", err, step.StepChangeContractAddress)
+	}
+	if ContractInfoExist {
+		saveContractInfo(sctx.ChainId().Uint64(), s.contractName, contractAddress, contractInfo)
+	}
+	s.tokenMeta = nil
+	if Conf.TokenFormat && token.IsToken(s.contractAbi) {
+		var err error
+		if s.tokenMeta, err = token.FetchMetadata(opCtx, sctx.EthClient(), s.contractAbi, *sctx.ContractAddress()); err != nil {
+			log.Error(fmt.Sprintf("failed to fetch token metadata, amounts are shown unformatted (reason: %v)\n", err))
+		}
+	}
+	return step.StepSelectMethod, nil
+}
+
+func (s *session) selectMethod(opCtx context.Context) (step.Step, error) {
+	action := prompt.MustSelectAction()
+	if action.Sends() && !s.ensureSigner() {
+		return step.StepSelectMethod, nil
+	}
+	methodName, method := prompt.MustSelectMethod(s.contractAbi, action.MethodType())
+	return s.invoke(opCtx, history.Call{Method: methodName, Kind: internalabi.MethodTypeOf(method), Action: action}, method)
+}
+
+// ensureSigner asks the private key or unlocks the keystore of the profile if the session has no signer yet
+func (s *session) ensureSigner() bool {
+	if s.sctx.PrivateKey() != nil {
+		return true
+	}
+	pk, err := inputSigner(s.sctx.Profile().Keystore)
+	if err != nil {
+		log.Error(fmt.Sprintf("%v\n", err))
+		return false
+	}
+	// Don't write private key to config file for security reasons
+	s.sctx.SetPrivateKey(pk)
+	return true
+}
+
+// invoke asks the arguments and the value of the call, prefilled with those of the given call, and runs it
+func (s *session) invoke(opCtx context.Context, prev history.Call, method abi.Method) (step.Step, error) {
+	args, err := prompt.InputArgsForMethod(method, prev.Args)
+	if err != nil {
+		log.Error(fmt.Sprintf("invalid arguments for %s (reason: %v)\n", prev.Method, err))
+		return step.StepSelectMethod, nil
+	}
+	call := history.Call{Abi: s.contractName, Method: prev.Method, Kind: prev.Kind, Action: prev.Action, Args: args}
+	if method.IsPayable() && prev.Action != step.ActionRead {
+		call.Value = prompt.MustInputValue(prev.Value).String()
+	}
+	return s.run(opCtx, call, method)
+}
+
+// run calls, sends, builds or adds to the Safe batch the call of the method,
+// once done it's recorded as the most recent call of the contract
+func (s *session) run(opCtx context.Context, call history.Call, method abi.Method) (step.Step, error) {
+	input, err := prompt.PackArgs(method, call.Args)
+	if err != nil {
+		log.Error(fmt.Sprintf("invalid arguments for %s (reason: %v)\n", call.Method, err))
+		return step.StepSelectMethod, nil
+	}
+	value := common.Big0
+	if call.Value != "" {
+		var ok bool
+		if value, ok = new(big.Int).SetString(call.Value, 10); !ok {
+			log.Error(fmt.Sprintf("invalid value %q of the call to %s\n", call.Value, call.Method))
+			return step.StepSelectMethod, nil
+		}
+	}
+	switch call.Action {
+	case step.ActionRead:
+		return s.call(opCtx, call, method, input)
+	case step.ActionBuild:
+		return s.build(call, method, input, value)
+	case step.ActionBatch:
+		return s.addToBatch(call, method, input, value)
+	case step.ActionWrite, step.ActionQueue:
+		return s.send(opCtx, call, method, input, value)
+	}
+	log.Error(fmt.Sprintf("unknown action %q of the call to %s\n", call.Action, call.Method))
+	return step.StepSelectMethod, nil
+}
+
+// remember records the call as the most recent call of the contract,
+// calls which fail, are refused or declined aren't recorded
+func (s *session) remember(call history.Call) {
+	RecentCalls.Add(s.sctx.ChainId().Uint64(), *s.sctx.ContractAddress(), call)
+	if err := RecentCalls.Write(RecentCallsPath); err != nil {
+		log.Error(fmt.Sprintf("failed to write recent calls (reason: %v)\n", err))
+	}
+}
+
+// repeatCall runs the last call of the contract again with the same arguments
+func (s *session) repeatCall(opCtx context.Context) (step.Step, error) {
+	call, method, ok := s.recentCall(false)
+	if !ok {
+		return step.StepSelectMethod, nil
+	}
+	log.Info(fmt.Sprintf("repeating %s\n", call))
+	return s.run(opCtx, call, method)
+}
+
+// editCall runs a recent call of the contract again, its arguments are prefilled and editable
+func (s *session) editCall(opCtx context.Context) (step.Step, error) {
+	call, method, ok := s.recentCall(true)
+	if !ok {
+		return step.StepSelectMethod, nil
+	}
+	return s.invoke(opCtx, call, method)
+}
+
+// recentCall returns the last call of the contract, or the recent call the user picks, with its method.
+// It returns false if there is no recent call to run.
+func (s *session) recentCall(pick bool) (history.Call, abi.Method, bool) {
+	sctx := s.sctx
+	calls := RecentCalls.Of(sctx.ChainId().Uint64(), *sctx.ContractAddress())
+	if len(calls) == 0 {
+		log.Error(fmt.Sprintf("no recent calls of %s (%s), select a method first\n", s.contractName, sctx.ContractAddress().Hex()))
+		return history.Call{}, abi.Method{}, false
+	}
+	call := calls[0]
+	if pick {
+		call = prompt.MustSelectRecentCall(calls)
+	}
+	method, ok := s.contractAbi.Methods[call.Method]
+	if !ok {
+		log.Error(fmt.Sprintf("%s is not a method of the %s abi\n", call.Method, s.contractName))
+		return history.Call{}, abi.Method{}, false
+	}
+	if call.Action.Sends() && !s.ensureSigner() {
+		return history.Call{}, abi.Method{}, false
+	}
+	return call, method, true
+}
+
+// build prints the calldata of the method instead of calling or sending it,
+// e.g. to propose it in a multisig or a governance proposal
+func (s *session) build(call history.Call, method abi.Method, input []byte, value *big.Int) (step.Step, error) {
+	sctx := s.sctx
+	s.remember(call)
+	built := builtCalldata{
+		Contract: s.contractName,
+		Method:   method.Sig,
+		Selector: hexutil.Encode(method.ID),
+		To:       sctx.ContractAddress().Hex(),
+		Calldata: hexutil.Encode(input),
+		Value:    value.String(),
+		ChainId:  sctx.ChainId().Uint64(),
+	}
+	if prompt.MustSelectCalldataFormat() == prompt.CalldataJSON {
+		req := txRequest{
+			To:      built.To,
+			Data:    built.Calldata,
+			Value:   hexutil.EncodeBig(value),
+			ChainId: hexutil.EncodeBig(sctx.ChainId()),
+		}
+		text, _ := json.MarshalIndent(req, "", "  ")
+		log.Result("tx_request", string(text)+"\n", req)
+		return step.StepSelectStep, nil
+	}
+	log.Result("calldata", fmt.Sprintf("method: %s\nselector: %s\ntarget: %s\nvalue: %s\ncalldata: %s\n", built.Method, built.Selector, built.To, formatNative(built.ChainId, value), built.Calldata), built)
+	return step.StepSelectStep, nil
+}
+
+// call calls the read method and prints its outputs
+func (s *session) call(opCtx context.Context, call history.Call, method abi.Method, input []byte) (step.Step, error) {
+	sctx := s.sctx
+	callMsg := ethereum.CallMsg{From: ZeroAddr, To: sctx.ContractAddress(), Data: input}
+	output, err := sctx.EthClient().CallContract(opCtx, callMsg, nil)
+	if err != nil {
+		return failed("failed to call contract", err, step.StepSelectMethod)
+	}
+	s.remember(call)
+	res, err := s.contractAbi.Unpack(call.Method, output)
+	if err != nil {
+		log.Error(fmt.Sprintf("failed to unpack output (reason: %v)\n", err))
+		return step.StepSelectMethod, nil
+	}
+	outputs := internalabi.NamedValues(method.Outputs, res)
+	if s.tokenMeta != nil && !token.IsDecimalsMethod(method) {
+		s.tokenMeta.Annotate(outputs)
+	}
+	if log.IsJSON() {
+		log.Emit("call_result", callResult{
+			Contract: s.contractName,
+			Address:  sctx.ContractAddress().Hex(),
+			Method:   call.Method,
+			Outputs:  internalabi.JSONNamedValues(outputs),
+		})
+	} else {
+		display := make([]interface{}, len(outputs))
+		for i, o := range outputs {
+			display[i] = o.Value
+			if o.Formatted != "" {
+				display[i] = fmt.Sprintf("%v (%s)", o.Value, o.Formatted)
+			}
+		}
+		fmt.Printf("output: %v\n", display)
+	}
+	return step.StepSelectStep, nil
+}
+
+// send signs and sends a transaction calling the write method,
+// then prints its receipt unless the call is queued without waiting
+func (s *session) send(opCtx context.Context, call history.Call, method abi.Method, input []byte, value *big.Int) (step.Step, error) {
+	sctx := s.sctx
+	from := crypto.PubkeyToAddress(sctx.PrivateKey().PublicKey)
+	if balance, err := sctx.EthClient().BalanceAt(opCtx, from, nil); err == nil {
+		log.Result("balance", fmt.Sprintf("sending from %s (balance: %s), value: %s\n", from.Hex(), formatNative(sctx.ChainId().Uint64(), balance), formatNative(sctx.ChainId().Uint64(), value)), balanceOutput{
+			Address:   from.Hex(),
+			Wei:       balance.String(),
+			Formatted: formatNativeUnits(sctx.ChainId().Uint64(), balance),
+		})
+	}
+	nonce, err := s.nonces.Next(opCtx, sctx.EthClient(), sctx.ChainId().Uint64(), from)
+	if err != nil {
+		return failed("failed to get nonce, maybe rpc is not working", err, step.StepInputRpcUrl)
+	}
+	gasPrice := sctx.Profile().FixedGasPrice()
+	if gasPrice == nil {
+		gasPrice, err = sctx.EthClient().SuggestGasPrice(opCtx)
+		if err != nil {
+			return failed("failed to get gas price, maybe rpc is not working", err, step.StepInputRpcUrl)
+		}
+	}
+	// TODO: Change to EthClient().EstimateGas() call.
+	sufficientGasLimit := sctx.Profile().Gas()
+	unsignedTx := types.NewTx(&types.LegacyTx{
+		To:       sctx.ContractAddress(),
+		Nonce:    nonce,
+		Value:    value,
+		Gas:      sufficientGasLimit,
+		GasPrice: gasPrice,
+		Data:     input,
+	})
+	if err = validation.ValidateSignerChainId(opCtx, sctx); err != nil {
+		return failed("refusing to sign the transaction", err, step.StepSelectMethod)
+	}
+	if !prompt.MustConfirm(fmt.Sprintf("Sign and send %s to %s (%s) with nonce %d on %s?", call.Method, s.contractName, sctx.ContractAddress().Hex(), nonce, chainLabel(sctx.ChainId().Uint64()))) {
+		log.Info("transaction is not sent\n")
+		return step.StepSelectMethod, nil
+	}
+	signedTx, err := types.SignTx(unsignedTx, types.NewEIP155Signer(sctx.ChainId()), sctx.PrivateKey())
+	if err != nil {
+		log.Error(fmt.Sprintf("failed to sign transaction (reason: %v)\n", err))
+		return step.StepSelectMethod, nil
+	}
+	if err = sctx.EthClient().SendTransaction(opCtx, signedTx); err != nil {
+		return failed("failed to send transaction, maybe rpc is not working", err, step.StepSelectMethod)
+	}
+	s.nonces.Sent(sctx.ChainId().Uint64(), from, nonce)
+	s.remember(call)
+	var args []internalabi.NamedValue
+	if values, err := method.Inputs.Unpack(input[4:]); err == nil {
+		args = internalabi.NamedValues(method.Inputs, values)
+	}
+	entry := recordSent(sctx.ChainId().Uint64(), signedTx, from, s.contractName, call.Method, args)
+	log.Result("tx_sent", fmt.Sprintf("transaction sent (txHash %v).\n", signedTx.Hash().Hex()), txSent{
+		Hash:     signedTx.Hash().Hex(),
+		From:     from.Hex(),
+		To:       sctx.ContractAddress().Hex(),
+		Contract: s.contractName,
+		Method:   call.Method,
+		Nonce:    nonce,
+	})
+	if call.Action == step.ActionQueue {
+		log.Info("not waiting for the receipt, check it later with the history step\n")
+		return step.StepSelectStep, nil
+	}
+	// sleep for x seconds to wait for transaction to be mined
+	waitTime := sctx.Profile().Wait()
+	log.Info(fmt.Sprintf("waiting for transaction to be mined... (sleep %s, Ctrl-C to stop waiting)\n", waitTime.String()))
+	select {
+	case <-time.After(waitTime):
+	case <-opCtx.Done():
+		log.Error(fmt.Sprintf("stopped waiting for transaction %s, it may still be mined, speed it up or cancel it with the history step\n", signedTx.Hash().Hex()))
+		return step.StepSelectStep, nil
+	}
+	receipt, err := sctx.EthClient().TransactionReceipt(opCtx, signedTx.Hash())
+	if err != nil {
+		return failed("failed to get transaction receipt", err, step.StepSelectStep)
+	}
+	recordReceipt(entry, receipt)
+	jsonReceipt, _ := receipt.MarshalJSON()
+	log.Result("receipt", fmt.Sprintf("transaction receipt: %s\n", string(jsonReceipt)), receipt)
+	for _, l := range receipt.Logs {
+		event, err := internalabi.DecodeLog(s.contractAbi, *l)
+		if err != nil {
+			// logs emitted by other contracts can't be decoded with the selected abi
+			continue
+		}
+		log.Result("event", fmt.Sprintf("event: %s\n", event), event.JSON())
+	}
+	return step.StepSelectStep, nil
+}
+
+// setNonce sets the nonce of the next transaction of the signer, e.g. to replace a stuck transaction
+// or to fill a gap left by a dropped one
+func (s *session) setNonce(opCtx context.Context) (step.Step, error) {
+	if !s.ensureSigner() {
+		return step.StepSelectStep, nil
+	}
+	sctx := s.sctx
+	from := crypto.PubkeyToAddress(sctx.PrivateKey().PublicKey)
+	next, err := s.nonces.Next(opCtx, sctx.EthClient(), sctx.ChainId().Uint64(), from)
+	if err != nil {
+		return failed("failed to get nonce, maybe rpc is not working", err, step.StepSelectStep)
+	}
+	confirmed, err := sctx.EthClient().NonceAt(opCtx, from, nil)
+	if err != nil {
+		return failed("failed to get nonce, maybe rpc is not working", err, step.StepSelectStep)
+	}
+	log.Info(fmt.Sprintf("%s has %d confirmed transactions, the next transaction uses nonce %d\n", from.Hex(), confirmed, next))
+	n := prompt.MustInputUint("Enter the nonce of the next transaction", fmt.Sprintf("%d", next))
+	s.nonces.Set(sctx.ChainId().Uint64(), from, n)
+	return step.StepSelectStep, nil
+}
+
+// events prints the decoded logs of the contract in a block range,
+// optionally of one event filtered by its indexed arguments
+func (s *session) events(opCtx context.Context) (step.Step, error) {
+	sctx := s.sctx
+	var topics [][]common.Hash
+	event := prompt.MustSelectEvent(s.contractAbi)
+	if event != nil {
+		var err error
+		if topics, err = events.Topics(*event, prompt.MustInputEventFilter(*event)); err != nil {
+			log.Error(fmt.Sprintf("invalid filter of %s (reason: %v)\n", event.Name, err))
+			return step.StepEvents, nil
+		}
+	}
+	latest, err := sctx.EthClient().BlockNumber(opCtx)
+	if err != nil {
+		return failed("failed to get the latest block", err, step.StepSelectStep)
+	}
+	from := prompt.MustInputBlock("From block", fmt.Sprintf("latest-%d", events.DefaultBlocks), latest)
+	to := prompt.MustInputBlock("To block", "latest", latest)
+	if from > to {
+		log.Error(fmt.Sprintf("from block %d is after to block %d\n", from, to))
+		return step.StepEvents, nil
+	}
+
+	logs, err := events.Fetch(opCtx, sctx.EthClient(), []common.Address{*sctx.ContractAddress()}, topics, from, to, func(start, end uint64) {
+		if start != from || end != to {
+			log.Info(fmt.Sprintf("fetching logs of blocks %d-%d...\n", start, end))
+		}
+	})
+	if err != nil {
+		if len(logs) == 0 {
+			return failed("failed to get logs", err, step.StepSelectStep)
+		}
+		log.Error(fmt.Sprintf("failed to get all logs, showing the %d logs found (reason: %v)\n", len(logs), err))
+	}
+	times, err := events.BlockTimes(opCtx, sctx.EthClient(), logs)
+	if err != nil {
+		log.Error(fmt.Sprintf("failed to get block timestamps (reason: %v)\n", err))
+	}
+	for _, l := range logs {
+		s.printLog(l, times[l.BlockNumber])
+	}
+	log.Info(fmt.Sprintf("found %d logs in blocks %d-%d\n", len(logs), from, to))
+	return step.StepSelectStep, nil
+}
+
+// watch prints the new logs of the chosen events of the contract until the user presses Ctrl-C
+func (s *session) watch(opCtx context.Context) (step.Step, error) {
+	sctx := s.sctx
+	var topics [][]common.Hash
+	switch selected := prompt.MustInputEventNames(s.contractAbi); len(selected) {
+	case 0:
+		// all events
+	case 1:
+		var err error
+		if topics, err = events.Topics(selected[0], prompt.MustInputEventFilter(selected[0])); err != nil {
+			log.Error(fmt.Sprintf("invalid filter of %s (reason: %v)\n", selected[0].Name, err))
+			return step.StepWatch, nil
+		}
+	default:
+		ids := make([]common.Hash, 0, len(selected))
+		for _, event := range selected {
+			if event.Anonymous {
+				// anonymous events have no id to filter by
+				ids = nil
+				break
+			}
+			ids = append(ids, event.ID)
+		}
+		if ids != nil {
+			topics = [][]common.Hash{ids}
+		}
+	}
+
+	log.Info(fmt.Sprintf("watching events of %s at %s, press Ctrl-C to stop\n", s.contractName, sctx.ContractAddress().Hex()))
+	polling := func(reason error) {
+		switch {
+		case events.IsNotificationsUnsupported(reason):
+		case errors.Is(reason, events.ErrSubscriptionClosed):
+			log.Error(fmt.Sprintf("%v\n", reason))
+		default:
+			log.Error(fmt.Sprintf("failed to subscribe to logs (reason: %v)\n", reason))
+		}
+		log.Info(fmt.Sprintf("polling new blocks every %s\n", watchPollInterval))
+	}
+	err := events.Watch(opCtx, sctx.EthClient(), []common.Address{*sctx.ContractAddress()}, topics, watchPollInterval, polling, func(l types.Log) {
+		times, err := events.BlockTimes(opCtx, sctx.EthClient(), []types.Log{l})
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Error(fmt.Sprintf("failed to get block timestamp (reason: %v)\n", err))
+		}
+		s.printLog(l, times[l.BlockNumber])
+	})
+	if errors.Is(err, context.Canceled) {
+		log.Info("stopped watching events\n")
+		return step.StepSelectStep, nil
+	}
+	return failed("failed to watch events", err, step.StepSelectStep)
+}
+
+// printLog prints the log decoded with the contract abi, token amounts are formatted for token contracts
+func (s *session) printLog(l types.Log, timestamp uint64) {
+	decoded, err := internalabi.DecodeLog(s.contractAbi, l)
+	if err != nil {
+		log.Error(fmt.Sprintf("failed to decode log %d of tx %s (reason: %v)\n", l.Index, l.TxHash.Hex(), err))
+		return
+	}
+	if s.tokenMeta != nil {
+		s.tokenMeta.Annotate(decoded.Args)
+	}
+	decoded.Timestamp = timestamp
+	text := fmt.Sprintf("#%d %s %s %s", l.BlockNumber, formatTime(timestamp), l.TxHash.Hex(), decoded)
+	if decoded.Removed {
+		text += " (removed by a reorg)"
+	}
+	log.Result("log", text+"\n", decoded.JSON())
+}
+
+// selectStep asks the user for the next step
+func (s *session) selectStep() (step.Step, error) {
+	return prompt.MustSelectStep(), nil
+}
+
+func (s *session) addressBook() (step.Step, error) {
+	manageAddressBook(s.sctx, s.abis)
+	return step.StepChangeContractAddress, nil
+}
+
+func (s *session) switchNetwork() (step.Step, error) {
+	sctx, err := selectNetwork("")
+	if err != nil {
+		log.Error(fmt.Sprintf("failed to switch network (reason: %v)\n", err))
+		return step.StepSelectStep, nil
+	}
+	s.sctx = sctx
+	return step.StepInputRpcUrl, nil
+}
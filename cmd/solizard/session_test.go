@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/zsystm/solizard/internal/step"
+)
+
+func TestFailed(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		want    step.Step
+		wantLog string
+	}{
+		{
+			name:    "failed request",
+			err:     errors.New("execution reverted"),
+			want:    step.StepSelectMethod,
+			wantLog: "failed to call contract (reason: execution reverted)",
+		},
+		{
+			name:    "interrupted request",
+			err:     context.Canceled,
+			want:    step.StepSelectStep,
+			wantLog: "failed to call contract (interrupted)",
+		},
+		{
+			name:    "interrupted while failing over",
+			err:     fmt.Errorf("failed to get code: %w", fmt.Errorf("%w (failover: no healthy rpc endpoint)", context.Canceled)),
+			want:    step.StepSelectStep,
+			wantLog: "failed to call contract (interrupted)",
+		},
+		{
+			// a timeout isn't an interrupt, the step is retried
+			name:    "timed out request",
+			err:     context.DeadlineExceeded,
+			want:    step.StepSelectMethod,
+			wantLog: "failed to call contract (reason: context deadline exceeded)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var next step.Step
+			var err error
+			out := captureStdout(t, func() {
+				next, err = failed("failed to call contract", tt.err, step.StepSelectMethod)
+			})
+			if err != nil {
+				t.Fatalf("failed() stops the machine: %v", err)
+			}
+			if next != tt.want {
+				t.Errorf("next step = %s, want %s", next, tt.want)
+			}
+			if !strings.Contains(out, tt.wantLog) {
+				t.Errorf("logged %q, want %q", out, tt.wantLog)
+			}
+		})
+	}
+}
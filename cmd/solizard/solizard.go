@@ -1,315 +1,273 @@
 package main
 
 import (
-	"context"
 	"embed"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
-	"time"
 
-	"github.com/ethereum/go-ethereum"
-	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
 
 	internalabi "github.com/zsystm/solizard/internal/abi"
 	"github.com/zsystm/solizard/internal/config"
 	"github.com/zsystm/solizard/internal/ctx"
+	"github.com/zsystm/solizard/internal/history"
 	"github.com/zsystm/solizard/internal/log"
 	"github.com/zsystm/solizard/internal/prompt"
 	"github.com/zsystm/solizard/internal/step"
-	"github.com/zsystm/solizard/internal/validation"
 	"github.com/zsystm/solizard/lib"
 )
 
 //go:embed embeds/*
 var embeddedFiles embed.FS
 
-const SolizardDir = ".solizard"
+const (
+	// SolizardDir is the legacy solizard directory in the user's home
+	SolizardDir = ".solizard"
+	// HomeEnv overrides the solizard directory like the --home flag
+	HomeEnv = "SOLIZARD_HOME"
+	// ProfileEnv selects the profile like the --profile flag
+	ProfileEnv = "SOLIZARD_PROFILE"
+)
 
 var (
+	// HomeDir is the solizard directory holding the config, the address book and the chain registry
+	HomeDir = ""
 	// AbiDir is the directory where all abi files are stored
-	// default is $HOME/.solizard/abis
-	AbiDir            = "abis"
+	// default is abis in the solizard directory, overridden by abi_dir of the config
+	AbiDir            = ""
 	ZeroAddr          = common.Address{}
 	ConfigPath        = ""
 	ConfigExist       = false
 	ContractInfosPath = ""
 	ContractInfoExist = false
 	Conf              *config.Config
-	ContractInfos     []config.ContractInfo
-	ChainInfos        []*lib.ChainInfo
+	AddressBook       config.AddressBook
+	ChainInfosPath    = ""
+	// CustomChainsPath is the file of user-defined chains merged into the chain registry
+	CustomChainsPath = ""
+	ChainInfos       *lib.ChainRegistry
+	// HistoryPath is the journal of the transactions sent by solizard
+	HistoryPath = ""
+	// RecentCallsPath is the file of the recent calls of each contract, repeated by the repeat and edit steps
+	RecentCallsPath = ""
+	RecentCalls     history.RecentCalls
+	// ProfileName is the profile selected by the --profile flag or $SOLIZARD_PROFILE,
+	// the user is asked to pick one at startup if empty
+	ProfileName = ""
 )
 
-func init() {
-	// get user's home directory
-	homeDir, err := os.UserHomeDir()
+// errInvalidOverride is returned when a flag or an environment variable has an invalid config value
+var errInvalidOverride = errors.New("invalid flag or environment variable")
+
+// solizardDir returns the solizard directory: the --home flag, $SOLIZARD_HOME,
+// $HOME/.solizard if it exists, or solizard in the XDG config directory
+// if $XDG_CONFIG_HOME is set or $HOME/.config/solizard exists, $HOME/.solizard otherwise.
+func solizardDir(home string) (string, error) {
+	if home != "" {
+		return home, nil
+	}
+	if home = os.Getenv(HomeEnv); home != "" {
+		return home, nil
+	}
+	userHome, err := os.UserHomeDir()
 	if err != nil {
-		log.Error(fmt.Sprintf("failed to get user's home directory (reason: %v)\n", err))
-		os.Exit(1)
+		return "", fmt.Errorf("failed to get user's home directory (reason: %v)", err)
+	}
+	legacy := filepath.Join(userHome, SolizardDir)
+	if _, err = os.Stat(legacy); err == nil {
+		return legacy, nil
+	}
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "solizard"), nil
+	}
+	xdg := filepath.Join(userHome, ".config", "solizard")
+	if _, err = os.Stat(xdg); err == nil {
+		return xdg, nil
 	}
-	dir := homeDir + "/" + SolizardDir
-	AbiDir = dir + "/" + AbiDir
-	ConfigPath = filepath.Join(homeDir, SolizardDir, "config.toml")
+	return legacy, nil
+}
+
+// setup creates the solizard directory with the bundled files if they don't exist
+// and reads the config, the address book and the chain registry.
+// The overrides of flags and environment variables are applied to the config.
+func setup(dir string, overrides ...config.Override) error {
+	HomeDir = dir
+	ConfigPath = filepath.Join(dir, "config.toml")
+	ContractInfosPath = filepath.Join(dir, "contract_infos.json")
+	ChainInfosPath = filepath.Join(dir, "chains_mini.json")
+	CustomChainsPath = filepath.Join(dir, "custom_chains.json")
+	HistoryPath = filepath.Join(dir, history.FileName)
+	RecentCallsPath = filepath.Join(dir, history.CallsFileName)
 
-	if _, err = os.Stat(AbiDir); os.IsNotExist(err) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create solizard directory (reason: %v)", err)
+	}
+	err := installEmbeds(func(name string) string {
+		switch name {
+		case "config.toml":
+			return ConfigPath
+		case "contract_infos.json":
+			ContractInfoExist = true
+			return ContractInfosPath
+		case "chains_mini.json":
+			return ChainInfosPath
+		}
+		return ""
+	})
+	if err != nil {
+		return err
+	}
+
+	if ChainInfos, err = lib.ParseChainsJSON(ChainInfosPath, CustomChainsPath); err != nil {
+		return fmt.Errorf("failed to read chain infos (reason: %v)", err)
+	}
+
+	if Conf, err = config.ReadConfig(ConfigPath); err != nil {
+		log.Error(fmt.Sprintf("failed to read config file (reason: %v)\n", err))
+		Conf = config.DefaultConfig()
+		ConfigExist = false
+	} else {
+		ConfigExist = true
+	}
+	for _, o := range overrides {
+		if err = Conf.Apply(o); err != nil {
+			return fmt.Errorf("%w: %v", errInvalidOverride, err)
+		}
+	}
+	if err = Conf.Validate(); err != nil {
+		return err
+	}
+
+	// the bundled abis are only installed in the default abi directory
+	AbiDir = Conf.AbiDir
+	if AbiDir == "" {
+		AbiDir = filepath.Join(dir, "abis")
 		if err = os.MkdirAll(AbiDir, 0755); err != nil {
-			log.Error(fmt.Sprintf("failed to create abi directory (reason: %v)\n", err))
-			os.Exit(1)
+			return fmt.Errorf("failed to create abi directory (reason: %v)", err)
+		}
+		err = installEmbeds(func(name string) string {
+			if filepath.Ext(name) == ".abi" {
+				return filepath.Join(AbiDir, name)
+			}
+			return ""
+		})
+		if err != nil {
+			return err
 		}
 	}
-	// copy embeds to directory and files if not exists
-	if err = fs.WalkDir(embeddedFiles, "embeds", func(path string, d fs.DirEntry, err error) error {
+
+	if AddressBook, err = config.ReadAddressBook(ContractInfosPath); err != nil {
+		return fmt.Errorf("failed to read contract infos (reason: %v)", err)
+	}
+	for _, err = range AddressBook.Sanitize() {
+		log.Error(fmt.Sprintf("skipping invalid address book entry (reason: %v)\n", err))
+	}
+	// the recent calls are a convenience, a broken file doesn't stop solizard
+	if RecentCalls, err = history.ReadRecentCalls(RecentCallsPath); err != nil {
+		log.Error(fmt.Sprintf("failed to read recent calls (reason: %v)\n", err))
+	}
+	return nil
+}
+
+// installEmbeds copies the embedded files to the targets returned by target if they don't exist,
+// files with an empty target are skipped
+func installEmbeds(target func(name string) string) error {
+	err := fs.WalkDir(embeddedFiles, "embeds", func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
-			log.Error(err.Error())
 			return err
 		}
-		if d.IsDir() {
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		dst := target(d.Name())
+		if dst == "" {
+			return nil
+		}
+		// If the file does not exist, create it
+		if _, err = os.Stat(dst); !os.IsNotExist(err) {
 			return nil
 		}
 		data, err := embeddedFiles.ReadFile(path)
 		if err != nil {
-			log.Error(err.Error())
 			return err
 		}
-		if d.Type().IsRegular() && d.Name()[len(d.Name())-4:] == ".abi" {
-			abiFile := filepath.Join(AbiDir, d.Name())
-			// If abi file does not exist, create it
-			if _, err = os.Stat(abiFile); os.IsNotExist(err) {
-				if err = os.WriteFile(abiFile, data, 0644); err != nil {
-					log.Error(err.Error())
-					return err
-				}
-			}
-		}
-		if d.Type().IsRegular() && d.Name() == "config.toml" {
-			// If config file does not exist, create it
-			if _, err = os.Stat(ConfigPath); os.IsNotExist(err) {
-				if err = os.WriteFile(ConfigPath, data, 0644); err != nil {
-					log.Error(err.Error())
-					return err
-				}
-			}
-			ConfigExist = true
-		}
-		if d.Type().IsRegular() && d.Name() == "contract_infos.json" {
-			// If contract_infos.json file does not exist, create it
-			ContractInfosPath = filepath.Join(homeDir, SolizardDir, "contract_infos.json")
-			if _, err = os.Stat(ContractInfosPath); os.IsNotExist(err) {
-				// create contract_infos.json if not exists
-				if err = os.WriteFile(ContractInfosPath, data, 0644); err != nil {
-					log.Error(fmt.Sprintf("failed to create contract_infos.json (reason: %v)\n", err))
-					os.Exit(1)
-				}
-			}
-			ContractInfoExist = true
-		}
-		if d.Type().IsRegular() && d.Name() == "chains_mini.json" {
-			// If chains_mini.json file does not exist, create it
-			ChainInfosPath := filepath.Join(homeDir, SolizardDir, "chains_mini.json")
-			if _, err = os.Stat(ChainInfosPath); os.IsNotExist(err) {
-				// create chains_mini.json if not exists
-				if err = os.WriteFile(ChainInfosPath, data, 0644); err != nil {
-					log.Error(fmt.Sprintf("failed to create chains_mini.json (reason: %v)\n", err))
-					os.Exit(1)
-				}
-			}
-			ChainInfos, err = lib.ParseChainsJSON(ChainInfosPath)
-			if err != nil {
-				log.Error(fmt.Sprintf("failed to read chain infos (reason: %v)\n", err))
-				os.Exit(1)
-			}
+		if err = os.WriteFile(dst, data, 0644); err != nil {
+			return fmt.Errorf("failed to create %s (reason: %v)", d.Name(), err)
 		}
 		return nil
-	}); err != nil {
-		// If error occurs, print error message and exit
-		log.Error(fmt.Sprintf("failed to walk embedded files (reason: %v)\n", err))
-		os.Exit(1)
-	}
-
-	if Conf, err = config.ReadConfig(ConfigPath); err != nil {
-		log.Error(fmt.Sprintf("failed to read config file (reason: %v)\n", err))
-		ConfigExist = false
-	} else {
-		ConfigExist = true
-	}
-
-	if ContractInfos, err = config.ReadContractInfos(ContractInfosPath); err != nil {
-		log.Error(fmt.Sprintf("failed to read contract infos (reason: %v)\n", err))
-		os.Exit(1)
-	}
-	if err = config.ValidateContractInfos(ContractInfos); err != nil {
-		log.Error(fmt.Sprintf("address book is invalid (reason: %v)\n", err))
-		panic(err)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to walk embedded files (reason: %v)", err)
 	}
+	return nil
 }
 
 func Run() error {
-	fmt.Println(`🦎 Welcome to Solizard v1.8.0 🦎`)
+	if !log.IsJSON() {
+		fmt.Println(`🦎 Welcome to Solizard v1.8.0 🦎`)
+	}
 	mAbi, err := internalabi.LoadABIs(AbiDir)
 	if err != nil {
 		return err
 	}
 
-	sctx := new(ctx.Context)
-	if ConfigExist {
-		if prompt.MustSelectApplyConfig() {
-			sctx = ctx.NewCtx(Conf)
-			ctx.PrintContext(sctx, ChainInfos)
-		}
+	sctx, err := selectNetwork(ProfileName)
+	if err != nil {
+		return err
 	}
 
-	var selectedContractName string
-	var selectedAbi abi.ABI
-	// start the main loop
-	for {
-	STEP_SELECT_CONTRACT:
-		selectedContractName, selectedAbi = prompt.MustSelectContractABI(mAbi)
-	INPUT_RPC_URL:
-		if sctx.EthClient() == nil {
-			rpcURL := prompt.MustInputRpcUrl()
-			client, err := ethclient.Dial(rpcURL)
-			if err != nil {
-				log.Error(fmt.Sprintf("failed to connect to given rpc url: %v, please input valid one\n", err))
-				goto INPUT_RPC_URL
-			}
-			sctx.SetEthClient(client)
-			Conf.RpcURL = rpcURL
-			if err = config.WriteConfig(ConfigPath, Conf); err != nil {
-				log.Error(fmt.Sprintf("failed to write config file (reason: %v)\n", err))
-			}
-		}
-	INPUT_CONTRACT_ADDRESS:
-		// check if address book exists
-		useContractInfo := false
-		var contractAddress string
-		if ContractInfoExist {
-			for _, ci := range ContractInfos {
-				if ci.Name == selectedContractName {
-					if yes := prompt.MustSelectAddressBookUsage(ci.Address); yes {
-						contractAddress = ci.Address
-						useContractInfo = true
-						break
-					}
-				}
-			}
-		}
-		if !useContractInfo {
-			contractAddress = prompt.MustInputContractAddress()
-		}
-		if err := validation.ValidateContractAddress(sctx, contractAddress); err != nil {
-			log.Error(fmt.Sprintf("Invalid contract address (reason: %v)\n", err))
-			goto INPUT_CONTRACT_ADDRESS
-		}
+	return newSession(sctx, mAbi).machine().Run(step.StepChangeContract)
+}
 
-	SELECT_METHOD:
-		rw := prompt.MustSelectReadOrWrite()
-		if rw == internalabi.WriteMethod {
-			// input private key
-			if sctx.PrivateKey() == nil {
-				pk := prompt.MustInputPrivateKey()
-				sctx.SetPrivateKey(pk)
-				// Don't write private key to config file for security reasons
-			}
-			// input chainId
-			if sctx.ChainId().Sign() == 0 {
-				chainID := prompt.MustInputChainID()
-				sctx.SetChainId(&chainID)
-				Conf.ChainId = chainID.Uint64()
-				if err = config.WriteConfig(ConfigPath, Conf); err != nil {
-					log.Error(fmt.Sprintf("failed to write config file (reason: %v)\n", err))
-				}
-			}
-		}
-		methodName, method := prompt.MustSelectMethod(selectedAbi, rw)
-		input := prompt.MustCreateInputDataForMethod(method)
-		if rw == internalabi.ReadMethod {
-			callMsg := ethereum.CallMsg{From: ZeroAddr, To: sctx.ContractAddress(), Data: input}
-			output, err := sctx.EthClient().CallContract(context.TODO(), callMsg, nil)
-			if err != nil {
-				log.Error(fmt.Sprintf("failed to call contract (reason: %v)\n", err))
-				return err
-			}
-			res, err := selectedAbi.Unpack(methodName, output)
-			if err != nil {
-				log.Error(fmt.Sprintf("failed to unpack output (reason: %v)\n", err))
-				return err
-			}
-			fmt.Printf("output: %v\n", res)
-		} else {
-			value := common.Big0
-			if method.IsPayable() {
-				value = prompt.MustInputValue()
-			}
-			nonce, err := sctx.EthClient().NonceAt(context.TODO(), crypto.PubkeyToAddress(sctx.PrivateKey().PublicKey), nil)
-			if err != nil {
-				log.Error(fmt.Sprintf("failed to get nonce (reason: %v), maybe rpc is not working.\n", err))
-				goto INPUT_RPC_URL
-			}
-			gasPrice, err := sctx.EthClient().SuggestGasPrice(context.TODO())
-			if err != nil {
-				log.Error(fmt.Sprintf("failed to get gas price (reason: %v), maybe rpc is not working.\n", err))
-				goto INPUT_RPC_URL
-			}
-			// TODO: Change to EthClient().EstimateGas() call.
-			sufficientGasLimit := uint64(3000000)
-			unsignedTx := types.NewTx(&types.LegacyTx{
-				To:       sctx.ContractAddress(),
-				Nonce:    nonce,
-				Value:    value,
-				Gas:      sufficientGasLimit,
-				GasPrice: gasPrice,
-				Data:     input,
-			})
-			signedTx, err := types.SignTx(unsignedTx, types.NewEIP155Signer(sctx.ChainId()), sctx.PrivateKey())
-			if err != nil {
-				log.Error(fmt.Sprintf("failed to sign transaction (reason: %v)\n", err))
-				return err
-			}
-			if err = sctx.EthClient().SendTransaction(context.TODO(), signedTx); err != nil {
-				log.Error(fmt.Sprintf("failed to send transaction (reason: %v), maybe rpc is not working.\n", err))
-				return err
-			}
-			log.Info(fmt.Sprintf("transaction sent (txHash %v).\n", signedTx.Hash().Hex()))
-			// sleep for x seconds to wait for transaction to be mined
-			waitTime, err := time.ParseDuration(Conf.WaitTime)
-			log.Info(fmt.Sprintf("waiting for transaction to be mined... (sleep %s)\n", waitTime.String()))
-			time.Sleep(waitTime)
-			receipt, err := sctx.EthClient().TransactionReceipt(context.TODO(), signedTx.Hash())
-			if err != nil {
-				log.Error(fmt.Sprintf("failed to get transaction receipt (reason: %v)\n", err))
-			} else {
-				jsonReceipt, _ := receipt.MarshalJSON()
-				log.Info(fmt.Sprintf("transaction receipt: %s\n", string(jsonReceipt)))
-			}
-		}
-		if !useContractInfo {
-			// Save interacted contract address to address book
-			ContractInfos = append(
-				ContractInfos,
-				config.ContractInfo{
-					Name:    selectedContractName,
-					Address: contractAddress,
-				},
-			)
-			if err = config.WriteContractInfos(ContractInfosPath, ContractInfos); err != nil {
-				log.Error(fmt.Sprintf("failed to write contract infos (reason: %v)\n", err))
-			}
+// saveContractInfo saves the validated contract address to the address book of the chain.
+// Entries without a known chain are moved to the chain, new addresses are labeled by the user.
+func saveContractInfo(chainId uint64, abiName, address string, known *config.ContractInfo) {
+	if known == nil {
+		// the typed address may be saved already
+		known = AddressBook.Lookup(chainId, abiName, address)
+	}
+	if known != nil {
+		if AddressBook.Remove(config.AnyChain, known.Abi, known.Address) != nil {
+			// already saved for this chain
+			return
 		}
-		st := prompt.MustSelectStep()
-		switch st {
-		case step.StepSelectMethod:
-			goto SELECT_METHOD
-		case step.StepChangeContract:
-			goto STEP_SELECT_CONTRACT
-		case step.StepChangeContractAddress:
-			goto INPUT_CONTRACT_ADDRESS
-		case step.StepExit:
-			panic("exit")
+		AddressBook.Add(chainId, *known)
+	} else {
+		AddressBook.Add(chainId, config.ContractInfo{
+			Abi:     abiName,
+			Address: address,
+			Label:   prompt.MustInputLabel(address),
+		})
+	}
+	if err := config.WriteAddressBook(ContractInfosPath, AddressBook); err != nil {
+		log.Error(fmt.Sprintf("failed to write contract infos (reason: %v)\n", err))
+	}
+}
+
+// selectNetwork creates the ctx for the profile with the given name.
+// If name is empty, the user is asked to pick one of the profiles.
+func selectNetwork(name string) (*ctx.Context, error) {
+	// the network given by flags or environment variables is used without asking
+	if name == "" && (Conf.Overridden("rpc_url") || Conf.Overridden("chain_id")) {
+		name = config.DefaultProfileName
+	}
+	if name == "" {
+		if !ConfigExist {
+			return ctx.NewCtx(Conf.ManualProfile(), ChainInfos), nil
 		}
+		name = prompt.MustSelectProfile(Conf)
+	}
+	if name == prompt.ManualSetup {
+		return ctx.NewCtx(Conf.ManualProfile(), ChainInfos), nil
+	}
+	p, err := Conf.Profile(name)
+	if err != nil {
+		return nil, err
 	}
+	sctx := ctx.NewCtx(p, ChainInfos)
+	ctx.PrintContext(sctx, ChainInfos)
+	return sctx, nil
 }
@@ -18,6 +18,14 @@ const (
 	AllMethod   MethodType = "All"
 )
 
+// MethodTypeOf returns whether the method reads or writes the contract state
+func MethodTypeOf(method abi.Method) MethodType {
+	if method.IsConstant() {
+		return ReadMethod
+	}
+	return WriteMethod
+}
+
 func readABIFile(filepath string) (abi.ABI, error) {
 	file, err := os.ReadFile(filepath)
 	if err != nil {
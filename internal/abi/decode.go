@@ -0,0 +1,157 @@
+package abi
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// maxNestedDepth limits the decoding of calls nested in bytes arguments, e.g. multicalls of multicalls
+const maxNestedDepth = 4
+
+// DecodedCall is calldata decoded with a method of the abis
+type DecodedCall struct {
+	// Path is the argument holding the call if it's nested in another call, e.g. data[1]
+	Path string `json:"path,omitempty"`
+	// Contracts are the names of the abis defining the method
+	Contracts []string     `json:"contracts"`
+	Method    string       `json:"method"`
+	Signature string       `json:"signature"`
+	Selector  string       `json:"selector"`
+	Args      []NamedValue `json:"args"`
+	// Calls are the calls nested in bytes arguments which decode with the abis, e.g. the calls of a multicall
+	Calls []DecodedCall `json:"calls,omitempty"`
+}
+
+// JSON returns a copy of the call whose argument values are converted with JSONValue
+func (c DecodedCall) JSON() DecodedCall {
+	c.Args = JSONNamedValues(c.Args)
+	calls := make([]DecodedCall, len(c.Calls))
+	for i, nested := range c.Calls {
+		calls[i] = nested.JSON()
+	}
+	c.Calls = calls
+	return c
+}
+
+// String returns the call and its nested calls, one per line indented by their depth
+func (c DecodedCall) String() string {
+	var sb strings.Builder
+	c.write(&sb, "")
+	return sb.String()
+}
+
+func (c DecodedCall) write(sb *strings.Builder, indent string) {
+	if c.Path != "" {
+		sb.WriteString(indent + c.Path + ": ")
+	} else {
+		sb.WriteString(indent)
+	}
+	sb.WriteString(fmt.Sprintf("%s(%s) [%s, abis: %s]\n", c.Method, FormatNamedValues(c.Args), c.Selector, strings.Join(c.Contracts, ", ")))
+	for _, nested := range c.Calls {
+		nested.write(sb, indent+"  ")
+	}
+}
+
+// DecodeCalldata decodes the calldata with every method of the abis whose selector matches.
+// The methods are grouped by signature, several calls are returned if the selector collides.
+func DecodeCalldata(abis map[string]abi.ABI, data []byte) ([]DecodedCall, error) {
+	calls := decodeCalldata(abis, data, 0)
+	if len(calls) == 0 {
+		if len(data) < 4 {
+			return nil, fmt.Errorf("calldata is shorter than a selector")
+		}
+		return nil, fmt.Errorf("no method of the abis matches selector %s", hexutil.Encode(data[:4]))
+	}
+	return calls, nil
+}
+
+func decodeCalldata(abis map[string]abi.ABI, data []byte, depth int) []DecodedCall {
+	if len(data) < 4 {
+		return nil
+	}
+	names := make([]string, 0, len(abis))
+	for name := range abis {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var calls []DecodedCall
+	bySig := make(map[string]int)
+	for _, name := range names {
+		contractABI := abis[name]
+		method, err := contractABI.MethodById(data[:4])
+		if err != nil {
+			continue
+		}
+		contract := strings.TrimSuffix(name, ".abi")
+		if i, ok := bySig[method.Sig]; ok {
+			calls[i].Contracts = append(calls[i].Contracts, contract)
+			continue
+		}
+		values, err := method.Inputs.Unpack(data[4:])
+		if err != nil {
+			continue
+		}
+		call := DecodedCall{
+			Contracts: []string{contract},
+			Method:    method.Name,
+			Signature: method.Sig,
+			Selector:  hexutil.Encode(method.ID),
+			Args:      NamedValues(method.Inputs, values),
+		}
+		if depth < maxNestedDepth {
+			for i, arg := range call.Args {
+				name := arg.Name
+				if name == "" {
+					name = fmt.Sprintf("arg%d", i)
+				}
+				call.Calls = append(call.Calls, nestedCalls(abis, name, arg.Value, depth+1)...)
+			}
+		}
+		bySig[method.Sig] = len(calls)
+		calls = append(calls, call)
+	}
+	return calls
+}
+
+// nestedCalls decodes the bytes in the value, which may be a bytes, a list or a tuple holding bytes
+func nestedCalls(abis map[string]abi.ABI, path string, value interface{}, depth int) []DecodedCall {
+	if b, ok := value.([]byte); ok {
+		var calls []DecodedCall
+		for _, call := range decodeCalldata(abis, b, depth) {
+			call.Path = path
+			calls = append(calls, call)
+		}
+		return calls
+	}
+	rv := reflect.ValueOf(value)
+	var calls []DecodedCall
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			// fixed bytes are no calldata
+			return nil
+		}
+		for i := 0; i < rv.Len(); i++ {
+			calls = append(calls, nestedCalls(abis, fmt.Sprintf("%s[%d]", path, i), rv.Index(i).Interface(), depth)...)
+		}
+	case reflect.Struct:
+		for i := 0; i < rv.NumField(); i++ {
+			field := rv.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := field.Tag.Get("json")
+			if name == "" {
+				name = field.Name
+			}
+			calls = append(calls, nestedCalls(abis, path+"."+name, rv.Field(i).Interface(), depth)...)
+		}
+	}
+	return calls
+}
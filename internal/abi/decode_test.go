@@ -0,0 +1,98 @@
+package abi
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const testABI = `[
+	{"type":"function","name":"multicall","inputs":[{"name":"data","type":"bytes[]"}],"outputs":[]},
+	{"type":"function","name":"transfer","inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"event","name":"Transfer","inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"value","type":"uint256","indexed":false}]}
+]`
+
+func parseTestABI(t *testing.T) abi.ABI {
+	t.Helper()
+	parsed, err := abi.JSON(strings.NewReader(testABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func pack(t *testing.T, contractABI abi.ABI, method string, args ...interface{}) []byte {
+	t.Helper()
+	data, err := contractABI.Pack(method, args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDecodeCalldata(t *testing.T) {
+	token := parseTestABI(t)
+	abis := map[string]abi.ABI{"Token.abi": token, "Other.abi": token}
+	to := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+
+	calls, err := DecodeCalldata(abis, pack(t, token, "transfer", to, big.NewInt(1500000)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the abis defining the same method are grouped, sorted by name
+	if len(calls) != 1 || calls[0].Method != "transfer" || strings.Join(calls[0].Contracts, ",") != "Other,Token" {
+		t.Fatalf("unexpected calls: %+v", calls)
+	}
+	if got := FormatNamedValues(calls[0].Args); got != "to: "+to.Hex()+", value: 1500000" {
+		t.Errorf("unexpected args: %s", got)
+	}
+
+	if _, err = DecodeCalldata(abis, []byte{0x01, 0x02}); err == nil {
+		t.Error("expected an error for calldata shorter than a selector")
+	}
+	if _, err = DecodeCalldata(abis, []byte{0x01, 0x02, 0x03, 0x04}); err == nil || !strings.Contains(err.Error(), "0x01020304") {
+		t.Errorf("expected an error naming the unknown selector, got %v", err)
+	}
+}
+
+func TestDecodeCalldataNestedDepth(t *testing.T) {
+	token := parseTestABI(t)
+	abis := map[string]abi.ABI{"Token.abi": token}
+	to := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+
+	// a transfer wrapped in more multicalls than decoded
+	data := pack(t, token, "transfer", to, big.NewInt(1))
+	for i := 0; i < maxNestedDepth+2; i++ {
+		data = pack(t, token, "multicall", [][]byte{{0xde, 0xad}, data})
+	}
+	calls, err := DecodeCalldata(abis, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	call, depth := calls[0], 0
+	for len(call.Calls) > 0 {
+		if len(call.Calls) != 1 || call.Calls[0].Path != "data[1]" {
+			t.Fatalf("unexpected nested calls at depth %d: %+v", depth, call.Calls)
+		}
+		call = call.Calls[0]
+		depth++
+	}
+	if depth != maxNestedDepth || call.Method != "multicall" {
+		t.Errorf("decoded %d nested calls down to %s, want %d down to multicall", depth, call.Method, maxNestedDepth)
+	}
+
+	// within the depth the innermost call is decoded
+	calls, err = DecodeCalldata(abis, pack(t, token, "multicall", [][]byte{pack(t, token, "transfer", to, big.NewInt(1))}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nested := calls[0].Calls; len(nested) != 1 || nested[0].Method != "transfer" || nested[0].Path != "data[0]" {
+		t.Errorf("unexpected nested calls: %+v", nested)
+	}
+	if got := calls[0].String(); !strings.Contains(got, "\n  data[0]: transfer(") {
+		t.Errorf("nested call isn't indented: %q", got)
+	}
+}
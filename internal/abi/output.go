@@ -0,0 +1,207 @@
+package abi
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// NamedValue is a decoded abi value with its argument name and type
+type NamedValue struct {
+	Name  string      `json:"name"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+	// Formatted is an optional human readable form of the value, e.g. a scaled token amount
+	Formatted string `json:"formatted,omitempty"`
+}
+
+// DecodedEvent is a contract event log decoded with the contract's ABI
+type DecodedEvent struct {
+	Name        string `json:"event"`
+	Signature   string `json:"signature"`
+	Address     string `json:"address"`
+	BlockNumber uint64 `json:"blockNumber"`
+	TxHash      string `json:"txHash"`
+	LogIndex    uint   `json:"logIndex"`
+	// Timestamp is the unix time of the block, it's only set for logs of past blocks
+	Timestamp uint64 `json:"timestamp,omitempty"`
+	// Removed is true if the log was reverted by a chain reorganization
+	Removed bool         `json:"removed,omitempty"`
+	Args    []NamedValue `json:"args"`
+}
+
+// String returns a single line representation of the event, e.g. Transfer(from: 0x.., to: 0x.., value: 1)
+func (e DecodedEvent) String() string {
+	return e.Name + "(" + FormatNamedValues(e.Args) + ")"
+}
+
+// JSON returns a copy of the event whose argument values are converted with JSONValue
+func (e DecodedEvent) JSON() DecodedEvent {
+	e.Args = JSONNamedValues(e.Args)
+	return e
+}
+
+// NamedValues pairs the unpacked values with their arguments
+func NamedValues(args abi.Arguments, values []interface{}) []NamedValue {
+	named := make([]NamedValue, 0, len(values))
+	for i, v := range values {
+		nv := NamedValue{Value: v}
+		if i < len(args) {
+			nv.Name = args[i].Name
+			nv.Type = args[i].Type.String()
+		}
+		named = append(named, nv)
+	}
+	return named
+}
+
+// FormatNamedValues returns the values formatted as "name: value" separated by commas
+func FormatNamedValues(values []NamedValue) string {
+	parts := make([]string, 0, len(values))
+	for i, v := range values {
+		name := v.Name
+		if name == "" {
+			name = fmt.Sprintf("arg%d", i)
+		}
+		if v.Formatted != "" {
+			parts = append(parts, fmt.Sprintf("%s: %v (%s)", name, FormatValue(v.Value), v.Formatted))
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s: %v", name, FormatValue(v.Value)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// JSONNamedValues converts every value with JSONValue
+func JSONNamedValues(values []NamedValue) []NamedValue {
+	out := make([]NamedValue, len(values))
+	for i, v := range values {
+		out[i] = NamedValue{Name: v.Name, Type: v.Type, Value: JSONValue(v.Value), Formatted: v.Formatted}
+	}
+	return out
+}
+
+// FormatValue returns a human readable representation of an unpacked abi value.
+// Byte arrays are printed as hex instead of a list of numbers.
+func FormatValue(v interface{}) string {
+	if list, ok := JSONValue(v).([]interface{}); ok {
+		parts := make([]string, len(list))
+		for i, e := range list {
+			parts[i] = fmt.Sprintf("%v", e)
+		}
+		return "[" + strings.Join(parts, ",") + "]"
+	}
+	return fmt.Sprintf("%v", JSONValue(v))
+}
+
+// JSONValue converts an unpacked abi value to a value that marshals losslessly to json.
+// Big integers and 64 bit integers become decimal strings, bytes become hex strings,
+// and tuples become objects keyed by their field names.
+func JSONValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case nil:
+		return nil
+	case *big.Int:
+		if val == nil {
+			return nil
+		}
+		return val.String()
+	case common.Address:
+		return val.Hex()
+	case common.Hash:
+		return val.Hex()
+	case []byte:
+		return hexutil.Encode(val)
+	case uint64, int64:
+		return fmt.Sprintf("%d", val)
+	case string, bool, uint8, uint16, uint32, int8, int16, int32:
+		return val
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return nil
+		}
+		return JSONValue(rv.Elem().Interface())
+	case reflect.Array:
+		// fixed bytes (bytes32, bytes4, ...)
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			return hexutil.Encode(b)
+		}
+		fallthrough
+	case reflect.Slice:
+		out := make([]interface{}, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			out[i] = JSONValue(rv.Index(i).Interface())
+		}
+		return out
+	case reflect.Struct:
+		out := make(map[string]interface{}, rv.NumField())
+		for i := 0; i < rv.NumField(); i++ {
+			field := rv.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name := field.Tag.Get("json")
+			if name == "" {
+				name = field.Name
+			}
+			out[name] = JSONValue(rv.Field(i).Interface())
+		}
+		return out
+	}
+	return v
+}
+
+// DecodeLog decodes the given log with the contract ABI.
+// It returns an error if the log is not emitted by one of the ABI's events.
+func DecodeLog(contractABI abi.ABI, l types.Log) (*DecodedEvent, error) {
+	if len(l.Topics) == 0 {
+		return nil, fmt.Errorf("log has no topics (anonymous event)")
+	}
+	event, err := contractABI.EventByID(l.Topics[0])
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]interface{})
+	if len(l.Data) > 0 {
+		if err = event.Inputs.NonIndexed().UnpackIntoMap(values, l.Data); err != nil {
+			return nil, fmt.Errorf("failed to unpack %s data: %v", event.Name, err)
+		}
+	}
+	var indexed abi.Arguments
+	for _, input := range event.Inputs {
+		if input.Indexed {
+			indexed = append(indexed, input)
+		}
+	}
+	if err = abi.ParseTopicsIntoMap(values, indexed, l.Topics[1:]); err != nil {
+		return nil, fmt.Errorf("failed to parse %s topics: %v", event.Name, err)
+	}
+
+	args := make([]NamedValue, 0, len(event.Inputs))
+	for _, input := range event.Inputs {
+		args = append(args, NamedValue{Name: input.Name, Type: input.Type.String(), Value: values[input.Name]})
+	}
+	return &DecodedEvent{
+		Name:        event.Name,
+		Signature:   event.Sig,
+		Address:     l.Address.Hex(),
+		BlockNumber: l.BlockNumber,
+		TxHash:      l.TxHash.Hex(),
+		LogIndex:    l.Index,
+		Removed:     l.Removed,
+		Args:        args,
+	}, nil
+}
@@ -0,0 +1,52 @@
+package abi
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestDecodeLog(t *testing.T) {
+	token := parseTestABI(t)
+	event := token.Events["Transfer"]
+	from := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	to := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	data, err := event.Inputs.NonIndexed().Pack(big.NewInt(1500000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := types.Log{
+		Address:     common.HexToAddress("0x2222222222222222222222222222222222222222"),
+		Topics:      []common.Hash{event.ID, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())},
+		Data:        data,
+		BlockNumber: 7,
+		Index:       2,
+	}
+
+	decoded, err := DecodeLog(token, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Name != "Transfer" || decoded.Signature != "Transfer(address,address,uint256)" || decoded.Address != l.Address.Hex() ||
+		decoded.BlockNumber != 7 || decoded.LogIndex != 2 {
+		t.Errorf("unexpected event: %+v", decoded)
+	}
+	// the arguments keep the order of the event, indexed or not
+	if got, want := decoded.String(), "Transfer(from: "+from.Hex()+", to: "+to.Hex()+", value: 1500000)"; got != want {
+		t.Errorf("String() = %s, want %s", got, want)
+	}
+
+	if _, err = DecodeLog(token, types.Log{}); err == nil || !strings.Contains(err.Error(), "no topics") {
+		t.Errorf("expected an error for an anonymous log, got %v", err)
+	}
+	if _, err = DecodeLog(token, types.Log{Topics: []common.Hash{{0x01}}}); err == nil {
+		t.Error("expected an error for an event of another abi")
+	}
+	l.Data = l.Data[:16]
+	if _, err = DecodeLog(token, l); err == nil {
+		t.Error("expected an error for truncated data")
+	}
+}
@@ -0,0 +1,470 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/zsystm/solizard/internal/log"
+	"github.com/zsystm/solizard/lib"
+)
+
+// ErrRangeLimit is returned by FilterLogs when the endpoint limits the block range or the number of results,
+// the query should be split into smaller ranges
+var ErrRangeLimit = errors.New("log query exceeds the limit of the endpoint")
+
+// HealthCheckTimeout is the time an endpoint has to answer eth_chainId to be considered healthy
+const HealthCheckTimeout = 5 * time.Second
+
+// json-rpc error codes returned by endpoints which can't serve the request,
+// the request is retried on the next endpoint unless it's a log query exceeding the range limit
+const (
+	errCodeMethodNotFound = -32601
+	errCodeLimitExceeded  = -32005
+)
+
+// EthClient is the chain client solizard works with.
+// It's implemented by Client, tests use the client of go-ethereum's simulated backend.
+type EthClient interface {
+	ChainID(ctx context.Context) (*big.Int, error)
+	CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+	CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error)
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+	SuggestGasPrice(ctx context.Context) (*big.Int, error)
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+	TransactionByHash(ctx context.Context, hash common.Hash) (tx *types.Transaction, isPending bool, err error)
+	BlockNumber(ctx context.Context) (uint64, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error)
+	SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error)
+}
+
+var _ EthClient = (*Client)(nil)
+
+// Client is an ethclient connected to one of several rpc endpoints of the same chain.
+// When a request fails because of the endpoint (connection errors, http errors, rate limits),
+// it switches to the next healthy endpoint and retries the request.
+// The ${VAR} placeholders of the endpoints are only expanded when dialing,
+// the client reports the endpoints and their errors with the placeholders.
+type Client struct {
+	mu        sync.Mutex
+	endpoints []string
+	current   int
+	eth       *ethclient.Client
+	chainId   *big.Int
+	// timeout is the timeout of each request attempt, zero means no timeout
+	timeout time.Duration
+}
+
+// Dial connects to the first healthy endpoint, the other endpoints are used for failover
+func Dial(endpoints ...string) (*Client, error) {
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no rpc endpoint given")
+	}
+	c := &Client{endpoints: dedup(endpoints)}
+	eth, idx, chainId, err := c.dialFrom(context.Background(), 0, -1, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.eth, c.current, c.chainId = eth, idx, chainId
+	return c, nil
+}
+
+// SetTimeout sets the timeout of each request, a request which times out is retried on the next endpoint
+func (c *Client) SetTimeout(timeout time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.timeout = timeout
+}
+
+// URL returns the endpoint the client is currently connected to
+func (c *Client) URL() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.endpoints[c.current]
+}
+
+// ChainId returns the chain id reported by the endpoints when connecting
+func (c *Client) ChainId() *big.Int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return new(big.Int).Set(c.chainId)
+}
+
+func (c *Client) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.eth != nil {
+		c.eth.Close()
+	}
+}
+
+// dialFrom connects to the first healthy endpoint starting at index from, skipping the endpoint at index skip.
+// The endpoint must serve chainId unless it's nil.
+func (c *Client) dialFrom(ctx context.Context, from, skip int, chainId *big.Int) (*ethclient.Client, int, *big.Int, error) {
+	var errs []string
+	for i := 0; i < len(c.endpoints); i++ {
+		idx := (from + i) % len(c.endpoints)
+		if idx == skip {
+			continue
+		}
+		eth, id, err := dialHealthy(ctx, c.endpoints[idx])
+		if err == nil && chainId != nil && id.Cmp(chainId) != 0 {
+			eth.Close()
+			err = fmt.Errorf("chain id %d differs from %d", id, chainId)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", c.endpoints[idx], err))
+			continue
+		}
+		return eth, idx, id, nil
+	}
+	return nil, 0, nil, fmt.Errorf("no healthy rpc endpoint (%s)", strings.Join(errs, "; "))
+}
+
+// failover switches to the next healthy endpoint if the client is still connected to the failed one.
+// The endpoints are dialed without holding the lock, so the other requests aren't blocked meanwhile.
+func (c *Client) failover(ctx context.Context, failed int, reason error) error {
+	c.mu.Lock()
+	current, chainId := c.current, c.chainId
+	c.mu.Unlock()
+	if current != failed {
+		// already switched by another request
+		return nil
+	}
+	if len(c.endpoints) == 1 {
+		return reason
+	}
+	eth, idx, _, err := c.dialFrom(ctx, failed+1, failed, chainId)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	if c.current != failed {
+		// another request switched while dialing
+		c.mu.Unlock()
+		eth.Close()
+		return nil
+	}
+	old := c.eth
+	c.eth, c.current = eth, idx
+	c.mu.Unlock()
+	old.Close()
+	log.Error(fmt.Sprintf("rpc endpoint %s failed (reason: %v), switched to %s\n", c.endpoints[failed], reason, c.endpoints[idx]))
+	return nil
+}
+
+func (c *Client) conn() (*ethclient.Client, int, time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.eth, c.current, c.timeout
+}
+
+// do runs the request, failing over to the next endpoints until it succeeds,
+// fails with an error which is not caused by the endpoint, or every endpoint was tried
+func do[T any](ctx context.Context, c *Client, request func(context.Context, *ethclient.Client) (T, error)) (T, error) {
+	var res T
+	var err error
+	for tries := 0; tries < len(c.endpoints); tries++ {
+		eth, idx, timeout := c.conn()
+		res, err = attempt(ctx, eth, timeout, request)
+		if !shouldFailover(ctx, err) {
+			return res, err
+		}
+		err = hideSecrets(err, c.endpoints[idx])
+		if ferr := c.failover(ctx, idx, err); ferr != nil {
+			return res, fmt.Errorf("%w (failover: %v)", err, ferr)
+		}
+	}
+	return res, err
+}
+
+// attempt runs the request once, it's aborted when ctx is cancelled or the timeout expires
+func attempt[T any](ctx context.Context, eth *ethclient.Client, timeout time.Duration, request func(context.Context, *ethclient.Client) (T, error)) (T, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return request(ctx, eth)
+}
+
+// shouldFailover returns true if the error is caused by the endpoint rather than the request
+func shouldFailover(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil || errors.Is(err, ethereum.NotFound) || errors.Is(err, ErrRangeLimit) {
+		return false
+	}
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		// the node answered, e.g. execution reverted or nonce too low
+		code := rpcErr.ErrorCode()
+		return code == errCodeMethodNotFound || code == errCodeLimitExceeded
+	}
+	return true
+}
+
+// dialHealthy connects to the endpoint and checks it answers eth_chainId,
+// the placeholders of the endpoint are expanded with the environment variables
+func dialHealthy(ctx context.Context, endpoint string) (*ethclient.Client, *big.Int, error) {
+	ctx, cancel := context.WithTimeout(ctx, HealthCheckTimeout)
+	defer cancel()
+	rpcURL, err := lib.ExpandRPC(endpoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	eth, err := ethclient.DialContext(ctx, rpcURL)
+	if err != nil {
+		return nil, nil, hideSecrets(err, endpoint)
+	}
+	chainId, err := eth.ChainID(ctx)
+	if err != nil {
+		eth.Close()
+		return nil, nil, hideSecrets(err, endpoint)
+	}
+	return eth, chainId, nil
+}
+
+// redactedError is an error whose message shows the rpc url with its placeholders rather than expanded
+type redactedError struct {
+	err              error
+	expanded, rpcURL string
+}
+
+func (e *redactedError) Error() string {
+	return strings.ReplaceAll(e.err.Error(), e.expanded, e.rpcURL)
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// hideSecrets replaces the expanded endpoint in the message of err, e.g. of a failed http request,
+// by the endpoint with its placeholders, so the api keys of environment variables aren't printed
+func hideSecrets(err error, endpoint string) error {
+	if err == nil {
+		return nil
+	}
+	expanded, xerr := lib.ExpandRPC(endpoint)
+	if xerr != nil || expanded == endpoint || !strings.Contains(err.Error(), expanded) {
+		return err
+	}
+	return &redactedError{err: err, expanded: expanded, rpcURL: endpoint}
+}
+
+// Healthy returns the endpoints answering eth_chainId with the expected chain id in their original order.
+// The endpoints are checked concurrently, chainId is not checked if it's zero.
+func Healthy(ctx context.Context, endpoints []string, chainId uint64) []string {
+	ok := make([]bool, len(endpoints))
+	var wg sync.WaitGroup
+	for i, endpoint := range endpoints {
+		wg.Add(1)
+		go func(i int, endpoint string) {
+			defer wg.Done()
+			eth, id, err := dialHealthy(ctx, endpoint)
+			if err != nil {
+				return
+			}
+			eth.Close()
+			ok[i] = chainId == 0 || id.Uint64() == chainId
+		}(i, endpoint)
+	}
+	wg.Wait()
+
+	var healthy []string
+	for i, endpoint := range endpoints {
+		if ok[i] {
+			healthy = append(healthy, endpoint)
+		}
+	}
+	return healthy
+}
+
+func dedup(endpoints []string) []string {
+	seen := make(map[string]bool, len(endpoints))
+	out := make([]string, 0, len(endpoints))
+	for _, e := range endpoints {
+		if e == "" || seen[e] {
+			continue
+		}
+		seen[e] = true
+		out = append(out, e)
+	}
+	return out
+}
+
+func (c *Client) ChainID(ctx context.Context) (*big.Int, error) {
+	return do(ctx, c, func(ctx context.Context, eth *ethclient.Client) (*big.Int, error) {
+		return eth.ChainID(ctx)
+	})
+}
+
+func (c *Client) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return do(ctx, c, func(ctx context.Context, eth *ethclient.Client) ([]byte, error) {
+		return eth.CallContract(ctx, msg, blockNumber)
+	})
+}
+
+func (c *Client) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	return do(ctx, c, func(ctx context.Context, eth *ethclient.Client) ([]byte, error) {
+		return eth.CodeAt(ctx, account, blockNumber)
+	})
+}
+
+func (c *Client) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	return do(ctx, c, func(ctx context.Context, eth *ethclient.Client) (uint64, error) {
+		return eth.NonceAt(ctx, account, blockNumber)
+	})
+}
+
+func (c *Client) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return do(ctx, c, func(ctx context.Context, eth *ethclient.Client) (uint64, error) {
+		return eth.PendingNonceAt(ctx, account)
+	})
+}
+
+func (c *Client) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	return do(ctx, c, func(ctx context.Context, eth *ethclient.Client) (*big.Int, error) {
+		return eth.BalanceAt(ctx, account, blockNumber)
+	})
+}
+
+func (c *Client) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return do(ctx, c, func(ctx context.Context, eth *ethclient.Client) (*big.Int, error) {
+		return eth.SuggestGasPrice(ctx)
+	})
+}
+
+func (c *Client) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return do(ctx, c, func(ctx context.Context, eth *ethclient.Client) (*big.Int, error) {
+		return eth.SuggestGasTipCap(ctx)
+	})
+}
+
+// SendTransaction sends the signed transaction, it's resent to the next endpoint after a connection error or a timeout.
+// The failed endpoint may have accepted the transaction before failing, so the resent transaction
+// refused as already known, or for a used nonce while the next endpoint knows it, is sent.
+func (c *Client) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	resent := false
+	_, err := do(ctx, c, func(ctx context.Context, eth *ethclient.Client) (struct{}, error) {
+		err := eth.SendTransaction(ctx, tx)
+		if err != nil && resent && alreadySent(ctx, eth, tx, err) {
+			return struct{}{}, nil
+		}
+		resent = true
+		return struct{}{}, err
+	})
+	return err
+}
+
+// alreadySent returns true if the endpoint refused to send the transaction because it already has it
+func alreadySent(ctx context.Context, eth *ethclient.Client, tx *types.Transaction, err error) bool {
+	var rpcErr rpc.Error
+	if !errors.As(err, &rpcErr) {
+		return false
+	}
+	msg := strings.ToLower(rpcErr.Error())
+	if strings.Contains(msg, "already known") || strings.Contains(msg, "known transaction") {
+		return true
+	}
+	if !strings.Contains(msg, "nonce too low") {
+		return false
+	}
+	// the nonce is used by the transaction itself if it's already mined, or by another one
+	_, _, err = eth.TransactionByHash(ctx, tx.Hash())
+	return err == nil
+}
+
+func (c *Client) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	return do(ctx, c, func(ctx context.Context, eth *ethclient.Client) (*types.Receipt, error) {
+		return eth.TransactionReceipt(ctx, txHash)
+	})
+}
+
+// TransactionByHash returns the transaction with the hash and whether it's still pending
+func (c *Client) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	type result struct {
+		tx        *types.Transaction
+		isPending bool
+	}
+	res, err := do(ctx, c, func(ctx context.Context, eth *ethclient.Client) (result, error) {
+		tx, isPending, err := eth.TransactionByHash(ctx, hash)
+		return result{tx, isPending}, err
+	})
+	return res.tx, res.isPending, err
+}
+
+func (c *Client) BlockNumber(ctx context.Context) (uint64, error) {
+	return do(ctx, c, func(ctx context.Context, eth *ethclient.Client) (uint64, error) {
+		return eth.BlockNumber(ctx)
+	})
+}
+
+func (c *Client) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return do(ctx, c, func(ctx context.Context, eth *ethclient.Client) (*types.Header, error) {
+		return eth.HeaderByNumber(ctx, number)
+	})
+}
+
+// FilterLogs returns the logs matching the query, errors of endpoints limiting the block range
+// or the number of results wrap ErrRangeLimit instead of failing over
+func (c *Client) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	return do(ctx, c, func(ctx context.Context, eth *ethclient.Client) ([]types.Log, error) {
+		logs, err := eth.FilterLogs(ctx, q)
+		if err != nil && isRangeLimit(err) {
+			return nil, fmt.Errorf("%w (reason: %v)", ErrRangeLimit, err)
+		}
+		return logs, err
+	})
+}
+
+// rangeLimitMessages are parts of the error messages of common providers for too large log queries
+var rangeLimitMessages = []string{
+	"block range",
+	"range is too large",
+	"range too large",
+	"query returned more than",
+	"too many results",
+	"response size exceeded",
+	"exceed maximum block range",
+	"limited to",
+}
+
+// isRangeLimit returns true if the endpoint refused the log query because of its range or result size
+func isRangeLimit(err error) bool {
+	var rpcErr rpc.Error
+	if !errors.As(err, &rpcErr) {
+		return false
+	}
+	if rpcErr.ErrorCode() == errCodeLimitExceeded {
+		return true
+	}
+	msg := strings.ToLower(rpcErr.Error())
+	for _, m := range rangeLimitMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// SubscribeFilterLogs subscribes to the logs matching the query on the current endpoint.
+// It needs a websocket endpoint, the subscription isn't failed over.
+func (c *Client) SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	eth, _, _ := c.conn()
+	return eth.SubscribeFilterLogs(ctx, q, ch)
+}
@@ -0,0 +1,300 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const testChainId = 1337
+
+// jsonError is the error of a json-rpc response
+type jsonError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// handler answers a json-rpc request of a fake endpoint other than eth_chainId
+type handler func(r *http.Request, method string) (interface{}, *jsonError)
+
+// fakeEndpoint is a json-rpc endpoint serving the test chain, it records the methods called on it
+type fakeEndpoint struct {
+	*httptest.Server
+	mu      sync.Mutex
+	methods []string
+}
+
+func newFakeEndpoint(t *testing.T, chainId uint64, handle handler) *fakeEndpoint {
+	t.Helper()
+	e := &fakeEndpoint{}
+	e.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Id     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var result interface{}
+		var rpcErr *jsonError
+		if req.Method == "eth_chainId" {
+			result = hexutil.Uint64(chainId)
+		} else {
+			e.mu.Lock()
+			e.methods = append(e.methods, req.Method)
+			e.mu.Unlock()
+			if result, rpcErr = handle(r, req.Method); r.Context().Err() != nil {
+				return
+			}
+		}
+		res := map[string]interface{}{"jsonrpc": "2.0", "id": req.Id}
+		if rpcErr != nil {
+			res["error"] = rpcErr
+		} else {
+			res["result"] = result
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	}))
+	t.Cleanup(e.Close)
+	return e
+}
+
+// called returns the methods called on the endpoint, eth_chainId excepted
+func (e *fakeEndpoint) called() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.methods...)
+}
+
+// hanging never answers, the request times out
+func hanging(r *http.Request, _ string) (interface{}, *jsonError) {
+	<-r.Context().Done()
+	return nil, nil
+}
+
+func answering(result interface{}) handler {
+	return func(*http.Request, string) (interface{}, *jsonError) {
+		return result, nil
+	}
+}
+
+func failing(code int, message string) handler {
+	return func(*http.Request, string) (interface{}, *jsonError) {
+		return nil, &jsonError{Code: code, Message: message}
+	}
+}
+
+func dialTest(t *testing.T, endpoints ...string) *Client {
+	t.Helper()
+	c, err := Dial(endpoints...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.Close)
+	c.SetTimeout(200 * time.Millisecond)
+	return c
+}
+
+func TestFailoverOnTimeout(t *testing.T) {
+	slow := newFakeEndpoint(t, testChainId, hanging)
+	next := newFakeEndpoint(t, testChainId, answering(hexutil.Uint64(42)))
+	c := dialTest(t, slow.URL, next.URL)
+
+	n, err := c.BlockNumber(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 42 {
+		t.Errorf("block number = %d, want 42", n)
+	}
+	if c.URL() != next.URL {
+		t.Errorf("connected to %s, want %s", c.URL(), next.URL)
+	}
+}
+
+func TestFailoverErrorChain(t *testing.T) {
+	slow := newFakeEndpoint(t, testChainId, hanging)
+	next := newFakeEndpoint(t, testChainId, answering(nil))
+	c := dialTest(t, slow.URL, next.URL)
+	// the next endpoint is down when failing over
+	next.Close()
+
+	_, err := c.BlockNumber(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "failover") {
+		t.Fatalf("expected a failover error, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("the error of the request isn't wrapped: %v", err)
+	}
+}
+
+func TestPlaceholders(t *testing.T) {
+	const secret = "s3cr3t"
+	t.Setenv("SOLIZARD_TEST_RPC_KEY", secret)
+	endpoint := newFakeEndpoint(t, testChainId, answering(hexutil.Uint64(42)))
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	// the client reports the endpoints with their placeholders
+	template := endpoint.URL + "/${SOLIZARD_TEST_RPC_KEY}"
+	c := dialTest(t, template)
+	if c.URL() != template {
+		t.Errorf("URL() = %s, want %s", c.URL(), template)
+	}
+	if _, err := c.BlockNumber(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Dial(down.URL + "/${SOLIZARD_TEST_RPC_KEY}")
+	if err == nil || strings.Contains(err.Error(), secret) || !strings.Contains(err.Error(), "${SOLIZARD_TEST_RPC_KEY}") {
+		t.Errorf("expected an error without the api key, got %v", err)
+	}
+	if _, err = Dial(endpoint.URL + "/${SOLIZARD_TEST_UNSET}"); err == nil || !strings.Contains(err.Error(), "SOLIZARD_TEST_UNSET") {
+		t.Errorf("expected an error for the unset variable, got %v", err)
+	}
+}
+
+func TestNoFailoverOnRPCError(t *testing.T) {
+	reverting := newFakeEndpoint(t, testChainId, failing(3, "execution reverted"))
+	next := newFakeEndpoint(t, testChainId, answering("0x"))
+	c := dialTest(t, reverting.URL, next.URL)
+
+	to := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	_, err := c.CallContract(context.Background(), ethereum.CallMsg{To: &to}, nil)
+	if err == nil || !strings.Contains(err.Error(), "execution reverted") {
+		t.Fatalf("expected the error of the endpoint, got %v", err)
+	}
+	if c.URL() != reverting.URL {
+		t.Errorf("switched to %s after a json-rpc error", c.URL())
+	}
+	if called := next.called(); len(called) != 0 {
+		t.Errorf("the request was retried on the next endpoint: %v", called)
+	}
+}
+
+func TestFailoverOnUnsupportedMethod(t *testing.T) {
+	limited := newFakeEndpoint(t, testChainId, failing(errCodeMethodNotFound, "the method eth_feeHistory does not exist"))
+	next := newFakeEndpoint(t, testChainId, answering(hexutil.Big(*big.NewInt(7))))
+	c := dialTest(t, limited.URL, next.URL)
+
+	tip, err := c.SuggestGasTipCap(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tip.Int64() != 7 {
+		t.Errorf("tip = %v, want 7", tip)
+	}
+}
+
+func signedTx(t *testing.T) *types.Transaction {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	to := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	tx, err := types.SignTx(types.NewTx(&types.LegacyTx{Nonce: 3, GasPrice: big.NewInt(1), Gas: 21000, To: &to}),
+		types.LatestSignerForChainID(big.NewInt(testChainId)), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func TestSendTransactionAfterTimeout(t *testing.T) {
+	tx := signedTx(t)
+	tests := []struct {
+		name string
+		// next answers the resent transaction
+		next    handler
+		wantErr bool
+	}{
+		{
+			name: "already known",
+			next: failing(-32000, "already known"),
+		},
+		{
+			name: "nonce used by the transaction",
+			next: func(_ *http.Request, method string) (interface{}, *jsonError) {
+				if method == "eth_getTransactionByHash" {
+					return tx, nil
+				}
+				return nil, &jsonError{Code: -32000, Message: "nonce too low: next nonce 4, tx nonce 3"}
+			},
+		},
+		{
+			name: "nonce used by another transaction",
+			next: func(_ *http.Request, method string) (interface{}, *jsonError) {
+				if method == "eth_getTransactionByHash" {
+					return nil, nil
+				}
+				return nil, &jsonError{Code: -32000, Message: "nonce too low: next nonce 4, tx nonce 3"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "resent",
+			next: answering(tx.Hash()),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the first endpoint may have accepted the transaction before timing out
+			slow := newFakeEndpoint(t, testChainId, hanging)
+			next := newFakeEndpoint(t, testChainId, tt.next)
+			c := dialTest(t, slow.URL, next.URL)
+
+			err := c.SendTransaction(context.Background(), tx)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SendTransaction() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if called := next.called(); len(called) == 0 || called[0] != "eth_sendRawTransaction" {
+				t.Errorf("the transaction wasn't resent to the next endpoint: %v", called)
+			}
+		})
+	}
+}
+
+func TestSendTransactionRefused(t *testing.T) {
+	// a node refusing the transaction of the first attempt didn't send it
+	refusing := newFakeEndpoint(t, testChainId, failing(-32000, "already known"))
+	next := newFakeEndpoint(t, testChainId, answering(nil))
+	c := dialTest(t, refusing.URL, next.URL)
+
+	if err := c.SendTransaction(context.Background(), signedTx(t)); err == nil {
+		t.Error("expected the error of the endpoint")
+	}
+	if called := next.called(); len(called) != 0 {
+		t.Errorf("the transaction was resent: %v", called)
+	}
+}
+
+func TestHealthy(t *testing.T) {
+	good := newFakeEndpoint(t, testChainId, answering(nil))
+	otherChain := newFakeEndpoint(t, 1, answering(nil))
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	endpoints := []string{down.URL, otherChain.URL, good.URL}
+	if got := Healthy(context.Background(), endpoints, testChainId); len(got) != 1 || got[0] != good.URL {
+		t.Errorf("Healthy() = %v, want [%s]", got, good.URL)
+	}
+	if got := Healthy(context.Background(), endpoints, 0); len(got) != 2 || got[0] != otherChain.URL || got[1] != good.URL {
+		t.Errorf("Healthy() without chain id = %v, want [%s %s]", got, otherChain.URL, good.URL)
+	}
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// BookFormat is the file format of imported and exported address books
+type BookFormat string
+
+const (
+	BookFormatJSON BookFormat = "json"
+	BookFormatCSV  BookFormat = "csv"
+)
+
+// csvHeader is the header of csv address books, tags are separated by semicolons
+var csvHeader = []string{"chain_id", "abi", "address", "label", "tags", "notes"}
+
+// BookFormatFromPath returns the format of the address book file by its extension, json by default
+func BookFormatFromPath(path string) BookFormat {
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return BookFormatCSV
+	}
+	return BookFormatJSON
+}
+
+// ImportAddressBook reads an address book file in json (either format of contract_infos.json) or csv format.
+// The entries are not validated, use Sanitize to drop the invalid ones.
+func ImportAddressBook(path string) (AddressBook, error) {
+	if BookFormatFromPath(path) == BookFormatJSON {
+		return ReadAddressBook(path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse csv: %v", err)
+	}
+	book := make(AddressBook)
+	for i, record := range records {
+		if i == 0 && len(record) > 0 && record[0] == csvHeader[0] {
+			continue
+		}
+		if len(record) < 3 {
+			return nil, fmt.Errorf("line %d: expected at least chain_id, abi and address columns", i+1)
+		}
+		chainId, err := strconv.ParseUint(strings.TrimSpace(record[0]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid chain id: %v", i+1, err)
+		}
+		ci := ContractInfo{
+			Abi:     strings.TrimSpace(record[1]),
+			Address: strings.TrimSpace(record[2]),
+		}
+		if len(record) > 3 {
+			ci.Label = strings.TrimSpace(record[3])
+		}
+		if len(record) > 4 && record[4] != "" {
+			ci.Tags = strings.Split(record[4], ";")
+		}
+		if len(record) > 5 {
+			ci.Notes = strings.TrimSpace(record[5])
+		}
+		book.Add(chainId, ci)
+	}
+	return book, nil
+}
+
+// ExportAddressBook writes the address book to w in the given format
+func ExportAddressBook(w io.Writer, book AddressBook, format BookFormat) error {
+	switch format {
+	case BookFormatJSON:
+		data, err := json.MarshalIndent(book, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal contract infos: %v", err)
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	case BookFormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvHeader); err != nil {
+			return err
+		}
+		for _, chainId := range book.ChainIds() {
+			for _, ci := range book[chainId] {
+				record := []string{strconv.FormatUint(chainId, 10), ci.Abi, ci.Address, ci.Label, strings.Join(ci.Tags, ";"), ci.Notes}
+				if err := cw.Write(record); err != nil {
+					return err
+				}
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("unknown address book format %q (available: %s, %s)", format, BookFormatJSON, BookFormatCSV)
+	}
+}
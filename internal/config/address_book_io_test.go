@@ -0,0 +1,105 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAddressBookRoundTrip(t *testing.T) {
+	book := AddressBook{
+		AnyChain: {{Abi: "FiatToken", Address: usdc}},
+		1: {
+			{Abi: "TetherToken", Address: usdt, Label: "usdt", Tags: []string{"stable", "erc20"}, Notes: `issued by "Tether", on ethereum`},
+			{Abi: "FiatToken", Address: usdc, Notes: "line one\nline two"},
+		},
+		11155111: {{Abi: "TetherToken", Address: usdt, Label: "test usdt"}},
+	}
+	for _, format := range []BookFormat{BookFormatCSV, BookFormatJSON} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := ExportAddressBook(&buf, book, format); err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), "book."+string(format))
+			if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+				t.Fatal(err)
+			}
+			imported, err := ImportAddressBook(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(imported, book) {
+				t.Errorf("imported book = %+v, want %+v\nfile:\n%s", imported, book, buf.String())
+			}
+		})
+	}
+}
+
+func TestImportCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		want    AddressBook
+		wantErr string
+	}{
+		{
+			name: "without header, short rows",
+			csv: "1, TetherToken ," + usdt + "\n" +
+				"1,FiatToken," + usdc + ",usdc\n" +
+				"5,TetherToken," + usdt + ",,stable;erc20\n",
+			want: AddressBook{
+				1: {{Abi: "TetherToken", Address: usdt}, {Abi: "FiatToken", Address: usdc, Label: "usdc"}},
+				5: {{Abi: "TetherToken", Address: usdt, Tags: []string{"stable", "erc20"}}},
+			},
+		},
+		{
+			name: "header and padded notes",
+			csv: "chain_id,abi,address,label,tags,notes\n" +
+				"1,TetherToken," + usdt + ",usdt,stable,\"  paused, see \"\"notice\"\"  \"\n",
+			want: AddressBook{1: {{Abi: "TetherToken", Address: usdt, Label: "usdt", Tags: []string{"stable"}, Notes: `paused, see "notice"`}}},
+		},
+		{
+			// invalid entries are imported, they are dropped by Sanitize
+			name: "invalid address",
+			csv:  "1,TetherToken,0x1234\n",
+			want: AddressBook{1: {{Abi: "TetherToken", Address: "0x1234"}}},
+		},
+		{name: "missing address", csv: "1,TetherToken\n", wantErr: "line 1: expected at least"},
+		{name: "invalid chain id", csv: "chain_id,abi,address\nmainnet,TetherToken," + usdt + "\n", wantErr: "line 2: invalid chain id"},
+		{name: "unterminated quote", csv: "1,TetherToken," + usdt + ",\"usdt\n", wantErr: "failed to parse csv"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "book.csv")
+			if err := os.WriteFile(path, []byte(tt.csv), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ImportAddressBook(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("imported book = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportUnknownFormat(t *testing.T) {
+	if err := ExportAddressBook(&bytes.Buffer{}, AddressBook{}, "yaml"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+	if BookFormatFromPath("book.CSV") != BookFormatCSV || BookFormatFromPath("book") != BookFormatJSON {
+		t.Error("unexpected format of the file extension")
+	}
+}
@@ -2,7 +2,9 @@ package config
 
 import (
 	"fmt"
+	"math/big"
 	"os"
+	"sort"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -11,23 +13,70 @@ import (
 	"github.com/pelletier/go-toml"
 )
 
-const DefaultRpcURL = "http://localhost:8545"
+const (
+	DefaultRpcURL   = "http://localhost:8545"
+	DefaultGasLimit = uint64(3000000)
+	DefaultWaitTime = "5s"
+	// DefaultRpcTimeout is the time an rpc request may take before it's aborted
+	DefaultRpcTimeout = "30s"
+	// DefaultProfileName is the name of the profile made of the top level fields of the config file
+	DefaultProfileName = "default"
+)
 
 type Config struct {
 	RpcURL     string `toml:"rpc_url"`
 	PrivateKey string `toml:"private_key"`
+	// Keystore is an encrypted keystore file, or a directory of them, used instead of PrivateKey if set
+	Keystore string `toml:"keystore,omitempty"`
+	ChainId  uint64 `toml:"chain_id"`
+	WaitTime string `toml:"wait_time"`
+	GasLimit uint64 `toml:"gas_limit,omitempty"`
+	GasPrice string `toml:"gas_price,omitempty"`
+	// DisableFailover disables switching to the rpc endpoints of the chain registry when the rpc url fails,
+	// e.g. for a local fork which shares the chain id of a public chain
+	DisableFailover bool `toml:"disable_failover,omitempty"`
+	// RpcTimeout is the timeout of each rpc request, "0s" disables it
+	RpcTimeout string `toml:"rpc_timeout,omitempty"`
+	// TokenFormat shows uint outputs of token contracts and native balances scaled by decimals next to the raw value
+	TokenFormat bool `toml:"token_format"`
+	// AbiDir is the directory of the abi files, abis in the solizard home if empty
+	AbiDir string `toml:"abi_dir,omitempty"`
+	// Profiles are the named networks defined as [profiles.<name>] tables
+	Profiles map[string]*Profile `toml:"profiles,omitempty"`
+
+	// sources are where the top level fields are set, keyed by toml key
+	sources map[string]string
+	// overridden are the config file values of the fields set by overrides
+	overridden map[string]string
+}
+
+// Profile is a named network setting.
+// Empty signer, gas and wait settings are inherited from the top level fields of the config.
+type Profile struct {
+	Name       string `toml:"-"`
+	RpcURL     string `toml:"rpc_url"`
 	ChainId    uint64 `toml:"chain_id"`
-	WaitTime   string `toml:"wait_time"`
+	PrivateKey string `toml:"private_key,omitempty"`
+	Keystore   string `toml:"keystore,omitempty"`
+	// GasLimit is the gas limit of sent transactions, DefaultGasLimit is used if zero
+	GasLimit uint64 `toml:"gas_limit,omitempty"`
+	// GasPrice is the gas price in wei, the price suggested by the node is used if empty
+	GasPrice        string `toml:"gas_price,omitempty"`
+	WaitTime        string `toml:"wait_time,omitempty"`
+	DisableFailover bool   `toml:"disable_failover,omitempty"`
+	RpcTimeout      string `toml:"rpc_timeout,omitempty"`
 }
 
 func DefaultConfig() *Config {
 	pk, _ := crypto.GenerateKey()
 	hexPriv := common.Bytes2Hex(crypto.FromECDSA(pk))
 	return &Config{
-		RpcURL:     DefaultRpcURL,
-		PrivateKey: hexPriv,
-		ChainId:    1,
-		WaitTime:   "5s",
+		RpcURL:      DefaultRpcURL,
+		PrivateKey:  hexPriv,
+		ChainId:     1,
+		WaitTime:    DefaultWaitTime,
+		RpcTimeout:  DefaultRpcTimeout,
+		TokenFormat: true,
 	}
 }
 
@@ -41,6 +90,9 @@ func ReadConfig(path string) (*Config, error) {
 	if err := toml.Unmarshal(data, cfg); err != nil {
 		return nil, err
 	}
+	if err := cfg.recordFileSources(data); err != nil {
+		return nil, err
+	}
 
 	return cfg, nil
 }
@@ -49,7 +101,8 @@ func WriteConfig(path string, conf *Config) error {
 	if err := conf.Validate(); err != nil {
 		return err
 	}
-	data, err := toml.Marshal(conf)
+	// values of flags and environment variables are not written
+	data, err := toml.Marshal(conf.withoutOverrides())
 	if err != nil {
 		return err
 	}
@@ -65,5 +118,190 @@ func (c *Config) Validate() error {
 	if _, err := time.ParseDuration(c.WaitTime); err != nil {
 		return fmt.Errorf("%s:: invalid wait time: %v", failMsg, err)
 	}
+	if c.RpcTimeout != "" {
+		if _, err := time.ParseDuration(c.RpcTimeout); err != nil {
+			return fmt.Errorf("%s:: invalid rpc timeout: %v", failMsg, err)
+		}
+	}
+	if c.GasPrice != "" {
+		if _, ok := new(big.Int).SetString(c.GasPrice, 10); !ok {
+			return fmt.Errorf("%s:: invalid gas price: %s", failMsg, c.GasPrice)
+		}
+	}
+	for name, p := range c.Profiles {
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("%s:: profile %s: %v", failMsg, name, err)
+		}
+	}
+	return nil
+}
+
+// ProfileNames returns the names of all profiles, the default profile comes first
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles)+1)
+	if _, ok := c.Profiles[DefaultProfileName]; !ok {
+		names = append(names, DefaultProfileName)
+	}
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.SliceStable(names, func(i, j int) bool {
+		if names[i] == DefaultProfileName || names[j] == DefaultProfileName {
+			return names[i] == DefaultProfileName
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// Profile returns the profile with the given name with the inherited settings filled in.
+// The default profile is made of the top level fields unless it's defined in the profiles table.
+func (c *Config) Profile(name string) (*Profile, error) {
+	p, ok := c.Profiles[name]
+	if !ok {
+		if name != DefaultProfileName {
+			return nil, fmt.Errorf("profile %q not found (available: %v)", name, c.ProfileNames())
+		}
+		p = &Profile{RpcURL: c.RpcURL, ChainId: c.ChainId}
+	}
+	merged := c.inherit(name)
+	merged.RpcURL = p.RpcURL
+	merged.ChainId = p.ChainId
+	// the signer of the profile replaces the inherited private key and keystore
+	if p.PrivateKey != "" || p.Keystore != "" {
+		merged.PrivateKey = p.PrivateKey
+		merged.Keystore = p.Keystore
+	}
+	if p.GasLimit != 0 {
+		merged.GasLimit = p.GasLimit
+	}
+	if p.GasPrice != "" {
+		merged.GasPrice = p.GasPrice
+	}
+	if p.WaitTime != "" {
+		merged.WaitTime = p.WaitTime
+	}
+	if p.RpcTimeout != "" {
+		merged.RpcTimeout = p.RpcTimeout
+	}
+	merged.DisableFailover = merged.DisableFailover || p.DisableFailover
+	c.overrideProfile(merged)
+	return merged, nil
+}
+
+// ManualProfile returns a profile without network and signer,
+// which are input by the user, but with the inherited gas and wait settings.
+func (c *Config) ManualProfile() *Profile {
+	p := c.inherit("manual")
+	// the signer of flags and environment variables is kept
+	if !c.Overridden("private_key") {
+		p.PrivateKey = ""
+	}
+	if !c.Overridden("keystore") {
+		p.Keystore = ""
+	}
+	return p
+}
+
+// SetProfile adds or replaces the profile with the given name.
+// Settings equal to the inherited ones are not stored.
+func (c *Config) SetProfile(name string, p *Profile) {
+	stored := *p
+	stored.Name = ""
+	if stored.PrivateKey == c.PrivateKey {
+		stored.PrivateKey = ""
+	}
+	if stored.Keystore == c.Keystore {
+		stored.Keystore = ""
+	}
+	if stored.GasLimit == c.GasLimit {
+		stored.GasLimit = 0
+	}
+	if stored.GasPrice == c.GasPrice {
+		stored.GasPrice = ""
+	}
+	if stored.WaitTime == c.WaitTime {
+		stored.WaitTime = ""
+	}
+	if stored.RpcTimeout == c.RpcTimeout {
+		stored.RpcTimeout = ""
+	}
+	if c.DisableFailover {
+		stored.DisableFailover = false
+	}
+	if c.Profiles == nil {
+		c.Profiles = make(map[string]*Profile)
+	}
+	c.Profiles[name] = &stored
+}
+
+// inherit returns a profile holding the settings shared by all profiles
+func (c *Config) inherit(name string) *Profile {
+	return &Profile{
+		Name:            name,
+		PrivateKey:      c.PrivateKey,
+		Keystore:        c.Keystore,
+		GasLimit:        c.GasLimit,
+		GasPrice:        c.GasPrice,
+		WaitTime:        c.WaitTime,
+		DisableFailover: c.DisableFailover,
+		RpcTimeout:      c.RpcTimeout,
+	}
+}
+
+func (p *Profile) Validate() error {
+	if p.WaitTime != "" {
+		if _, err := time.ParseDuration(p.WaitTime); err != nil {
+			return fmt.Errorf("invalid wait time: %v", err)
+		}
+	}
+	if p.RpcTimeout != "" {
+		if _, err := time.ParseDuration(p.RpcTimeout); err != nil {
+			return fmt.Errorf("invalid rpc timeout: %v", err)
+		}
+	}
+	if p.GasPrice != "" {
+		if _, ok := new(big.Int).SetString(p.GasPrice, 10); !ok {
+			return fmt.Errorf("invalid gas price: %s", p.GasPrice)
+		}
+	}
 	return nil
 }
+
+// Wait returns the time to wait for a sent transaction to be mined
+func (p *Profile) Wait() time.Duration {
+	d, err := time.ParseDuration(p.WaitTime)
+	if err != nil {
+		d, _ = time.ParseDuration(DefaultWaitTime)
+	}
+	return d
+}
+
+// Timeout returns the timeout of each rpc request, zero means no timeout
+func (p *Profile) Timeout() time.Duration {
+	d, err := time.ParseDuration(p.RpcTimeout)
+	if err != nil {
+		d, _ = time.ParseDuration(DefaultRpcTimeout)
+	}
+	return d
+}
+
+// Gas returns the gas limit of sent transactions
+func (p *Profile) Gas() uint64 {
+	if p.GasLimit == 0 {
+		return DefaultGasLimit
+	}
+	return p.GasLimit
+}
+
+// FixedGasPrice returns the configured gas price, or nil if the price should be suggested by the node
+func (p *Profile) FixedGasPrice() *big.Int {
+	if p.GasPrice == "" {
+		return nil
+	}
+	price, ok := new(big.Int).SetString(p.GasPrice, 10)
+	if !ok {
+		return nil
+	}
+	return price
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testConfig has top level settings inherited by the profiles
+func testConfig() *Config {
+	return &Config{
+		RpcURL:     "http://localhost:8545",
+		PrivateKey: "aa",
+		ChainId:    1,
+		WaitTime:   "5s",
+		GasLimit:   100_000,
+		GasPrice:   "1000",
+		RpcTimeout: "30s",
+		Profiles: map[string]*Profile{
+			"sepolia": {RpcURL: "https://sepolia", ChainId: 11155111},
+			"key":     {RpcURL: "https://key", ChainId: 2, PrivateKey: "bb"},
+			"vault":   {RpcURL: "https://vault", ChainId: 3, Keystore: "/keys"},
+			"tuned":   {RpcURL: "https://tuned", ChainId: 4, GasLimit: 500_000, GasPrice: "7", WaitTime: "1s", RpcTimeout: "2s", DisableFailover: true},
+		},
+	}
+}
+
+func TestProfile(t *testing.T) {
+	tests := []struct {
+		name string
+		want Profile
+	}{
+		{
+			name: DefaultProfileName,
+			want: Profile{RpcURL: "http://localhost:8545", ChainId: 1, PrivateKey: "aa", GasLimit: 100_000, GasPrice: "1000", WaitTime: "5s", RpcTimeout: "30s"},
+		},
+		{
+			name: "sepolia",
+			want: Profile{RpcURL: "https://sepolia", ChainId: 11155111, PrivateKey: "aa", GasLimit: 100_000, GasPrice: "1000", WaitTime: "5s", RpcTimeout: "30s"},
+		},
+		{
+			name: "key",
+			want: Profile{RpcURL: "https://key", ChainId: 2, PrivateKey: "bb", GasLimit: 100_000, GasPrice: "1000", WaitTime: "5s", RpcTimeout: "30s"},
+		},
+		{
+			// the keystore of the profile replaces the inherited private key
+			name: "vault",
+			want: Profile{RpcURL: "https://vault", ChainId: 3, Keystore: "/keys", GasLimit: 100_000, GasPrice: "1000", WaitTime: "5s", RpcTimeout: "30s"},
+		},
+		{
+			name: "tuned",
+			want: Profile{RpcURL: "https://tuned", ChainId: 4, PrivateKey: "aa", GasLimit: 500_000, GasPrice: "7", WaitTime: "1s", RpcTimeout: "2s", DisableFailover: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := testConfig().Profile(tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.want.Name = tt.name
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("Profile(%q) = %+v, want %+v", tt.name, *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProfileOverrides(t *testing.T) {
+	c := testConfig()
+	c.DisableFailover = true
+	for _, o := range []Override{{Key: "rpc_url", Value: "http://fork:8545"}, {Key: "gas_price", Value: "9"}} {
+		if err := c.Apply(o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := c.Profile("tuned")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// overrides take precedence over the profile, failover stays disabled if the config disables it
+	if got.RpcURL != "http://fork:8545" || got.GasPrice != "9" || got.GasLimit != 500_000 || !got.DisableFailover {
+		t.Errorf("unexpected overridden profile: %+v", *got)
+	}
+	if got, _ = c.Profile("sepolia"); !got.DisableFailover {
+		t.Errorf("failover of the config isn't inherited: %+v", *got)
+	}
+}
+
+func TestUnknownProfile(t *testing.T) {
+	_, err := testConfig().Profile("mainnet")
+	if err == nil || !strings.Contains(err.Error(), `"mainnet" not found`) || !strings.Contains(err.Error(), "sepolia") {
+		t.Errorf("expected an error listing the available profiles, got %v", err)
+	}
+	// the default profile is defined by the top level fields
+	if _, err = (&Config{}).Profile(DefaultProfileName); err != nil {
+		t.Errorf("default profile of an empty config: %v", err)
+	}
+}
+
+func TestSetProfile(t *testing.T) {
+	c := testConfig()
+	inherited, err := c.Profile("sepolia")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := *inherited
+	saved.RpcURL, saved.ChainId, saved.GasPrice = "https://holesky", 17000, "3"
+	c.SetProfile("holesky", &saved)
+
+	// only the settings which differ from the inherited ones are stored
+	want := Profile{RpcURL: "https://holesky", ChainId: 17000, GasPrice: "3"}
+	if got := *c.Profiles["holesky"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("stored profile = %+v, want %+v", got, want)
+	}
+	got, err := c.Profile("holesky")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved.Name = "holesky"
+	if !reflect.DeepEqual(*got, saved) {
+		t.Errorf("Profile() = %+v, want the saved %+v", *got, saved)
+	}
+
+	// a profile changes with the top level settings it inherits
+	c.WaitTime = "10s"
+	if got, _ = c.Profile("holesky"); got.WaitTime != "10s" {
+		t.Errorf("wait time %s isn't inherited", got.WaitTime)
+	}
+	// SetProfile replaces the profile
+	c.SetProfile("holesky", &Profile{RpcURL: "https://other", ChainId: 17000})
+	if got, _ = c.Profile("holesky"); got.RpcURL != "https://other" || got.GasPrice != "1000" {
+		t.Errorf("unexpected replaced profile: %+v", *got)
+	}
+}
+
+func TestManualProfile(t *testing.T) {
+	c := testConfig()
+	got := c.ManualProfile()
+	want := Profile{Name: "manual", GasLimit: 100_000, GasPrice: "1000", WaitTime: "5s", RpcTimeout: "30s"}
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("ManualProfile() = %+v, want %+v", *got, want)
+	}
+
+	// the signer of flags and environment variables is kept
+	if err := c.Apply(Override{Key: "private_key", Value: "cc"}); err != nil {
+		t.Fatal(err)
+	}
+	if got = c.ManualProfile(); got.PrivateKey != "cc" || got.RpcURL != "" {
+		t.Errorf("unexpected manual profile with an overridden key: %+v", *got)
+	}
+}
@@ -4,37 +4,72 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
 )
 
+// AnyChain is the chain id of entries without a known chain,
+// e.g. entries migrated from the legacy address book format.
+// They are offered on every chain until they are used on one.
+const AnyChain = uint64(0)
+
+// ContractInfo is an address book entry of a deployed contract
 type ContractInfo struct {
+	// Abi is the name of the abi file without the .abi extension
+	Abi     string   `json:"abi"`
+	Address string   `json:"address"`
+	Label   string   `json:"label,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+	Notes   string   `json:"notes,omitempty"`
+}
+
+// AddressBook holds the contract deployments keyed by chain id
+type AddressBook map[uint64][]ContractInfo
+
+// legacyContractInfo is an entry of the legacy address book, a flat list without chain ids
+type legacyContractInfo struct {
 	Name    string `json:"name"`
 	Address string `json:"address"`
 }
 
-func ReadContractInfos(path string) ([]ContractInfo, error) {
+func ReadAddressBook(path string) (AddressBook, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
-	var ci []ContractInfo
-	if err = json.Unmarshal(data, &ci); err != nil {
+	book := make(AddressBook)
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		// legacy format
+		var legacy []legacyContractInfo
+		if err = json.Unmarshal(data, &legacy); err != nil {
+			return nil, err
+		}
+		for _, l := range legacy {
+			book.Add(AnyChain, ContractInfo{Abi: l.Name, Address: l.Address})
+		}
+		return book, nil
+	}
+	if err = json.Unmarshal(data, &book); err != nil {
 		return nil, err
 	}
-	return ci, nil
+	return book, nil
 }
 
-// WriteContractInfos writes the contract information to a file
-func WriteContractInfos(path string, infos []ContractInfo) error {
+// WriteAddressBook writes the address book to a file, duplicated entries are merged
+func WriteAddressBook(path string, book AddressBook) error {
 	// Validate all contract infos before writing
-	if err := ValidateContractInfos(infos); err != nil {
+	if err := book.Validate(); err != nil {
 		return fmt.Errorf("validation failed: %v", err)
 	}
 
+	deduped := make(AddressBook, len(book))
+	deduped.Merge(book)
+
 	// Marshal the contract infos to JSON with indentation for readability
-	data, err := json.MarshalIndent(infos, "", "  ")
+	data, err := json.MarshalIndent(deduped, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal contract infos: %v", err)
 	}
@@ -47,9 +82,163 @@ func WriteContractInfos(path string, infos []ContractInfo) error {
 	return nil
 }
 
+// Add adds the entry to the chain's list.
+// An address can be saved once per abi, e.g. as a proxy and as its implementation.
+// If the abi and address are already in the list, the entry is merged into the existing one:
+// non-empty fields replace the existing ones and tags are combined.
+// An entry without an abi is merged into the first entry with the address.
+func (b AddressBook) Add(chainId uint64, ci ContractInfo) {
+	infos := b[chainId]
+	for i, existing := range infos {
+		if !strings.EqualFold(existing.Address, ci.Address) || (ci.Abi != "" && existing.Abi != ci.Abi) {
+			continue
+		}
+		if ci.Label != "" {
+			existing.Label = ci.Label
+		}
+		if ci.Notes != "" {
+			existing.Notes = ci.Notes
+		}
+		existing.Tags = mergeTags(existing.Tags, ci.Tags)
+		infos[i] = existing
+		return
+	}
+	ci.Tags = mergeTags(nil, ci.Tags)
+	b[chainId] = append(infos, ci)
+}
+
+// find returns the index of the entry with the given abi and address in the chain's list.
+// An empty abi name matches the entries of every abi, an error is returned if the address
+// isn't found or if it is saved with more than one abi.
+func (b AddressBook) find(chainId uint64, abiName, address string) (int, error) {
+	idx := -1
+	var abis []string
+	for i, ci := range b[chainId] {
+		if strings.EqualFold(ci.Address, address) && (abiName == "" || ci.Abi == abiName) {
+			idx = i
+			abis = append(abis, ci.Abi)
+		}
+	}
+	switch {
+	case idx < 0 && abiName != "":
+		return -1, fmt.Errorf("address %s with abi %s not found on chain %d", address, abiName, chainId)
+	case idx < 0:
+		return -1, fmt.Errorf("address %s not found on chain %d", address, chainId)
+	case len(abis) > 1:
+		return -1, fmt.Errorf("address %s is saved with the abis %s on chain %d, choose one of them", address, strings.Join(abis, ", "), chainId)
+	}
+	return idx, nil
+}
+
+// Lookup returns the entry of the abi with the given address on the chain,
+// or the one without a known chain, or nil if the address isn't saved for the abi
+func (b AddressBook) Lookup(chainId uint64, abiName, address string) *ContractInfo {
+	for _, ci := range b.Deployments(chainId, abiName) {
+		if strings.EqualFold(ci.Address, address) {
+			return &ci
+		}
+	}
+	return nil
+}
+
+// Remove removes the entry with the given abi and address from the chain's list,
+// the abi can be empty if the address is saved with a single abi
+func (b AddressBook) Remove(chainId uint64, abiName, address string) error {
+	i, err := b.find(chainId, abiName, address)
+	if err != nil {
+		return err
+	}
+	infos := b[chainId]
+	b[chainId] = append(infos[:i:i], infos[i+1:]...)
+	if len(b[chainId]) == 0 {
+		delete(b, chainId)
+	}
+	return nil
+}
+
+// Rename changes the label of the entry with the given abi and address,
+// the abi can be empty if the address is saved with a single abi
+func (b AddressBook) Rename(chainId uint64, abiName, address, label string) error {
+	i, err := b.find(chainId, abiName, address)
+	if err != nil {
+		return err
+	}
+	b[chainId][i].Label = label
+	return nil
+}
+
+// Deployments returns the entries of the given abi on the chain,
+// followed by the entries of the abi without a known chain
+func (b AddressBook) Deployments(chainId uint64, abiName string) []ContractInfo {
+	var deployments []ContractInfo
+	chainIds := []uint64{chainId}
+	if chainId != AnyChain {
+		chainIds = append(chainIds, AnyChain)
+	}
+	for _, id := range chainIds {
+		for _, ci := range b[id] {
+			if ci.Abi == abiName {
+				deployments = append(deployments, ci)
+			}
+		}
+	}
+	return deployments
+}
+
+// ChainIds returns the chain ids of the address book in ascending order
+func (b AddressBook) ChainIds() []uint64 {
+	ids := make([]uint64, 0, len(b))
+	for id := range b {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// Sanitize removes the invalid entries from the address book and returns why each of them is invalid
+func (b AddressBook) Sanitize() []error {
+	var errs []error
+	for chainId, infos := range b {
+		valid := infos[:0]
+		for _, ci := range infos {
+			if err := ci.Validate(); err != nil {
+				errs = append(errs, fmt.Errorf("chain %d: entry %q (abi: %q): %v", chainId, ci.Address, ci.Abi, err))
+				continue
+			}
+			valid = append(valid, ci)
+		}
+		if len(valid) == 0 {
+			delete(b, chainId)
+		} else {
+			b[chainId] = valid
+		}
+	}
+	return errs
+}
+
+// Merge adds all entries of the other address book
+func (b AddressBook) Merge(other AddressBook) {
+	for chainId, infos := range other {
+		for _, ci := range infos {
+			b.Add(chainId, ci)
+		}
+	}
+}
+
+func (b AddressBook) Validate() error {
+	for chainId, infos := range b {
+		for _, ci := range infos {
+			if err := ci.Validate(); err != nil {
+				return fmt.Errorf("chain %d: %v", chainId, err)
+			}
+		}
+	}
+	return nil
+}
+
 func (ci ContractInfo) Validate() error {
-	if ci.Name == "" {
-		return fmt.Errorf("contract name is empty")
+	if ci.Abi == "" {
+		return fmt.Errorf("contract abi name is empty")
 	}
 	if ci.Address == "" {
 		return fmt.Errorf("contract address is empty")
@@ -60,11 +249,34 @@ func (ci ContractInfo) Validate() error {
 	return nil
 }
 
-func ValidateContractInfos(cis []ContractInfo) error {
-	for _, c := range cis {
-		if err := c.Validate(); err != nil {
-			return err
+// String returns the entry formatted for the address pickers, e.g. "usdt 0xdAC1... [stable]"
+func (ci ContractInfo) String() string {
+	s := ci.Address
+	if ci.Label != "" {
+		s = ci.Label + " " + s
+	}
+	if len(ci.Tags) > 0 {
+		s += " [" + strings.Join(ci.Tags, ",") + "]"
+	}
+	return s
+}
+
+func mergeTags(tags []string, more []string) []string {
+	for _, t := range more {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		found := false
+		for _, existing := range tags {
+			if existing == t {
+				found = true
+				break
+			}
+		}
+		if !found {
+			tags = append(tags, t)
 		}
 	}
-	return nil
+	return tags
 }
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	usdt = "0xdAC17F958D2ee523a2206206994597C13D831ec7"
+	usdc = "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"
+)
+
+func TestAddressBookAdd(t *testing.T) {
+	book := make(AddressBook)
+	book.Add(1, ContractInfo{Abi: "TetherToken", Address: usdt, Label: "usdt", Tags: []string{"stable", " ", "stable"}})
+	// the same address in another case is merged into the entry
+	book.Add(1, ContractInfo{Address: "0xdac17f958d2ee523a2206206994597c13d831ec7", Tags: []string{"erc20", "stable"}, Notes: "tether"})
+	book.Add(1, ContractInfo{Abi: "FiatToken", Address: usdc})
+	book.Add(5, ContractInfo{Abi: "TetherToken", Address: usdt})
+
+	want := []ContractInfo{
+		{Abi: "TetherToken", Address: usdt, Label: "usdt", Tags: []string{"stable", "erc20"}, Notes: "tether"},
+		{Abi: "FiatToken", Address: usdc},
+	}
+	if !reflect.DeepEqual(book[1], want) {
+		t.Errorf("entries of chain 1 = %+v, want %+v", book[1], want)
+	}
+	if len(book[5]) != 1 {
+		t.Errorf("entries are merged across chains: %+v", book[5])
+	}
+
+	// empty fields keep the existing values
+	book.Add(1, ContractInfo{Address: usdt, Label: "tether"})
+	if got := book[1][0]; got.Abi != "TetherToken" || got.Label != "tether" || got.Notes != "tether" || len(got.Tags) != 2 {
+		t.Errorf("unexpected merged entry: %+v", got)
+	}
+
+	// the address with another abi is a separate entry
+	book.Add(1, ContractInfo{Abi: "ERC20", Address: usdt, Label: "erc20 view"})
+	if got := book.Deployments(1, "ERC20"); len(got) != 1 || got[0].Label != "erc20 view" {
+		t.Errorf("Deployments() of the second abi = %+v", got)
+	}
+	if got := book.Deployments(1, "TetherToken"); len(got) != 1 || got[0].Label != "tether" {
+		t.Errorf("Deployments() of the first abi = %+v", got)
+	}
+}
+
+func TestAddressBookLookup(t *testing.T) {
+	book := AddressBook{
+		1:        {{Abi: "TetherToken", Address: usdt, Label: "usdt"}},
+		AnyChain: {{Abi: "FiatToken", Address: usdc}},
+	}
+	tests := []struct {
+		name    string
+		abi     string
+		address string
+		want    string
+	}{
+		{name: "on the chain", abi: "TetherToken", address: "0xdac17f958d2ee523a2206206994597c13d831ec7", want: usdt},
+		{name: "without a known chain", abi: "FiatToken", address: usdc, want: usdc},
+		{name: "other abi", abi: "FiatToken", address: usdt},
+		{name: "unknown address", abi: "TetherToken", address: usdc},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := book.Lookup(1, tt.abi, tt.address)
+			if (got == nil) != (tt.want == "") || (got != nil && got.Address != tt.want) {
+				t.Errorf("Lookup() = %+v, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddressBookRemove(t *testing.T) {
+	book := AddressBook{
+		1: {{Abi: "TetherToken", Address: usdt}, {Abi: "FiatToken", Address: usdc}},
+		5: {{Abi: "TetherToken", Address: usdt}},
+	}
+	if err := book.Remove(1, "", "0x0000000000000000000000000000000000000001"); err == nil {
+		t.Error("removed an unknown address")
+	}
+	if err := book.Remove(1, "FiatToken", usdt); err == nil {
+		t.Error("removed the address of another abi")
+	}
+	if err := book.Remove(1, "", "0xDAC17F958D2EE523A2206206994597C13D831EC7"); err != nil {
+		t.Fatalf("the address isn't found case insensitively: %v", err)
+	}
+	if len(book[1]) != 1 || book[1][0].Address != usdc || len(book[5]) != 1 {
+		t.Errorf("unexpected book after removing: %+v", book)
+	}
+	// the chain of the last entry is removed
+	if err := book.Remove(5, "TetherToken", usdt); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := book[5]; ok {
+		t.Errorf("chain 5 is kept without entries: %+v", book)
+	}
+	if ids := book.ChainIds(); len(ids) != 1 || ids[0] != 1 {
+		t.Errorf("ChainIds() = %v, want [1]", ids)
+	}
+}
+
+func TestAddressBookAmbiguousAddress(t *testing.T) {
+	book := AddressBook{1: {{Abi: "Proxy", Address: usdt}, {Abi: "TetherToken", Address: usdt}}}
+	if err := book.Remove(1, "", usdt); err == nil || !strings.Contains(err.Error(), "Proxy, TetherToken") {
+		t.Errorf("expected an error listing the abis, got %v", err)
+	}
+	if err := book.Rename(1, "", usdt, "usdt"); err == nil {
+		t.Error("renamed an ambiguous address")
+	}
+	if len(book[1]) != 2 || book[1][0].Label != "" || book[1][1].Label != "" {
+		t.Fatalf("the book changed: %+v", book)
+	}
+
+	if err := book.Rename(1, "TetherToken", usdt, "usdt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := book.Remove(1, "Proxy", usdt); err != nil {
+		t.Fatal(err)
+	}
+	want := AddressBook{1: {{Abi: "TetherToken", Address: usdt, Label: "usdt"}}}
+	if !reflect.DeepEqual(book, want) {
+		t.Errorf("book = %+v, want %+v", book, want)
+	}
+}
+
+func TestAddressBookSanitize(t *testing.T) {
+	book := AddressBook{
+		1: {
+			{Abi: "TetherToken", Address: usdt},
+			{Abi: "", Address: usdc},
+			{Abi: "FiatToken", Address: "0x1234"},
+		},
+		5: {{Abi: "TetherToken", Address: ""}},
+	}
+	errs := book.Sanitize()
+	if len(errs) != 3 {
+		t.Errorf("got %d errors, want 3: %v", len(errs), errs)
+	}
+	want := AddressBook{1: {{Abi: "TetherToken", Address: usdt}}}
+	if !reflect.DeepEqual(book, want) {
+		t.Errorf("sanitized book = %+v, want %+v", book, want)
+	}
+	if err := book.Validate(); err != nil {
+		t.Errorf("sanitized book is invalid: %v", err)
+	}
+}
+
+func TestAddressBookMerge(t *testing.T) {
+	book := AddressBook{1: {{Abi: "TetherToken", Address: usdt, Tags: []string{"stable"}}}}
+	book.Merge(AddressBook{
+		1:        {{Address: usdt, Label: "usdt", Tags: []string{"erc20"}}, {Abi: "FiatToken", Address: usdc}},
+		AnyChain: {{Abi: "FiatToken", Address: usdc}},
+	})
+	if len(book[1]) != 2 || book[1][0].Label != "usdt" || !reflect.DeepEqual(book[1][0].Tags, []string{"stable", "erc20"}) {
+		t.Errorf("unexpected merged entries: %+v", book[1])
+	}
+	// the deployments of the chain come before the ones without a known chain
+	if got := book.Deployments(1, "FiatToken"); len(got) != 2 {
+		t.Errorf("Deployments() = %+v, want the entries of chain 1 and of any chain", got)
+	}
+}
+
+func TestReadAddressBook(t *testing.T) {
+	dir := t.TempDir()
+	legacy := filepath.Join(dir, "legacy.json")
+	data := `[
+		{"name": "TetherToken", "address": "` + usdt + `"},
+		{"name": "TetherToken", "address": "0xdac17f958d2ee523a2206206994597c13d831ec7"},
+		{"name": "FiatToken", "address": "` + usdc + `"}
+	]`
+	if err := os.WriteFile(legacy, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	book, err := ReadAddressBook(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// legacy entries have no chain, duplicates are merged
+	want := AddressBook{AnyChain: {{Abi: "TetherToken", Address: usdt}, {Abi: "FiatToken", Address: usdc}}}
+	if !reflect.DeepEqual(book, want) {
+		t.Errorf("migrated book = %+v, want %+v", book, want)
+	}
+
+	// the migrated book is written in the current format
+	path := filepath.Join(dir, "contract_info.json")
+	book.Add(1, ContractInfo{Abi: "TetherToken", Address: usdt, Label: "usdt", Tags: []string{"stable"}, Notes: "tether"})
+	if err = WriteAddressBook(path, book); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadAddressBook(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, book) {
+		t.Errorf("book read back = %+v, want %+v", read, book)
+	}
+
+	if err = WriteAddressBook(path, AddressBook{1: {{Abi: "TetherToken", Address: "0x1234"}}}); err == nil {
+		t.Error("expected an error writing an invalid entry")
+	}
+	if _, err = ReadAddressBook(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,243 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+)
+
+// EnvPrefix is the prefix of the environment variables overriding config fields,
+// e.g. SOLIZARD_RPC_URL overrides rpc_url
+const EnvPrefix = "SOLIZARD_"
+
+// sources of the config values shown by `solizard config show`
+const (
+	SourceDefault = "default"
+	SourceFile    = "config file"
+)
+
+// Override is a value of a config field set outside of the config file, e.g. by a flag
+type Override struct {
+	// Key is the toml key of the field
+	Key    string
+	Value  string
+	Source string
+}
+
+// Keys returns the toml keys of the top level fields of the config, profiles excluded
+func Keys() []string {
+	t := reflect.TypeOf(Config{})
+	keys := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if key := tomlKey(t.Field(i)); key != "" && key != "profiles" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// EnvName returns the environment variable overriding the field with the toml key
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(key)
+}
+
+// EnvOverrides returns the overrides of the SOLIZARD_* variables of environ, formatted as KEY=value
+func EnvOverrides(environ []string) []Override {
+	known := make(map[string]string)
+	for _, key := range Keys() {
+		known[EnvName(key)] = key
+	}
+	var overrides []Override
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if key, isKey := known[name]; ok && isKey {
+			overrides = append(overrides, Override{Key: key, Value: value, Source: "env " + name})
+		}
+	}
+	sort.Slice(overrides, func(i, j int) bool { return overrides[i].Key < overrides[j].Key })
+	return overrides
+}
+
+// Field is the value of a config field and where it's set
+type Field struct {
+	Key    string      `json:"key"`
+	Value  interface{} `json:"value"`
+	Source string      `json:"source"`
+}
+
+// Fields returns the top level fields of the config with their sources
+func (c *Config) Fields() []Field {
+	fields := make([]Field, 0)
+	for _, key := range Keys() {
+		v, _ := c.field(key)
+		fields = append(fields, Field{Key: key, Value: v.Interface(), Source: c.Source(key)})
+	}
+	return fields
+}
+
+// ProfileFields returns the fields of the profile with the inherited settings and overrides applied,
+// with their sources
+func (c *Config) ProfileFields(name string) ([]Field, error) {
+	merged, err := c.Profile(name)
+	if err != nil {
+		return nil, err
+	}
+	own := reflect.ValueOf(Profile{})
+	if p, ok := c.Profiles[name]; ok {
+		own = reflect.ValueOf(*p)
+	}
+	v := reflect.ValueOf(*merged)
+	fields := make([]Field, 0)
+	for i := 0; i < v.NumField(); i++ {
+		key := tomlKey(v.Type().Field(i))
+		if key == "" {
+			continue
+		}
+		source := c.Source(key)
+		if !c.Overridden(key) && !own.Field(i).IsZero() {
+			source = "profile " + name
+		}
+		fields = append(fields, Field{Key: key, Value: v.Field(i).Interface(), Source: source})
+	}
+	return fields, nil
+}
+
+// Apply sets the field of the override, the value in the config file is kept for WriteConfig
+func (c *Config) Apply(o Override) error {
+	if _, ok := c.overridden[o.Key]; !ok {
+		if c.overridden == nil {
+			c.overridden = make(map[string]string)
+		}
+		original, err := c.Get(o.Key)
+		if err != nil {
+			return err
+		}
+		c.overridden[o.Key] = original
+	}
+	if err := c.Set(o.Key, o.Value); err != nil {
+		return fmt.Errorf("invalid %s from %s: %v", o.Key, o.Source, err)
+	}
+	if c.sources == nil {
+		c.sources = make(map[string]string)
+	}
+	c.sources[o.Key] = o.Source
+	return nil
+}
+
+// Overridden returns true if the field with the toml key is set by an override
+func (c *Config) Overridden(key string) bool {
+	_, ok := c.overridden[key]
+	return ok
+}
+
+// Source returns where the value of the field with the toml key comes from
+func (c *Config) Source(key string) string {
+	if source, ok := c.sources[key]; ok {
+		return source
+	}
+	return SourceDefault
+}
+
+// Get returns the value of the field with the toml key
+func (c *Config) Get(key string) (string, error) {
+	v, err := c.field(key)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprint(v.Interface()), nil
+}
+
+// Set parses the value into the field with the toml key
+func (c *Config) Set(key, value string) error {
+	v, err := c.field(key)
+	if err != nil {
+		return err
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("%s can't be set from a string", key)
+	}
+	return nil
+}
+
+func (c *Config) field(key string) (reflect.Value, error) {
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if k := tomlKey(v.Type().Field(i)); k == key && k != "profiles" {
+			return v.Field(i), nil
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("unknown config field %q", key)
+}
+
+// withoutOverrides returns a copy of the config holding the values of the config file
+func (c *Config) withoutOverrides() *Config {
+	file := *c
+	for key, original := range c.overridden {
+		_ = file.Set(key, original)
+	}
+	return &file
+}
+
+// overrideProfile sets the overridden fields which are also profile fields,
+// so overrides take precedence over the settings of the selected profile
+func (c *Config) overrideProfile(p *Profile) {
+	v := reflect.ValueOf(p).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		key := tomlKey(v.Type().Field(i))
+		if !c.Overridden(key) {
+			continue
+		}
+		value, _ := c.Get(key)
+		switch f := v.Field(i); f.Kind() {
+		case reflect.String:
+			f.SetString(value)
+		case reflect.Uint64:
+			n, _ := strconv.ParseUint(value, 10, 64)
+			f.SetUint(n)
+		case reflect.Bool:
+			f.SetBool(value == "true")
+		}
+	}
+}
+
+// recordFileSources marks the fields present in the toml data as set by the config file
+func (c *Config) recordFileSources(data []byte) error {
+	tree, err := toml.LoadBytes(data)
+	if err != nil {
+		return err
+	}
+	c.sources = make(map[string]string)
+	for _, key := range Keys() {
+		if tree.Has(key) {
+			c.sources[key] = SourceFile
+		}
+	}
+	return nil
+}
+
+func tomlKey(f reflect.StructField) string {
+	key, _, _ := strings.Cut(f.Tag.Get("toml"), ",")
+	if key == "-" {
+		return ""
+	}
+	return key
+}
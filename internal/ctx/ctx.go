@@ -5,56 +5,74 @@ import (
 	"crypto/ecdsa"
 	"fmt"
 	"math/big"
-	"net/url"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
 
+	"github.com/zsystm/solizard/internal/client"
 	"github.com/zsystm/solizard/internal/config"
+	"github.com/zsystm/solizard/internal/log"
 	"github.com/zsystm/solizard/lib"
 
 	"github.com/fatih/color"
 )
 
 type Context struct {
-	ethCli          *ethclient.Client
-	rpcURL          string
+	profile         *config.Profile
+	ethCli          client.EthClient
 	pk              *ecdsa.PrivateKey
 	chainId         big.Int
 	contractAddress *common.Address
 }
 
-// NewCtx creates a new ctx with the given config.
-// If the config is invalid, it will prompt the user to input manually.
-func NewCtx(conf *config.Config) *Context {
-	ctx := &Context{}
+// NewCtx creates a new ctx with the given profile.
+// If the profile is invalid, it will prompt the user to input manually.
+// Empty rpc url and private key are left to be input manually.
+// The private key is ignored if the profile has a keystore.
+// The rpc endpoints of the chain in chainInfos are used for failover.
+func NewCtx(p *config.Profile, chainInfos *lib.ChainRegistry) *Context {
+	ctx := &Context{profile: p}
 	var err error
 
-	errMsg := "failed to apply config file, please input manually when you see the prompt"
-	if ctx.pk, err = crypto.HexToECDSA(conf.PrivateKey); err != nil {
-		fmt.Printf("%s (reason: invalid private key, err: %v)\n", errMsg, err)
-		ctx.pk = nil
+	errMsg := "failed to apply profile " + p.Name + ", please input manually when you see the prompt"
+	// the key of a keystore is unlocked with a password when signing
+	if p.PrivateKey != "" && p.Keystore == "" {
+		if ctx.pk, err = crypto.HexToECDSA(p.PrivateKey); err != nil {
+			log.Error(fmt.Sprintf("%s (reason: invalid private key, err: %v)\n", errMsg, err))
+			ctx.pk = nil
+		}
 	}
 	// check url validity
-	if _, err := url.ParseRequestURI(conf.RpcURL); err != nil {
-		fmt.Printf("%s (reason: invalid rpc url, err: %v)\n", errMsg, err)
-	} else {
-		rpcURL := conf.RpcURL
-		if ctx.ethCli, err = ethclient.Dial(rpcURL); err != nil {
-			fmt.Printf("%s (reason: failed to connect to given rpc url, err: %v)\n", errMsg, err)
-			ctx.ethCli = nil
+	if p.RpcURL != "" {
+		if err := lib.ValidateRPC(p.RpcURL); err != nil {
+			log.Error(fmt.Sprintf("%s (reason: %v)\n", errMsg, err))
+		} else {
+			// the endpoints of the chain registry are used when the rpc url fails,
+			// the client expands the placeholders of the rpc url when dialing
+			endpoints := []string{p.RpcURL}
+			if !p.DisableFailover {
+				endpoints = append(endpoints, FailoverEndpoints(chainInfos, p.ChainId)...)
+			}
+			cli, err := client.Dial(endpoints...)
+			if err != nil {
+				log.Error(fmt.Sprintf("%s (reason: failed to connect to given rpc url, err: %v)\n", errMsg, err))
+			} else {
+				if cli.URL() != p.RpcURL {
+					log.Error(fmt.Sprintf("failed to connect to %s, using %s from the chain registry instead\n", p.RpcURL, cli.URL()))
+				}
+				cli.SetTimeout(p.Timeout())
+				ctx.ethCli = cli
+			}
 		}
-		ctx.rpcURL = rpcURL
 	}
-	ctx.chainId.SetUint64(conf.ChainId)
+	ctx.chainId.SetUint64(p.ChainId)
 	if ctx.ethCli != nil {
 		// query chain id from the client
 		chainID, err := ctx.ethCli.ChainID(context.TODO())
 		if err == nil {
-			// if conf.ChainId and chainId are different, print a warning
+			// if p.ChainId and chainId are different, print a warning
 			if chainID.Cmp(&ctx.chainId) != 0 {
-				fmt.Printf("WARNING: chain id from config file (%d) and chain id from the client (%d) are different\n", ctx.chainId.Uint64(), chainID.Uint64())
+				log.Error(fmt.Sprintf("WARNING: chain id from profile %s (%d) and chain id from the client (%d) are different\n", p.Name, ctx.chainId.Uint64(), chainID.Uint64()))
 			}
 		}
 	}
@@ -62,7 +80,7 @@ func NewCtx(conf *config.Config) *Context {
 }
 
 // setters
-func (c *Context) SetEthClient(cli *ethclient.Client) {
+func (c *Context) SetEthClient(cli client.EthClient) {
 	c.ethCli = cli
 }
 
@@ -79,7 +97,20 @@ func (c *Context) SetContractAddress(addr *common.Address) {
 }
 
 // getters
-func (c *Context) EthClient() *ethclient.Client {
+func (c *Context) Profile() *config.Profile {
+	return c.profile
+}
+
+// RpcURL returns the rpc endpoint the client is currently connected to with its ${VAR} placeholders,
+// it's empty if the client isn't connected to an rpc endpoint (e.g. a simulated backend)
+func (c *Context) RpcURL() string {
+	if cli, ok := c.ethCli.(interface{ URL() string }); ok {
+		return cli.URL()
+	}
+	return ""
+}
+
+func (c *Context) EthClient() client.EthClient {
 	return c.ethCli
 }
 
@@ -95,25 +126,54 @@ func (c *Context) ContractAddress() *common.Address {
 	return c.contractAddress
 }
 
-func PrintContext(ctx *Context, chainInfos []*lib.ChainInfo) {
+// FailoverEndpoints returns the usable rpc endpoints of the chain from the chain registry
+func FailoverEndpoints(chainInfos *lib.ChainRegistry, chainId uint64) []string {
+	if chainId == 0 {
+		return nil
+	}
+	chainInfo, err := chainInfos.GetChainInfoByID(chainId)
+	if err != nil {
+		return nil
+	}
+	return chainInfo.UsableRPCs()
+}
+
+func PrintContext(ctx *Context, chainInfos *lib.ChainRegistry) {
 	title := color.New(color.FgHiYellow, color.Bold).SprintFunc()
 	key := color.New(color.FgCyan, color.Bold).SprintFunc()
 	val := color.New(color.FgWhite).SprintFunc()
 
 	chainId := ctx.ChainId().Uint64()
-	chainInfo, _ := lib.GetChainInfoByID(chainInfos, chainId)
+	chainInfo, _ := chainInfos.GetChainInfoByID(chainId)
+
+	if log.IsJSON() {
+		out := map[string]interface{}{"profile": ctx.profile.Name, "rpcUrl": ctx.RpcURL(), "chainId": chainId}
+		if chainInfo != nil {
+			out["chainName"] = chainInfo.Name
+			out["nativeCurrency"] = chainInfo.NativeCurrency
+			if explorer := chainInfo.ExplorerURL(); explorer != "" {
+				out["explorer"] = explorer
+			}
+		}
+		log.Emit("context", out)
+		return
+	}
 
 	const contentWidth = 35
 
 	fmt.Println("╔═════════════════════════════════════╗")
 	fmt.Printf("║         %s       ║\n", title("Current Configuration"))
 	fmt.Println("╟─────────────────────────────────────╢")
-	fmt.Printf("║ %s ║\n", lib.PadRightAnsiAware(fmt.Sprintf("%s: %s", key("RPC URL"), val(ctx.rpcURL)), contentWidth))
+	fmt.Printf("║ %s ║\n", lib.PadRightAnsiAware(fmt.Sprintf("%s: %s", key("Profile"), val(ctx.profile.Name)), contentWidth))
+	fmt.Printf("║ %s ║\n", lib.PadRightAnsiAware(fmt.Sprintf("%s: %s", key("RPC URL"), val(ctx.RpcURL())), contentWidth))
 	fmt.Printf("║ %s ║\n", lib.PadRightAnsiAware(fmt.Sprintf("%s: %d", key("Chain ID"), ctx.ChainId()), contentWidth))
 	if chainInfo != nil {
 		fmt.Printf("║ %s ║\n", lib.PadRightAnsiAware(fmt.Sprintf("%s: %s", key("Chain Name"), val(chainInfo.Name)), contentWidth))
 		fmt.Printf("║ %s ║\n", lib.PadRightAnsiAware(fmt.Sprintf("%s: %s (%s)", key("Native Currency"), val(chainInfo.NativeCurrency.Name), val(chainInfo.NativeCurrency.Symbol)), contentWidth))
 		fmt.Printf("║ %s ║\n", lib.PadRightAnsiAware(fmt.Sprintf("%s: %d", key("Decimals"), chainInfo.NativeCurrency.Decimals), contentWidth))
+		if explorer := chainInfo.ExplorerURL(); explorer != "" {
+			fmt.Printf("║ %s ║\n", lib.PadRightAnsiAware(fmt.Sprintf("%s: %s", key("Explorer"), val(explorer)), contentWidth))
+		}
 	}
 	fmt.Println("╚═════════════════════════════════════╝")
 	fmt.Println("")
@@ -0,0 +1,139 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/zsystm/solizard/internal/client"
+)
+
+// DefaultBlocks is the number of recent blocks searched for logs by default
+const DefaultBlocks = 10_000
+
+// MaxChunkSize is the largest block range queried at once,
+// chunks are halved whenever the endpoint refuses a range as too large
+const MaxChunkSize = uint64(100_000)
+
+// Filterer is the part of the chain client fetching logs
+type Filterer interface {
+	FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error)
+}
+
+// HeaderReader is the part of the chain client reading block headers
+type HeaderReader interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+// Fetch returns the logs matching the addresses and topics in the blocks [from, to].
+// The range is queried in chunks, progress is called before each chunk if not nil.
+func Fetch(ctx context.Context, cli Filterer, addresses []common.Address, topics [][]common.Hash, from, to uint64, progress func(from, to uint64)) ([]types.Log, error) {
+	if from > to {
+		return nil, fmt.Errorf("from block %d is after to block %d", from, to)
+	}
+	chunk := min(to-from+1, MaxChunkSize)
+	var logs []types.Log
+	for start := from; start <= to; {
+		end := min(start+chunk-1, to)
+		if progress != nil {
+			progress(start, end)
+		}
+		found, err := cli.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(start),
+			ToBlock:   new(big.Int).SetUint64(end),
+			Addresses: addresses,
+			Topics:    topics,
+		})
+		if errors.Is(err, client.ErrRangeLimit) && chunk > 1 {
+			// retry the range in smaller chunks
+			chunk /= 2
+			continue
+		}
+		if err != nil {
+			return logs, fmt.Errorf("failed to get logs of blocks %d-%d: %w", start, end, err)
+		}
+		logs = append(logs, found...)
+		if end == to {
+			break
+		}
+		start = end + 1
+	}
+	return logs, nil
+}
+
+// Topics returns the topics filtering the logs of the event,
+// values are the values of the indexed arguments, nil matches any value
+func Topics(event abi.Event, values []interface{}) ([][]common.Hash, error) {
+	query := make([][]interface{}, len(values))
+	for i, v := range values {
+		if v != nil {
+			query[i] = []interface{}{v}
+		}
+	}
+	topics, err := abi.MakeTopics(query...)
+	if err != nil {
+		return nil, err
+	}
+	if !event.Anonymous {
+		topics = append([][]common.Hash{{event.ID}}, topics...)
+	}
+	// trailing wildcards are not needed
+	for len(topics) > 0 && len(topics[len(topics)-1]) == 0 {
+		topics = topics[:len(topics)-1]
+	}
+	return topics, nil
+}
+
+// BlockTimes returns the timestamps of the blocks of the logs keyed by block number
+func BlockTimes(ctx context.Context, cli HeaderReader, logs []types.Log) (map[uint64]uint64, error) {
+	times := make(map[uint64]uint64)
+	for _, l := range logs {
+		if _, ok := times[l.BlockNumber]; ok {
+			continue
+		}
+		header, err := cli.HeaderByNumber(ctx, new(big.Int).SetUint64(l.BlockNumber))
+		if err != nil {
+			return times, fmt.Errorf("failed to get block %d: %w", l.BlockNumber, err)
+		}
+		times[l.BlockNumber] = header.Time
+	}
+	return times, nil
+}
+
+// ParseBlock parses a block number, "latest", "earliest" or a number of blocks before the latest one,
+// e.g. "latest-1000"
+func ParseBlock(s string, latest uint64) (uint64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "latest":
+		return latest, nil
+	case "earliest":
+		return 0, nil
+	}
+	if back, ok := strings.CutPrefix(s, "latest-"); ok {
+		n, err := strconv.ParseUint(strings.TrimSpace(back), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number of blocks %q", back)
+		}
+		if n > latest {
+			return 0, nil
+		}
+		return latest - n, nil
+	}
+	n, err := strconv.ParseUint(s, 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid block %q, enter a number, latest, earliest or latest-<blocks>", s)
+	}
+	if n > latest {
+		return 0, fmt.Errorf("block %d is after the latest block %d", n, latest)
+	}
+	return n, nil
+}
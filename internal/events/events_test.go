@@ -0,0 +1,160 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+
+	"github.com/zsystm/solizard/internal/client"
+)
+
+// limitedFilterer refuses queries of more than limit blocks and returns a log per block otherwise
+type limitedFilterer struct {
+	limit   uint64
+	queries [][2]uint64
+}
+
+func (f *limitedFilterer) FilterLogs(_ context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	from, to := q.FromBlock.Uint64(), q.ToBlock.Uint64()
+	f.queries = append(f.queries, [2]uint64{from, to})
+	if to-from+1 > f.limit {
+		return nil, fmt.Errorf("%w (reason: block range is too large)", client.ErrRangeLimit)
+	}
+	var logs []types.Log
+	for n := from; n <= to; n++ {
+		logs = append(logs, types.Log{BlockNumber: n})
+	}
+	return logs, nil
+}
+
+func TestFetchHalvesChunks(t *testing.T) {
+	f := &limitedFilterer{limit: 10}
+	logs, err := Fetch(context.Background(), f, nil, nil, 0, 99, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 100 {
+		t.Fatalf("got %d logs, want 100", len(logs))
+	}
+	for i, l := range logs {
+		if l.BlockNumber != uint64(i) {
+			t.Fatalf("log %d is of block %d, the blocks are missing or repeated", i, l.BlockNumber)
+		}
+	}
+	// 100 blocks are refused, then 50, 25 and 12, 6 blocks are accepted
+	want := [][2]uint64{{0, 99}, {0, 49}, {0, 24}, {0, 11}, {0, 5}, {6, 11}}
+	for i, q := range want {
+		if f.queries[i] != q {
+			t.Fatalf("query %d = %v, want %v (queries: %v)", i, f.queries[i], q, f.queries)
+		}
+	}
+	for _, q := range f.queries[len(want):] {
+		if q[1]-q[0]+1 > 6 {
+			t.Errorf("query %v is larger than the halved chunk", q)
+		}
+	}
+}
+
+func TestFetchSingleBlockLimit(t *testing.T) {
+	f := &limitedFilterer{limit: 0}
+	_, err := Fetch(context.Background(), f, nil, nil, 5, 6, nil)
+	if !errors.Is(err, client.ErrRangeLimit) {
+		t.Fatalf("expected the range limit error once the chunk is a single block, got %v", err)
+	}
+	if len(f.queries) != 2 {
+		t.Errorf("got %d queries, want 2: %v", len(f.queries), f.queries)
+	}
+}
+
+func TestFetchProgress(t *testing.T) {
+	var chunks [][2]uint64
+	_, err := Fetch(context.Background(), &limitedFilterer{limit: MaxChunkSize}, nil, nil, 0, MaxChunkSize+1, func(from, to uint64) {
+		chunks = append(chunks, [2]uint64{from, to})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 2 || chunks[0] != [2]uint64{0, MaxChunkSize - 1} || chunks[1] != [2]uint64{MaxChunkSize, MaxChunkSize + 1} {
+		t.Errorf("unexpected chunks: %v", chunks)
+	}
+	if _, err = Fetch(context.Background(), &limitedFilterer{limit: 1}, nil, nil, 2, 1, nil); err == nil {
+		t.Error("expected an error for a from block after the to block")
+	}
+}
+
+func TestParseBlock(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    uint64
+		wantErr bool
+	}{
+		{in: "latest", want: 1000},
+		{in: " Latest ", want: 1000},
+		{in: "earliest", want: 0},
+		{in: "latest-100", want: 900},
+		{in: "latest-0", want: 1000},
+		{in: "latest-5000", want: 0},
+		{in: "latest-x", wantErr: true},
+		{in: "42", want: 42},
+		{in: "0x10", want: 16},
+		{in: "1000", want: 1000},
+		{in: "1001", wantErr: true},
+		{in: "-1", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseBlock(tt.in, 1000)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseBlock(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseBlock(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+// closingWatcher pushes a log of block 3, then the node closes the subscription without an error at block 5
+type closingWatcher struct {
+	limitedFilterer
+}
+
+func (w *closingWatcher) BlockNumber(context.Context) (uint64, error) {
+	return 5, nil
+}
+
+func (w *closingWatcher) SubscribeFilterLogs(_ context.Context, _ ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	ch <- types.Log{BlockNumber: 3}
+	return event.NewSubscription(func(<-chan struct{}) error { return nil }), nil
+}
+
+func TestWatchPollsAfterClosedSubscription(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var reasons []error
+	var blocks []uint64
+	err := Watch(ctx, &closingWatcher{limitedFilterer{limit: MaxChunkSize}}, nil, nil, time.Millisecond, func(reason error) {
+		reasons = append(reasons, reason)
+	}, func(l types.Log) {
+		blocks = append(blocks, l.BlockNumber)
+		if l.BlockNumber == 5 {
+			cancel()
+		}
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Watch() error = %v, want it to poll until canceled", err)
+	}
+	if len(reasons) != 1 || !errors.Is(reasons[0], ErrSubscriptionClosed) {
+		t.Errorf("unexpected polling reasons: %v", reasons)
+	}
+	// the blocks after the pushed log are polled
+	if len(blocks) != 3 || blocks[0] != 3 || blocks[1] != 4 || blocks[2] != 5 {
+		t.Errorf("got the logs of the blocks %v, want [3 4 5]", blocks)
+	}
+}
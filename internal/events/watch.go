@@ -0,0 +1,111 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// DefaultPollInterval is the interval of the polling of new logs when the endpoint doesn't support subscriptions
+const DefaultPollInterval = 2 * time.Second
+
+// ErrSubscriptionClosed is reported when the node ends the subscription without an error, the watch goes on by polling
+var ErrSubscriptionClosed = errors.New("the node closed the subscription")
+
+// Watcher is the part of the chain client watching new logs
+type Watcher interface {
+	Filterer
+	BlockNumber(ctx context.Context) (uint64, error)
+	SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error)
+}
+
+// Watch calls handle with the new logs matching the addresses and topics until ctx is done.
+// It subscribes to the logs on websocket endpoints and polls new blocks every pollInterval otherwise,
+// or once the node closes the subscription. Polling is reported by calling polling if not nil.
+func Watch(ctx context.Context, cli Watcher, addresses []common.Address, topics [][]common.Hash, pollInterval time.Duration, polling func(reason error), handle func(types.Log)) error {
+	ch := make(chan types.Log, 64)
+	sub, err := cli.SubscribeFilterLogs(ctx, ethereum.FilterQuery{Addresses: addresses, Topics: topics}, ch)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if polling != nil {
+			polling(err)
+		}
+		return poll(ctx, cli, addresses, topics, pollInterval, 0, handle)
+	}
+	defer sub.Unsubscribe()
+	// next is the block after the last handled log
+	var next uint64
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err = <-sub.Err():
+			if err != nil {
+				return fmt.Errorf("subscription failed: %w", err)
+			}
+			// the error channel is closed, handle the received logs and poll the next blocks
+			for len(ch) > 0 {
+				l := <-ch
+				handle(l)
+				next = l.BlockNumber + 1
+			}
+			if polling != nil {
+				polling(ErrSubscriptionClosed)
+			}
+			return poll(ctx, cli, addresses, topics, pollInterval, next, handle)
+		case l := <-ch:
+			handle(l)
+			next = l.BlockNumber + 1
+		}
+	}
+}
+
+// poll fetches the logs of the new blocks every interval from the block from,
+// or from the block after the latest one if from is 0
+func poll(ctx context.Context, cli Watcher, addresses []common.Address, topics [][]common.Hash, interval time.Duration, from uint64, handle func(types.Log)) error {
+	next := from
+	if next == 0 {
+		latest, err := cli.BlockNumber(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get the latest block: %w", err)
+		}
+		next = latest + 1
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+		head, err := cli.BlockNumber(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get the latest block: %w", err)
+		}
+		if head < next {
+			continue
+		}
+		logs, err := Fetch(ctx, cli, addresses, topics, next, head, nil)
+		if err != nil {
+			return err
+		}
+		for _, l := range logs {
+			handle(l)
+		}
+		next = head + 1
+	}
+}
+
+// IsNotificationsUnsupported returns true if the endpoint can't push logs, e.g. it's an http endpoint
+func IsNotificationsUnsupported(err error) bool {
+	return errors.Is(err, rpc.ErrNotificationsUnsupported)
+}
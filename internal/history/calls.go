@@ -0,0 +1,101 @@
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	internalabi "github.com/zsystm/solizard/internal/abi"
+	"github.com/zsystm/solizard/internal/step"
+)
+
+// CallsFileName is the file of the recent calls of each contract in the solizard directory
+const CallsFileName = "recent_calls.json"
+
+// MaxRecentCalls is the number of calls kept per contract
+const MaxRecentCalls = 10
+
+// Call is an invocation of a contract method with the arguments as the user entered them
+type Call struct {
+	Abi    string                 `json:"abi"`
+	Method string                 `json:"method"`
+	Kind   internalabi.MethodType `json:"kind"`
+	// Action is how the call was run, e.g. sent without waiting for its receipt or added to the Safe batch
+	Action step.Action `json:"action"`
+	Args   []string    `json:"args"`
+	// Value is the value in wei sent with a call of a payable method
+	Value string `json:"value,omitempty"`
+	Time  int64  `json:"time"`
+}
+
+// String returns a single line representation of the call, e.g. transfer(0x.., 1500000) [Write]
+func (c Call) String() string {
+	s := fmt.Sprintf("%s(%s) [%s]", c.Method, strings.Join(c.Args, ", "), c.Action.Label())
+	if c.Value != "" && c.Value != "0" {
+		s += fmt.Sprintf(" value: %s wei", c.Value)
+	}
+	return s
+}
+
+// same returns true if the calls invoke the method the same way, whatever the action
+func (c Call) same(other Call) bool {
+	return c.Method == other.Method && c.Kind == other.Kind && c.Value == other.Value && slices.Equal(c.Args, other.Args)
+}
+
+// RecentCalls are the recent calls keyed by chain id and contract address, the most recent first
+type RecentCalls map[string][]Call
+
+func callsKey(chainId uint64, address common.Address) string {
+	return fmt.Sprintf("%d:%s", chainId, address.Hex())
+}
+
+// ReadRecentCalls reads the recent calls, a missing file has no calls
+func ReadRecentCalls(path string) (RecentCalls, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return make(RecentCalls), nil
+	}
+	if err != nil {
+		return make(RecentCalls), err
+	}
+	calls := make(RecentCalls)
+	if err = json.Unmarshal(data, &calls); err != nil {
+		return make(RecentCalls), fmt.Errorf("invalid recent calls file %s: %v", path, err)
+	}
+	return calls, nil
+}
+
+// Write writes the recent calls to the file
+func (r RecentCalls) Write(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0600)
+}
+
+// Add records the call of the contract as the most recent one, a previous identical call is moved first
+func (r RecentCalls) Add(chainId uint64, address common.Address, call Call) {
+	if call.Time == 0 {
+		call.Time = time.Now().Unix()
+	}
+	key := callsKey(chainId, address)
+	calls := []Call{call}
+	for _, c := range r[key] {
+		if !c.same(call) && len(calls) < MaxRecentCalls {
+			calls = append(calls, c)
+		}
+	}
+	r[key] = calls
+}
+
+// Of returns the recent calls of the contract, the most recent first
+func (r RecentCalls) Of(chainId uint64, address common.Address) []Call {
+	return r[callsKey(chainId, address)]
+}
@@ -0,0 +1,73 @@
+package history
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	internalabi "github.com/zsystm/solizard/internal/abi"
+	"github.com/zsystm/solizard/internal/step"
+)
+
+func TestRecentCallsAdd(t *testing.T) {
+	token := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	calls := make(RecentCalls)
+	for i := 0; i < MaxRecentCalls+3; i++ {
+		calls.Add(1, token, Call{Method: "transfer", Kind: internalabi.WriteMethod, Action: step.ActionWrite, Args: []string{"0x01", fmt.Sprint(i)}})
+	}
+	recent := calls.Of(1, token)
+	if len(recent) != MaxRecentCalls {
+		t.Fatalf("got %d recent calls, want %d", len(recent), MaxRecentCalls)
+	}
+	if recent[0].Args[1] != fmt.Sprint(MaxRecentCalls+2) || recent[MaxRecentCalls-1].Args[1] != "3" {
+		t.Errorf("the oldest calls aren't dropped: %+v", recent)
+	}
+	if recent[0].Time == 0 {
+		t.Error("the time of the call isn't set")
+	}
+
+	// an identical call moves first instead of being added again
+	calls.Add(1, token, Call{Method: "transfer", Kind: internalabi.WriteMethod, Action: step.ActionWrite, Args: []string{"0x01", "5"}})
+	recent = calls.Of(1, token)
+	if len(recent) != MaxRecentCalls || recent[0].Args[1] != "5" || recent[1].Args[1] != fmt.Sprint(MaxRecentCalls+2) {
+		t.Errorf("unexpected recent calls after repeating a call: %+v", recent)
+	}
+	// the same call run by another action replaces the call
+	calls.Add(1, token, Call{Method: "transfer", Kind: internalabi.WriteMethod, Action: step.ActionQueue, Args: []string{"0x01", "5"}})
+	recent = calls.Of(1, token)
+	if len(recent) != MaxRecentCalls || recent[0].Action != step.ActionQueue || recent[1].Args[1] == "5" {
+		t.Errorf("unexpected recent calls after queuing a call: %+v", recent)
+	}
+	// the same arguments with another value are another call
+	calls.Add(1, token, Call{Method: "transfer", Kind: internalabi.WriteMethod, Action: step.ActionWrite, Args: []string{"0x01", "5"}, Value: "1"})
+	if recent = calls.Of(1, token); recent[0].Value != "1" || recent[1].Action != step.ActionQueue || recent[1].Args[1] != "5" {
+		t.Errorf("unexpected recent calls after a call with a value: %+v", recent)
+	}
+
+	if other := calls.Of(5, token); len(other) != 0 {
+		t.Errorf("calls are shared between chains: %+v", other)
+	}
+}
+
+func TestRecentCallsReadWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), CallsFileName)
+	calls, err := ReadRecentCalls(path)
+	if err != nil || len(calls) != 0 {
+		t.Fatalf("ReadRecentCalls() of a missing file = %v, %v, want no calls", calls, err)
+	}
+
+	token := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	calls.Add(1, token, Call{Abi: "TetherToken", Method: "approve", Kind: internalabi.WriteMethod, Action: step.ActionWrite, Args: []string{"0x01", "1"}, Value: "0"})
+	if err = calls.Write(path); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadRecentCalls(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := read.Of(1, token); len(got) != 1 || !got[0].same(calls.Of(1, token)[0]) || got[0].Abi != "TetherToken" || got[0].Action != step.ActionWrite {
+		t.Errorf("unexpected calls read back: %+v", got)
+	}
+}
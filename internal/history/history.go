@@ -0,0 +1,235 @@
+package history
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	internalabi "github.com/zsystm/solizard/internal/abi"
+)
+
+// FileName is the journal of sent transactions in the solizard directory, one json entry per line
+const FileName = "history.jsonl"
+
+// Status is the state of a sent transaction as last checked
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusSuccess Status = "success"
+	StatusFailed  Status = "failed"
+	// StatusReplaced is a transaction whose nonce was used by another transaction, e.g. its speed up or cancel
+	StatusReplaced Status = "replaced"
+)
+
+// Entry is a sent transaction of the journal
+type Entry struct {
+	ChainId  uint64 `json:"chain_id"`
+	Hash     string `json:"hash"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Nonce    uint64 `json:"nonce"`
+	Contract string `json:"contract,omitempty"`
+	Method   string `json:"method,omitempty"`
+	// Args are the decoded arguments of the method, their values converted with JSONValue
+	Args        []internalabi.NamedValue `json:"args,omitempty"`
+	Status      Status                   `json:"status"`
+	GasUsed     uint64                   `json:"gas_used,omitempty"`
+	BlockNumber uint64                   `json:"block_number,omitempty"`
+	// Timestamp is the unix time the transaction was sent
+	Timestamp int64 `json:"timestamp"`
+	// Replaces is the hash of the pending transaction this one speeds up or cancels
+	Replaces string `json:"replaces,omitempty"`
+}
+
+// NewEntry returns a pending entry of the transaction sent now
+func NewEntry(chainId uint64, tx *types.Transaction, from common.Address, contract, method string, args []internalabi.NamedValue) Entry {
+	e := Entry{
+		ChainId:   chainId,
+		Hash:      tx.Hash().Hex(),
+		From:      from.Hex(),
+		Nonce:     tx.Nonce(),
+		Contract:  contract,
+		Method:    method,
+		Args:      internalabi.JSONNamedValues(args),
+		Status:    StatusPending,
+		Timestamp: time.Now().Unix(),
+	}
+	if tx.To() != nil {
+		e.To = tx.To().Hex()
+	}
+	return e
+}
+
+// String returns a single line representation of the entry, e.g. 0x.. transfer(to: 0x.., value: 1) on TetherToken [success]
+func (e Entry) String() string {
+	call := e.Method
+	if call == "" {
+		call = "call"
+	}
+	call += "(" + internalabi.FormatNamedValues(e.Args) + ")"
+	target := e.To
+	if e.Contract != "" {
+		target = fmt.Sprintf("%s (%s)", e.Contract, e.To)
+	}
+	return fmt.Sprintf("%s %s on %s [%s]", e.Hash, call, target, e.Status)
+}
+
+// SetReceipt sets the status, gas used and block of the mined transaction
+func (e *Entry) SetReceipt(receipt *types.Receipt) {
+	e.Status = StatusFailed
+	if receipt.Status == types.ReceiptStatusSuccessful {
+		e.Status = StatusSuccess
+	}
+	e.GasUsed = receipt.GasUsed
+	if receipt.BlockNumber != nil {
+		e.BlockNumber = receipt.BlockNumber.Uint64()
+	}
+}
+
+// ReceiptReader is the part of the chain client fetching receipts
+type ReceiptReader interface {
+	TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error)
+}
+
+// Refresh fetches the receipt of the transaction, the entry stays pending if it's not mined yet
+func (e *Entry) Refresh(ctx context.Context, cli ReceiptReader) error {
+	receipt, err := cli.TransactionReceipt(ctx, common.HexToHash(e.Hash))
+	if errors.Is(err, ethereum.NotFound) {
+		e.Status = StatusPending
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	e.SetReceipt(receipt)
+	return nil
+}
+
+// Filter selects entries of the journal, zero fields match any entry
+type Filter struct {
+	ChainId uint64
+	// Address matches the sender or the target of the transaction
+	Address  string
+	Contract string
+	Method   string
+	Status   Status
+	// Text matches any part of the entry, case insensitively
+	Text string
+}
+
+// Match returns true if the entry matches every field of the filter
+func (f Filter) Match(e Entry) bool {
+	return (f.ChainId == 0 || e.ChainId == f.ChainId) &&
+		(f.Address == "" || strings.EqualFold(e.From, f.Address) || strings.EqualFold(e.To, f.Address)) &&
+		(f.Contract == "" || strings.EqualFold(e.Contract, f.Contract)) &&
+		(f.Method == "" || e.Method == f.Method) &&
+		(f.Status == "" || e.Status == f.Status) &&
+		(f.Text == "" || strings.Contains(strings.ToLower(e.String()), strings.ToLower(f.Text)))
+}
+
+// Select returns the entries matching the filter, the most recent first
+func Select(entries []Entry, f Filter) []Entry {
+	var out []Entry
+	for i := len(entries) - 1; i >= 0; i-- {
+		if f.Match(entries[i]) {
+			out = append(out, entries[i])
+		}
+	}
+	return out
+}
+
+// Read returns the entries of the journal in the order they were sent, a missing journal has no entries
+func Read(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var e Entry
+		if err = json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return entries, fmt.Errorf("invalid entry at line %d of %s: %v", line, path, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// Append adds the entry at the end of the journal
+func Append(path string, e Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Update replaces the entries of the journal having the same hash as the given ones
+func Update(path string, updated ...Entry) error {
+	entries, err := Read(path)
+	if err != nil {
+		return err
+	}
+	byHash := make(map[string]Entry, len(updated))
+	for _, e := range updated {
+		byHash[e.Hash] = e
+	}
+	var sb strings.Builder
+	for _, e := range entries {
+		if u, ok := byHash[e.Hash]; ok {
+			e = u
+		}
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		sb.Write(line)
+		sb.WriteByte('\n')
+	}
+	// the journal is replaced at once so an interrupted update doesn't lose entries
+	tmp, err := os.CreateTemp(filepath.Dir(path), FileName+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.WriteString(sb.String()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,122 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	internalabi "github.com/zsystm/solizard/internal/abi"
+)
+
+var testEntries = []Entry{
+	{ChainId: 1, Hash: "0x01", From: "0xAaAa", To: "0xToken", Contract: "TetherToken", Method: "transfer", Status: StatusSuccess,
+		Args: []internalabi.NamedValue{{Name: "to", Value: "0xBbBb"}}},
+	{ChainId: 1, Hash: "0x02", From: "0xAaAa", To: "0xToken", Contract: "TetherToken", Method: "approve", Status: StatusPending},
+	{ChainId: 5, Hash: "0x03", From: "0xCcCc", To: "0xAaAa", Status: StatusFailed},
+	{ChainId: 1, Hash: "0x04", From: "0xAaAa", To: "0xAaAa", Status: StatusReplaced, Replaces: "0x02"},
+}
+
+func hashes(entries []Entry) []string {
+	out := make([]string, len(entries))
+	for i, e := range entries {
+		out[i] = e.Hash
+	}
+	return out
+}
+
+func TestSelect(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{name: "all, most recent first", filter: Filter{}, want: []string{"0x04", "0x03", "0x02", "0x01"}},
+		{name: "chain", filter: Filter{ChainId: 5}, want: []string{"0x03"}},
+		{name: "sender or target", filter: Filter{Address: "0xaaaa"}, want: []string{"0x04", "0x03", "0x02", "0x01"}},
+		{name: "target", filter: Filter{Address: "0xbbbb"}, want: nil},
+		{name: "contract", filter: Filter{Contract: "tethertoken"}, want: []string{"0x02", "0x01"}},
+		{name: "method", filter: Filter{Method: "transfer"}, want: []string{"0x01"}},
+		{name: "method is case sensitive", filter: Filter{Method: "Transfer"}, want: nil},
+		{name: "status", filter: Filter{ChainId: 1, Status: StatusPending}, want: []string{"0x02"}},
+		{name: "text in args", filter: Filter{Text: "0xBBBB"}, want: []string{"0x01"}},
+		{name: "text in status", filter: Filter{Text: "replaced"}, want: []string{"0x04"}},
+		{name: "every field", filter: Filter{ChainId: 1, Contract: "TetherToken", Status: StatusSuccess, Text: "transfer"}, want: []string{"0x01"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := hashes(Select(testEntries, tt.filter))
+			if len(got) != len(tt.want) {
+				t.Fatalf("Select() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Select() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, FileName)
+	for _, e := range testEntries {
+		if err := Append(path, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mined := testEntries[1]
+	mined.Status, mined.GasUsed, mined.BlockNumber = StatusSuccess, 21000, 9
+	unknown := Entry{ChainId: 1, Hash: "0x05"}
+	if err := Update(path, mined, unknown); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the entries keep their order, unknown hashes aren't added
+	if got := hashes(entries); len(got) != 4 || got[0] != "0x01" || got[1] != "0x02" || got[2] != "0x03" || got[3] != "0x04" {
+		t.Fatalf("unexpected entries after update: %v", got)
+	}
+	if e := entries[1]; e.Status != StatusSuccess || e.GasUsed != 21000 || e.BlockNumber != 9 || e.Method != "approve" {
+		t.Errorf("unexpected updated entry: %+v", e)
+	}
+	if e := entries[0]; e.Status != StatusSuccess || len(e.Args) != 1 {
+		t.Errorf("entry which isn't updated changed: %+v", e)
+	}
+
+	// the journal is replaced by a renamed temporary file which doesn't stay behind
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name() != FileName {
+		t.Errorf("unexpected files next to the journal: %v", files)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("unexpected journal mode: %v (%v)", info.Mode(), err)
+	}
+}
+
+func TestReadMissingAndInvalid(t *testing.T) {
+	dir := t.TempDir()
+	entries, err := Read(filepath.Join(dir, FileName))
+	if err != nil || entries != nil {
+		t.Errorf("Read() of a missing journal = %v, %v, want no entries", entries, err)
+	}
+
+	path := filepath.Join(dir, "invalid.jsonl")
+	if err = os.WriteFile(path, []byte("{\"hash\":\"0x01\"}\n\nnot json\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	entries, err = Read(path)
+	if err == nil || len(entries) != 1 {
+		t.Errorf("Read() = %v, %v, want the valid entry and an error for line 3", entries, err)
+	}
+	if err = Update(path, Entry{Hash: "0x01", Status: StatusSuccess}); err == nil {
+		t.Error("expected Update() to refuse rewriting an invalid journal")
+	}
+}
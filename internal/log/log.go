@@ -1,11 +1,86 @@
 package log
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Format is the output format of solizard
+type Format string
+
+const (
+	FormatText Format = "text"
+	FormatJSON Format = "json"
+)
+
+var format = FormatText
+
+// ParseFormat returns the Format for the given string
+func ParseFormat(s string) (Format, error) {
+	switch Format(strings.ToLower(s)) {
+	case FormatText:
+		return FormatText, nil
+	case FormatJSON:
+		return FormatJSON, nil
+	default:
+		return "", fmt.Errorf("unknown output format %q (available: %s, %s)", s, FormatText, FormatJSON)
+	}
+}
+
+// SetFormat changes the output format used by all log functions
+func SetFormat(f Format) {
+	format = f
+}
+
+// IsJSON returns true if the output format is json
+func IsJSON() bool {
+	return format == FormatJSON
+}
 
 func Info(msg string) {
+	if IsJSON() {
+		Emit("info", map[string]string{"message": strings.TrimSpace(msg)})
+		return
+	}
 	fmt.Printf("🦎 %s", msg)
 }
 
 func Error(errMsg string) {
+	if IsJSON() {
+		Emit("error", map[string]string{"message": strings.TrimSpace(errMsg)})
+		return
+	}
 	fmt.Printf("👾 %s", errMsg)
 }
+
+// Emit prints a single json object of the given type to stdout, the data is nested under the "data" field
+// so its own fields (e.g. the "type" of a receipt) are kept.
+// Emit is a no-op in text mode, use Result to print both representations.
+func Emit(typ string, data interface{}) {
+	if !IsJSON() {
+		return
+	}
+	out, err := json.Marshal(record{Type: typ, Data: data})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to marshal %s output (reason: %v)\n", typ, err)
+		return
+	}
+	fmt.Println(string(out))
+}
+
+// record is a json output line
+type record struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// Result prints text in text mode and emits data as a json object of the given type in json mode
+func Result(typ string, text string, data interface{}) {
+	if IsJSON() {
+		Emit(typ, data)
+		return
+	}
+	Info(text)
+}
@@ -0,0 +1,89 @@
+package log
+
+import (
+	"encoding/json"
+	"io"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// captureStdout returns what fn prints to stdout
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	fn()
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestEmitReceipt(t *testing.T) {
+	SetFormat(FormatJSON)
+	defer SetFormat(FormatText)
+
+	receipt := &types.Receipt{
+		Type:              types.DynamicFeeTxType,
+		Status:            types.ReceiptStatusSuccessful,
+		TxHash:            common.HexToHash("0x01"),
+		BlockNumber:       big.NewInt(7),
+		GasUsed:           21000,
+		EffectiveGasPrice: big.NewInt(1),
+		Logs:              []*types.Log{},
+	}
+	out := captureStdout(t, func() { Emit("receipt", receipt) })
+
+	var record struct {
+		Type string `json:"type"`
+		Data struct {
+			Type   string `json:"type"`
+			Status string `json:"status"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(out), &record); err != nil {
+		t.Fatalf("output isn't a json object: %q", out)
+	}
+	// the type of the record doesn't replace the transaction type of the receipt
+	if record.Type != "receipt" || record.Data.Type != "0x2" || record.Data.Status != "0x1" {
+		t.Errorf("unexpected record: %s", out)
+	}
+}
+
+func TestEmit(t *testing.T) {
+	tests := []struct {
+		name string
+		data interface{}
+		want string
+	}{
+		{name: "object", data: map[string]string{"message": "hi"}, want: `{"type":"info","data":{"message":"hi"}}` + "\n"},
+		{name: "not an object", data: []int{1, 2}, want: `{"type":"info","data":[1,2]}` + "\n"},
+		{name: "without data", data: nil, want: `{"type":"info"}` + "\n"},
+	}
+	SetFormat(FormatJSON)
+	defer SetFormat(FormatText)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := captureStdout(t, func() { Emit("info", tt.data) }); got != tt.want {
+				t.Errorf("Emit() printed %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// nothing is emitted in text mode
+	SetFormat(FormatText)
+	if got := captureStdout(t, func() { Emit("info", tests[0].data) }); got != "" {
+		t.Errorf("Emit() printed %q in text mode", got)
+	}
+}
@@ -0,0 +1,76 @@
+package nonce
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// PendingNoncer is the part of the chain client reading the nonce of an account including its pending transactions
+type PendingNoncer interface {
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+}
+
+type account struct {
+	chainId uint64
+	address common.Address
+}
+
+// Manager hands out the nonces of the transactions of the accounts.
+// The nonces sent locally are tracked, so transactions sent back to back get consecutive nonces
+// even if the node doesn't count the previous ones as pending yet.
+type Manager struct {
+	mu sync.Mutex
+	// next is the nonce after the last one sent by the account
+	next map[account]uint64
+	// override is the nonce set by the user for the next transaction of the account
+	override map[account]uint64
+}
+
+func NewManager() *Manager {
+	return &Manager{next: make(map[account]uint64), override: make(map[account]uint64)}
+}
+
+// Next returns the nonce of the next transaction of the account: the nonce set by the user if any,
+// otherwise the highest of the pending nonce of the node and the nonce after the last one sent
+func (m *Manager) Next(ctx context.Context, cli PendingNoncer, chainId uint64, address common.Address) (uint64, error) {
+	acc := account{chainId, address}
+	m.mu.Lock()
+	if n, ok := m.override[acc]; ok {
+		m.mu.Unlock()
+		return n, nil
+	}
+	m.mu.Unlock()
+
+	pending, err := cli.PendingNonceAt(ctx, address)
+	if err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return max(pending, m.next[acc]), nil
+}
+
+// Set sets the nonce of the next transaction of the account, e.g. to replace a pending transaction
+// or to fill a gap after dropped transactions. It's used until a transaction is sent
+// and the nonces sent before are forgotten, the following ones continue from it.
+func (m *Manager) Set(chainId uint64, address common.Address, nonce uint64) {
+	acc := account{chainId, address}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.override[acc] = nonce
+	delete(m.next, acc)
+}
+
+// Sent records that a transaction of the account with the nonce is sent, e.g. a new transaction
+// or the replacement of a pending one. The nonce set by the user is used up unless it's after the nonce.
+func (m *Manager) Sent(chainId uint64, address common.Address, nonce uint64) {
+	acc := account{chainId, address}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if n, ok := m.override[acc]; ok && n <= nonce {
+		delete(m.override, acc)
+	}
+	m.next[acc] = max(m.next[acc], nonce+1)
+}
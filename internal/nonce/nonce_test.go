@@ -0,0 +1,117 @@
+package nonce
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// pendingNoncer returns the pending nonce of every account, like a node
+type pendingNoncer struct {
+	pending uint64
+	err     error
+}
+
+func (p *pendingNoncer) PendingNonceAt(context.Context, common.Address) (uint64, error) {
+	return p.pending, p.err
+}
+
+var (
+	alice = common.HexToAddress("0x1111111111111111111111111111111111111111")
+	bob   = common.HexToAddress("0x2222222222222222222222222222222222222222")
+)
+
+func next(t *testing.T, m *Manager, cli PendingNoncer, chainId uint64, address common.Address) uint64 {
+	t.Helper()
+	n, err := m.Next(context.Background(), cli, chainId, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestNextAfterSent(t *testing.T) {
+	m := NewManager()
+	node := &pendingNoncer{pending: 5}
+	if n := next(t, m, node, 1, alice); n != 5 {
+		t.Errorf("Next() = %d, want the pending nonce 5", n)
+	}
+
+	// the node doesn't count the sent transactions as pending yet
+	m.Sent(1, alice, 5)
+	if n := next(t, m, node, 1, alice); n != 6 {
+		t.Errorf("Next() after sending 5 = %d, want 6", n)
+	}
+	m.Sent(1, alice, 6)
+	if n := next(t, m, node, 1, alice); n != 7 {
+		t.Errorf("Next() after sending 6 = %d, want 7", n)
+	}
+	// transactions sent by other tools raise the pending nonce
+	node.pending = 10
+	if n := next(t, m, node, 1, alice); n != 10 {
+		t.Errorf("Next() = %d, want the higher pending nonce 10", n)
+	}
+	// an older nonce sent again, e.g. a replacement, doesn't lower the next one
+	m.Sent(1, alice, 3)
+	node.pending = 0
+	if n := next(t, m, node, 1, alice); n != 7 {
+		t.Errorf("Next() after replacing 3 = %d, want 7", n)
+	}
+
+	// the nonces are tracked per chain and account
+	if n := next(t, m, node, 5, alice); n != 0 {
+		t.Errorf("Next() on another chain = %d, want 0", n)
+	}
+	if n := next(t, m, node, 1, bob); n != 0 {
+		t.Errorf("Next() of another account = %d, want 0", n)
+	}
+}
+
+func TestSetOverride(t *testing.T) {
+	m := NewManager()
+	node := &pendingNoncer{pending: 8}
+	m.Sent(1, alice, 8)
+
+	// the nonce set by the user is used even if it's lower than the pending one
+	m.Set(1, alice, 4)
+	if n := next(t, m, &pendingNoncer{err: errors.New("unreachable")}, 1, alice); n != 4 {
+		t.Errorf("Next() = %d, want the set nonce 4 without asking the node", n)
+	}
+	if n := next(t, m, node, 1, alice); n != 4 {
+		t.Errorf("Next() = %d, want the set nonce until it's sent", n)
+	}
+	// the following nonces continue from it, the nonces sent before are forgotten
+	m.Sent(1, alice, 4)
+	if n := next(t, m, node, 1, alice); n != 8 {
+		t.Errorf("Next() after sending the set nonce = %d, want the pending nonce 8", n)
+	}
+	node.pending = 2
+	if n := next(t, m, node, 1, alice); n != 5 {
+		t.Errorf("Next() after sending the set nonce = %d, want 5", n)
+	}
+}
+
+func TestSentKeepsLaterOverride(t *testing.T) {
+	m := NewManager()
+	node := &pendingNoncer{pending: 3}
+
+	// replacing a transaction with a nonce before the set one keeps it, e.g. to fill a gap later
+	m.Set(1, alice, 9)
+	m.Sent(1, alice, 3)
+	if n := next(t, m, node, 1, alice); n != 9 {
+		t.Errorf("Next() = %d, want the set nonce 9", n)
+	}
+	// replacing the transaction with the set nonce uses it up
+	m.Sent(1, alice, 9)
+	if n := next(t, m, node, 1, alice); n != 10 {
+		t.Errorf("Next() = %d, want 10", n)
+	}
+}
+
+func TestNextError(t *testing.T) {
+	if _, err := NewManager().Next(context.Background(), &pendingNoncer{err: errors.New("connection refused")}, 1, alice); err == nil {
+		t.Error("expected the error of the node")
+	}
+}
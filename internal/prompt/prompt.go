@@ -2,36 +2,65 @@ package prompt
 
 import (
 	"crypto/ecdsa"
+	"encoding/hex"
 	"fmt"
 	"math/big"
-	"regexp"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/zsystm/promptui"
 
 	internalabi "github.com/zsystm/solizard/internal/abi"
 	"github.com/zsystm/solizard/internal/config"
+	"github.com/zsystm/solizard/internal/events"
+	"github.com/zsystm/solizard/internal/history"
+	"github.com/zsystm/solizard/internal/log"
 	"github.com/zsystm/solizard/internal/step"
 	"github.com/zsystm/solizard/internal/validation"
+	"github.com/zsystm/solizard/lib"
 )
 
 const DefaultPromptListSize = 10
 
-// MustSelectApplyConfig returns true if the user don't want to setup manually
-// which means the user wants to apply the config file
-func MustSelectApplyConfig() bool {
-	prompt := promptui.Prompt{
-		Label: "found config file at ~/.solizard/config.toml, apply? [Y/n]",
-	}
-	ret, _ := prompt.Run()
-	if NoSelected(ret) {
-		return false
+// ManualSetup is the profile picker item to input the network and signer manually
+const ManualSetup = "manual setup"
+
+// MustSelectProfile prompts the user to select one of the profiles of the config file
+// and returns its name, or ManualSetup if the user wants to setup manually
+func MustSelectProfile(conf *config.Config) string {
+	names := append(conf.ProfileNames(), ManualSetup)
+	items := make([]string, len(names))
+	for i, name := range names {
+		items[i] = name
+		if p, err := conf.Profile(name); err == nil {
+			items[i] = fmt.Sprintf("%s (%s, chain id: %d)", name, p.RpcURL, p.ChainId)
+		}
 	}
-	return true
+
+	idx := mustSelect(SelectPrompt{
+		Label:  "Select the network profile (from ~/.solizard/config.toml)",
+		Items:  items,
+		Search: true,
+	})
+	return names[idx]
+}
+
+// MustInputProfileName prompts the user to name the manually input network to save it as a profile.
+// It returns an empty string if the user doesn't want to save it.
+func MustInputProfileName() string {
+	return mustInput(InputPrompt{
+		Label: "Save this network as a profile? Enter the profile name (empty to skip)",
+		Validate: func(s string) error {
+			if strings.ContainsAny(s, " .[]\"\t") {
+				return fmt.Errorf("profile name must not contain spaces, dots, brackets or quotes")
+			}
+			return nil
+		},
+	})
 }
 
 // MustSelectContractABI prompts the user to select a contract ABI and returns the selected contract name and ABI
@@ -40,85 +69,274 @@ func MustSelectContractABI(abis map[string]abi.ABI) (string, abi.ABI) {
 	for name := range abis {
 		contractNames = append(contractNames, name)
 	}
+	// map iteration order is random, sort the names so that scripts select the same contract
+	sort.Strings(contractNames)
 
-	prompt := promptui.Select{
-		Label: fmt.Sprintf("Select the contract to interact (total: %d)", len(abis)),
-		Items: contractNames,
-		Size:  DefaultPromptListSize,
-		Searcher: func(input string, index int) bool {
-			// if there is a method name that contains the input, return true
-			return strings.Contains(contractNames[index], input)
-		},
-		StartInSearchMode: shouldSupportSearchMode(len(abis)),
-	}
+	idx := mustSelect(SelectPrompt{
+		Label:  fmt.Sprintf("Select the contract to interact (total: %d)", len(abis)),
+		Items:  contractNames,
+		Search: true,
+	})
+	selected := contractNames[idx]
+	return strings.TrimSuffix(selected, ".abi"), abis[selected]
+}
 
-	_, selected, err := prompt.Run()
-	if err != nil {
-		panic(err)
+// CustomRpcURL is the rpc picker item to input an rpc url which is not in the chain registry
+const CustomRpcURL = "enter a custom rpc url"
+
+// MustSelectRpcUrl prompts the user to select one of the rpc endpoints of the chain registry
+// or to input a custom one
+func MustSelectRpcUrl(endpoints []string) string {
+	if len(endpoints) == 0 {
+		return MustInputRpcUrl()
 	}
-	return strings.TrimSuffix(selected, ".abi"), abis[selected]
+	items := append(append([]string{}, endpoints...), CustomRpcURL)
+	idx := mustSelect(SelectPrompt{
+		Label:  fmt.Sprintf("Select the RPC URL (healthy endpoints from the chain registry: %d)", len(endpoints)),
+		Items:  items,
+		Search: true,
+	})
+	if idx == len(endpoints) {
+		return MustInputRpcUrl()
+	}
+	return items[idx]
 }
 
 func MustInputRpcUrl() string {
-	prompt := promptui.Prompt{
-		Label:     "Enter the RPC URL",
-		Default:   config.DefaultRpcURL,
-		AllowEdit: true,
-		Validate:  validation.ValidateRpcURL,
+	return mustInput(InputPrompt{
+		Label:    "Enter the RPC URL",
+		Default:  config.DefaultRpcURL,
+		Validate: validation.ValidateRpcURL,
+	})
+}
+
+// NewAddress is the address picker item to input an address which is not in the address book
+const NewAddress = "enter a new address"
+
+// MustSelectContractAddress prompts the user to select one of the known deployments of the contract.
+// It returns the selected entry, or nil if the user wants to input a new address.
+func MustSelectContractAddress(deployments []config.ContractInfo) *config.ContractInfo {
+	if len(deployments) == 0 {
+		return nil
 	}
-	rpcURL, err := prompt.Run()
-	if err != nil {
-		panic(err)
+	items := make([]string, 0, len(deployments)+1)
+	for _, d := range deployments {
+		items = append(items, d.String())
 	}
-	return rpcURL
-}
+	items = append(items, NewAddress)
 
-func MustSelectAddressBookUsage(contractAddr string) bool {
-	prompt := promptui.Prompt{
-		Label: fmt.Sprintf("Use %s as contract address? [Y/n]", contractAddr),
+	idx := mustSelect(SelectPrompt{
+		Label:  fmt.Sprintf("Select the contract address from the address book (total: %d)", len(deployments)),
+		Items:  items,
+		Search: true,
+	})
+	if idx == len(deployments) {
+		return nil
 	}
-	ret, _ := prompt.Run()
-	if NoSelected(ret) {
-		return false
+	return &deployments[idx]
+}
+
+// MustInputLabel prompts the user to label a new address book entry, the label can be empty
+func MustInputLabel(address string) string {
+	label := mustInput(InputPrompt{
+		Label: fmt.Sprintf("Enter a label for %s to save it in the address book (optional)", address),
+	})
+	return strings.TrimSpace(label)
+}
+
+// MustInputTags prompts the user to tag an address book entry, tags are separated by commas
+func MustInputTags() []string {
+	tags := mustInput(InputPrompt{
+		Label: "Enter tags separated by commas (optional)",
+	})
+	if strings.TrimSpace(tags) == "" {
+		return nil
 	}
-	return true
+	return strings.Split(tags, ",")
 }
 
-func MustInputContractAddress() string {
-	prompt := promptui.Prompt{
-		Label:    "Enter the contract address",
-		Validate: validation.ValidateAddress,
+// MustInputNotes prompts the user to add notes to an address book entry
+func MustInputNotes() string {
+	notes := mustInput(InputPrompt{
+		Label: "Enter notes (optional)",
+	})
+	return strings.TrimSpace(notes)
+}
+
+// BookAction is an action of the interactive address book step
+type BookAction string
+
+const (
+	BookActionList   BookAction = "list"
+	BookActionAdd    BookAction = "add"
+	BookActionRename BookAction = "rename"
+	BookActionRemove BookAction = "remove"
+	BookActionImport BookAction = "import"
+	BookActionExport BookAction = "export"
+	BookActionBack   BookAction = "back"
+)
+
+func MustSelectBookAction(chainId uint64) BookAction {
+	actions := []BookAction{BookActionList, BookActionAdd, BookActionRename, BookActionRemove, BookActionImport, BookActionExport, BookActionBack}
+	idx := mustSelect(SelectPrompt{
+		Label: fmt.Sprintf("Address book (chain id: %d)", chainId),
+		Items: toStrings(actions),
+	})
+	return actions[idx]
+}
+
+// BatchAction is an action of the interactive Safe batch step
+type BatchAction string
+
+const (
+	BatchActionList   BatchAction = "list"
+	BatchActionExport BatchAction = "export"
+	BatchActionClear  BatchAction = "clear"
+	BatchActionBack   BatchAction = "back"
+)
+
+func MustSelectBatchAction(chainId string, size int) BatchAction {
+	actions := []BatchAction{BatchActionList, BatchActionExport, BatchActionClear, BatchActionBack}
+	idx := mustSelect(SelectPrompt{
+		Label: fmt.Sprintf("Safe batch (chain id: %s, transactions: %d)", chainId, size),
+		Items: toStrings(actions),
+	})
+	return actions[idx]
+}
+
+// MustInputSafeAddress prompts the user for the address of the Safe executing the batch, it may be empty
+func MustInputSafeAddress() string {
+	address := mustInput(InputPrompt{
+		Label: "Enter the address of the Safe (optional)",
+		Validate: func(s string) error {
+			if s = strings.TrimSpace(s); s != "" && !common.IsHexAddress(s) {
+				return fmt.Errorf("invalid address")
+			}
+			return nil
+		},
+	})
+	return strings.TrimSpace(address)
+}
+
+// HistoryAction is an action of the interactive history step
+type HistoryAction string
+
+const (
+	HistoryActionList    HistoryAction = "list"
+	HistoryActionCheck   HistoryAction = "check receipt"
+	HistoryActionSpeedUp HistoryAction = "speed up"
+	HistoryActionCancel  HistoryAction = "cancel"
+	HistoryActionBack    HistoryAction = "back"
+)
+
+func MustSelectHistoryAction(chainId uint64) HistoryAction {
+	actions := []HistoryAction{HistoryActionList, HistoryActionCheck, HistoryActionSpeedUp, HistoryActionCancel, HistoryActionBack}
+	idx := mustSelect(SelectPrompt{
+		Label: fmt.Sprintf("Transaction history (chain id: %d)", chainId),
+		Items: toStrings(actions),
+	})
+	return actions[idx]
+}
+
+// MustInputHistoryFilter prompts the user for a text the listed transactions contain, it may be empty
+func MustInputHistoryFilter() string {
+	return strings.TrimSpace(mustInput(InputPrompt{
+		Label: "Filter by contract, method, address, hash or status (optional)",
+	}))
+}
+
+// MustSelectHistoryEntry prompts the user to select one of the transactions, it returns nil if there is none
+func MustSelectHistoryEntry(entries []history.Entry) *history.Entry {
+	if len(entries) == 0 {
+		log.Info("no matching transactions in the history of this chain\n")
+		return nil
 	}
-	address, err := prompt.Run()
-	if err != nil {
-		panic(err)
+	items := make([]string, len(entries))
+	for i, e := range entries {
+		items[i] = e.String()
 	}
-	return address
+
+	idx := mustSelect(SelectPrompt{
+		Label:  fmt.Sprintf("Select the transaction (total: %d)", len(entries)),
+		Items:  items,
+		Search: true,
+	})
+	return &entries[idx]
 }
 
-func MustSelectReadOrWrite() internalabi.MethodType {
-	prompt := promptui.Select{
-		Label: "Read or Write contract",
-		Items: []internalabi.MethodType{internalabi.ReadMethod, internalabi.WriteMethod},
+// MustSelectRecentCall prompts the user to select one of the recent calls, calls must not be empty
+func MustSelectRecentCall(calls []history.Call) history.Call {
+	items := make([]string, len(calls))
+	for i, c := range calls {
+		items[i] = c.String()
 	}
 
-	_, selected, err := prompt.Run()
-	if err != nil {
-		panic(err)
+	idx := mustSelect(SelectPrompt{
+		Label:  fmt.Sprintf("Select the call to edit (total: %d)", len(calls)),
+		Items:  items,
+		Search: true,
+	})
+	return calls[idx]
+}
+
+// MustSelectBookEntry prompts the user to select one of the entries, it returns nil if there is no entry
+func MustSelectBookEntry(entries []config.ContractInfo) *config.ContractInfo {
+	if len(entries) == 0 {
+		log.Info("no address book entries on this chain\n")
+		return nil
 	}
-	return internalabi.MethodType(selected)
+	items := make([]string, len(entries))
+	for i, e := range entries {
+		items[i] = e.Abi + " " + e.String()
+	}
+
+	idx := mustSelect(SelectPrompt{
+		Label:  fmt.Sprintf("Select the address book entry (total: %d)", len(entries)),
+		Items:  items,
+		Search: true,
+	})
+	return &entries[idx]
+}
+
+func MustInputFilePath(label string) string {
+	path := mustInput(InputPrompt{
+		Label: label,
+		Validate: func(s string) error {
+			if strings.TrimSpace(s) == "" {
+				return fmt.Errorf("input cannot be empty")
+			}
+			return nil
+		},
+	})
+	return strings.TrimSpace(path)
+}
+
+func MustInputContractAddress() string {
+	return mustInput(InputPrompt{
+		Label:    "Enter the contract address",
+		Validate: validation.ValidateAddress,
+	})
+}
+
+// MustSelectAction prompts the user to pick what to do with a method of the contract
+func MustSelectAction() step.Action {
+	labels := make([]string, len(step.Actions))
+	for i, action := range step.Actions {
+		labels[i] = action.Label()
+	}
+	idx := mustSelect(SelectPrompt{
+		Label: "Read or Write contract, build the calldata only or add the call to the Safe batch",
+		Items: labels,
+	})
+	return step.Actions[idx]
 }
 
 func MustInputPrivateKey() *ecdsa.PrivateKey {
-	prompt := promptui.Prompt{
+	privateKey := mustInput(InputPrompt{
 		Label:    "Enter your private key to execute contract (e.g. 1234..., no 0x prefix)",
 		Mask:     '*',
 		Validate: validation.ValidatePrivateKey,
-	}
-	privateKey, err := prompt.Run()
-	if err != nil {
-		panic(err)
-	}
+	})
 	pk, err := crypto.HexToECDSA(privateKey)
 	if err != nil {
 		panic(err)
@@ -126,168 +344,401 @@ func MustInputPrivateKey() *ecdsa.PrivateKey {
 	return pk
 }
 
-func MustInputChainID() big.Int {
-	prompt := promptui.Prompt{
-		Label:    "Enter the chain ID to execute contract method (e.g. 1 for mainnet, 3 for ropsten, 4 for rinkeby, 5 for goerli)",
-		Validate: validation.ValidateInt,
+// MustSelectAccount prompts the user to pick one of the accounts of a keystore directory
+func MustSelectAccount(accounts []string) int {
+	return mustSelect(SelectPrompt{
+		Label:  "Select the account to sign with",
+		Items:  accounts,
+		Search: true,
+	})
+}
+
+// MustInputPassword prompts the user to input the password of the keystore file
+func MustInputPassword(file string) string {
+	return mustInput(InputPrompt{
+		Label: fmt.Sprintf("Enter the password of %s", file),
+		Mask:  '*',
+	})
+}
+
+// AllEvents is the event picker item for the logs of every event of the contract
+const AllEvents = "all events"
+
+// MustSelectEvent prompts the user to pick one of the events of the contract.
+// It returns nil if the user picks AllEvents.
+func MustSelectEvent(contractABI abi.ABI) *abi.Event {
+	names := make([]string, 0, len(contractABI.Events))
+	for name := range contractABI.Events {
+		names = append(names, name)
 	}
-	chainIDStr, err := prompt.Run()
-	if err != nil {
-		panic(err)
+	sort.Strings(names)
+
+	idx := mustSelect(SelectPrompt{
+		Label:  fmt.Sprintf("Select Event (total: %d)", len(names)),
+		Items:  append([]string{AllEvents}, names...),
+		Search: true,
+	})
+	if idx == 0 {
+		return nil
 	}
-	chainID := new(big.Int)
-	chainID.SetString(chainIDStr, 10)
-	return *chainID
+	event := contractABI.Events[names[idx-1]]
+	return &event
 }
 
-func MustSelectMethod(contractABI abi.ABI, rw internalabi.MethodType) (string, abi.Method) {
-	var methodNames []string
-	for name := range internalabi.GetMethodsByType(contractABI, rw) {
-		methodNames = append(methodNames, name)
+// MustInputEventNames prompts the user for the events to watch separated by commas.
+// It returns nil if the user wants to watch every event.
+func MustInputEventNames(contractABI abi.ABI) []abi.Event {
+	names := make([]string, 0, len(contractABI.Events))
+	for name := range contractABI.Events {
+		names = append(names, name)
 	}
+	sort.Strings(names)
 
-	prompt := promptui.Select{
-		Label: fmt.Sprintf("Select Method (total: %d)", len(methodNames)),
-		Items: methodNames,
-		Size:  DefaultPromptListSize,
-		Searcher: func(input string, index int) bool {
-			// if there is a method name that contains the input, return true
-			return strings.Contains(methodNames[index], input)
+	parse := func(s string) ([]abi.Event, error) {
+		var selected []abi.Event
+		for _, name := range strings.Split(s, ",") {
+			if name = strings.TrimSpace(name); name == "" {
+				continue
+			}
+			event, ok := contractABI.Events[name]
+			if !ok {
+				return nil, fmt.Errorf("no event %q (events: %s)", name, strings.Join(names, ", "))
+			}
+			selected = append(selected, event)
+		}
+		return selected, nil
+	}
+	s := mustInput(InputPrompt{
+		Label: fmt.Sprintf("Enter the events to watch separated by commas, empty for all (%s)", strings.Join(names, ", ")),
+		Validate: func(s string) error {
+			_, err := parse(s)
+			return err
 		},
-		StartInSearchMode: shouldSupportSearchMode(len(methodNames)),
+	})
+	selected, err := parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return selected
+}
+
+// MustInputEventFilter prompts the user for the values of the indexed arguments of the event to filter the logs by.
+// A nil value matches any value, arguments whose topics can't be computed (tuples and arrays) are not asked.
+func MustInputEventFilter(event abi.Event) []interface{} {
+	var values []interface{}
+	for _, input := range event.Inputs {
+		if !input.Indexed {
+			continue
+		}
+		typ := input.Type
+		if typ.T == abi.TupleTy || typ.T == abi.SliceTy || typ.T == abi.ArrayTy {
+			values = append(values, nil)
+			continue
+		}
+		s := mustInput(InputPrompt{
+			Label: fmt.Sprintf("Filter by %s (%s), empty for any", input.Name, typ),
+			Validate: func(s string) error {
+				if s == "" {
+					return nil
+				}
+				_, err := parseArgument(s, typ)
+				return err
+			},
+		})
+		if s == "" {
+			values = append(values, nil)
+			continue
+		}
+		value, err := parseArgument(s, typ)
+		if err != nil {
+			panic(err)
+		}
+		values = append(values, value)
 	}
+	return values
+}
 
-	_, selectedMethod, err := prompt.Run()
+// MustInputBlock prompts the user for a block of the range of the logs, see events.ParseBlock
+func MustInputBlock(label, defaultBlock string, latest uint64) uint64 {
+	s := mustInput(InputPrompt{
+		Label:   fmt.Sprintf("%s (number, latest, earliest or latest-<blocks>, latest block: %d)", label, latest),
+		Default: defaultBlock,
+		Validate: func(s string) error {
+			_, err := events.ParseBlock(s, latest)
+			return err
+		},
+	})
+	block, err := events.ParseBlock(s, latest)
 	if err != nil {
 		panic(err)
 	}
+	return block
+}
 
-	return selectedMethod, contractABI.Methods[selectedMethod]
+// OtherChain is the chain picker item for chains which are not in the chain registry
+const OtherChain = "other chain (enter the rpc url)"
+
+// MustSelectChain prompts the user to search the chain registry by name, short name or chain id.
+// It returns nil if the chain is not in the registry.
+func MustSelectChain(chainInfos []*lib.ChainInfo) *lib.ChainInfo {
+	items := make([]string, 0, len(chainInfos)+1)
+	items = append(items, OtherChain)
+	for _, c := range chainInfos {
+		items = append(items, c.String())
+	}
+
+	idx := mustSelect(SelectPrompt{
+		Label:  fmt.Sprintf("Select the chain, search by name, short name or chain id (total: %d)", len(chainInfos)),
+		Items:  items,
+		Search: true,
+	})
+	if idx == 0 {
+		return nil
+	}
+	return chainInfos[idx-1]
 }
 
-func getUserInput(promptText string) (string, error) {
-	prompt := promptui.Prompt{
-		Label: promptText,
+func MustSelectMethod(contractABI abi.ABI, rw internalabi.MethodType) (string, abi.Method) {
+	var methodNames []string
+	for name := range internalabi.GetMethodsByType(contractABI, rw) {
+		methodNames = append(methodNames, name)
 	}
-	return prompt.Run()
+	sort.Strings(methodNames)
+
+	idx := mustSelect(SelectPrompt{
+		Label:  fmt.Sprintf("Select Method (total: %d)", len(methodNames)),
+		Items:  methodNames,
+		Search: true,
+	})
+	selectedMethod := methodNames[idx]
+	return selectedMethod, contractABI.Methods[selectedMethod]
 }
 
 func MustSelectStep() step.Step {
-	prompt := promptui.Select{
+	steps := []step.Step{step.StepChangeContract, step.StepChangeContractAddress, step.StepSelectMethod, step.StepRepeatCall, step.StepEditCall, step.StepEvents, step.StepWatch, step.StepSwitchNetwork, step.StepAddressBook, step.StepSafeBatch, step.StepHistory, step.StepSetNonce, step.StepExit}
+	idx := mustSelect(SelectPrompt{
 		Label: "Select the next step",
-		Items: []step.Step{step.StepChangeContract, step.StepChangeContractAddress, step.StepSelectMethod, step.StepExit},
-	}
+		Items: toStrings(steps),
+	})
+	return steps[idx]
+}
 
-	_, selected, err := prompt.Run()
-	if err != nil {
-		panic(err)
+// toStrings converts the string typed items of a select prompt
+func toStrings[T ~string](items []T) []string {
+	out := make([]string, len(items))
+	for i, item := range items {
+		out[i] = string(item)
 	}
-	return step.Step(selected)
+	return out
 }
 
-func MustCreateInputDataForMethod(method abi.Method) []byte {
-	lenInput := len(method.Inputs)
-	if lenInput == 0 {
-		// short circuit if no arguments
-		return method.ID
+// InputDataForMethod prompts the user for the arguments of the method and returns the call data.
+// Invalid arguments are asked again, an error is returned if the arguments can't be encoded.
+func InputDataForMethod(method abi.Method) ([]byte, error) {
+	args, err := InputArgsForMethod(method, nil)
+	if err != nil {
+		return nil, err
 	}
+	return PackArgs(method, args)
+}
 
-	arguments := make(abi.Arguments, 0, lenInput)
+// InputArgsForMethod prompts the user for the arguments of the method and returns them as entered,
+// the prompts are prefilled with the defaults, e.g. the arguments of a previous call
+func InputArgsForMethod(method abi.Method, defaults []string) ([]string, error) {
 	for _, input := range method.Inputs {
-		arguments = append(arguments, abi.Argument{
-			Name:    input.Name,
-			Type:    input.Type,
-			Indexed: input.Indexed,
-		})
+		if input.Type.T == abi.FixedPointTy || input.Type.T == abi.FunctionTy {
+			// TODO: implement
+			return nil, fmt.Errorf("type %s of argument %s is not supported", input.Type, input.Name)
+		}
 	}
 
 	// get user input for each argument
-	var args []interface{}
-	for _, arg := range arguments {
-		// TODO: Make validation for each type
-		strValue, err := getUserInput(fmt.Sprintf("Enter value for %s (type: %s)", arg.Name, arg.Type))
-		if err != nil {
-			panic(err)
+	args := make([]string, 0, len(method.Inputs))
+	for i, arg := range method.Inputs {
+		typ := arg.Type
+		var defaultValue string
+		if i < len(defaults) {
+			defaultValue = defaults[i]
 		}
-		var value interface{}
-		switch arg.Type.T {
-		case abi.IntTy:
-			value, _ = new(big.Int).SetString(strValue, 10)
-		case abi.UintTy:
-			parts := regexp.MustCompile(`(u)?int([0-9]*)`).FindStringSubmatch(arg.Type.String())
-			switch parts[2] {
-			case "8":
-				if parts[1] == "u" {
-					value, err = strconv.ParseUint(strValue, 10, 8)
-				} else {
-					value, err = strconv.ParseInt(strValue, 10, 8)
-				}
-			case "16":
-				if parts[1] == "u" {
-					value, err = strconv.ParseUint(strValue, 10, 16)
-				} else {
-					value, err = strconv.ParseInt(strValue, 10, 16)
-				}
-			case "32":
-				if parts[1] == "u" {
-					value, err = strconv.ParseUint(strValue, 10, 32)
-					value = uint32(value.(uint64))
-				} else {
-					value, err = strconv.ParseInt(strValue, 10, 32)
-					value = int32(value.(int64))
-				}
-			case "64":
-				if parts[1] == "u" {
-					value, err = strconv.ParseUint(strValue, 10, 64)
-				} else {
-					value, err = strconv.ParseInt(strValue, 10, 64)
-				}
-			default:
-				value, _ = new(big.Int).SetString(strValue, 10)
-			}
-			if err != nil {
-				panic(err)
-			}
-		case abi.BoolTy:
-			value = strValue == "true"
-		case abi.StringTy:
-			value = strValue
-		case abi.SliceTy, abi.ArrayTy:
-			value = internalabi.ParseArrayOrSliceInput(strValue, arg.Type)
-		case abi.TupleTy:
-			value = internalabi.ParseTupleInput(strValue, arg.Type)
-		case abi.AddressTy:
-			value = common.HexToAddress(strValue)
-		case abi.FixedBytesTy, abi.BytesTy:
-			value = common.Hex2Bytes(strValue)
-		case abi.HashTy:
-			value = common.HexToHash(strValue)
-		case abi.FixedPointTy, abi.FunctionTy:
-			// TODO: implement
-			panic("type not supported")
-		default:
-			panic("unsupported type")
+		args = append(args, mustInput(InputPrompt{
+			Label:   fmt.Sprintf("Enter value for %s (type: %s)", arg.Name, typ),
+			Default: defaultValue,
+			Validate: func(s string) error {
+				_, err := parseArgument(s, typ)
+				return err
+			},
+		}))
+	}
+	return args, nil
+}
+
+// PackArgs parses the arguments entered for the method and returns the call data
+func PackArgs(method abi.Method, args []string) ([]byte, error) {
+	if len(method.Inputs) == 0 {
+		// short circuit if no arguments
+		return method.ID, nil
+	}
+	if len(args) != len(method.Inputs) {
+		return nil, fmt.Errorf("%s expects %d arguments, got %d", method.Name, len(method.Inputs), len(args))
+	}
+	values := make([]interface{}, 0, len(args))
+	for i, arg := range method.Inputs {
+		value, err := parseArgument(args[i], arg.Type)
+		if err != nil {
+			return nil, err
 		}
-		// change value to the correct type
-		args = append(args, value)
+		values = append(values, value)
 	}
 	// pack the arguments
-	data, err := method.Inputs.Pack(args...)
+	data, err := method.Inputs.Pack(values...)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("failed to encode the arguments: %v", err)
 	}
-	return append(method.ID, data...)
+	return append(method.ID, data...), nil
 }
 
-func MustInputValue() *big.Int {
-	prompt := promptui.Prompt{
-		Label:    "Enter the value to be sent with the contract call (in wei)",
-		Validate: validation.ValidateInt,
+// parseArgument converts the input to the go value of the abi type
+func parseArgument(input string, typ abi.Type) (value interface{}, err error) {
+	switch typ.T {
+	case abi.IntTy, abi.UintTy:
+		n, ok := new(big.Int).SetString(strings.TrimSpace(input), 0)
+		if !ok {
+			return nil, fmt.Errorf("invalid integer %q", input)
+		}
+		return sizedInt(n, typ)
+	case abi.BoolTy:
+		b, err := strconv.ParseBool(strings.TrimSpace(input))
+		if err != nil {
+			return nil, fmt.Errorf("invalid bool %q, enter true or false", input)
+		}
+		return b, nil
+	case abi.StringTy:
+		return input, nil
+	case abi.AddressTy:
+		if !common.IsHexAddress(strings.TrimSpace(input)) {
+			return nil, fmt.Errorf("invalid address %q", input)
+		}
+		return common.HexToAddress(strings.TrimSpace(input)), nil
+	case abi.BytesTy:
+		return parseHex(input)
+	case abi.FixedBytesTy:
+		b, err := parseHex(input)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) > typ.Size {
+			return nil, fmt.Errorf("%d bytes don't fit into %s", len(b), typ)
+		}
+		// right padded like solidity's bytesN literals
+		fixed := reflect.New(typ.GetType()).Elem()
+		reflect.Copy(fixed, reflect.ValueOf(b))
+		return fixed.Interface(), nil
+	case abi.HashTy:
+		return common.HexToHash(input), nil
+	case abi.SliceTy, abi.ArrayTy, abi.TupleTy:
+		// the parsers panic on unsupported element types
+		defer func() {
+			if r := recover(); r != nil {
+				value, err = nil, fmt.Errorf("%v", r)
+			}
+		}()
+		if typ.T == abi.TupleTy {
+			return internalabi.ParseTupleInput(input, typ), nil
+		}
+		return internalabi.ParseArrayOrSliceInput(input, typ), nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", typ)
+	}
+}
+
+// sizedInt converts n to the go type of the integer type, e.g. uint8 for uint8 and *big.Int for uint256
+func sizedInt(n *big.Int, typ abi.Type) (interface{}, error) {
+	if typ.T == abi.UintTy {
+		if n.Sign() < 0 || n.BitLen() > typ.Size {
+			return nil, fmt.Errorf("%s is out of range of %s", n, typ)
+		}
+	} else {
+		limit := new(big.Int).Lsh(big.NewInt(1), uint(typ.Size-1))
+		if n.Cmp(limit) >= 0 || n.Cmp(new(big.Int).Neg(limit)) < 0 {
+			return nil, fmt.Errorf("%s is out of range of %s", n, typ)
+		}
+	}
+	rt := typ.GetType()
+	switch rt.Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v := reflect.New(rt).Elem()
+		v.SetUint(n.Uint64())
+		return v.Interface(), nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v := reflect.New(rt).Elem()
+		v.SetInt(n.Int64())
+		return v.Interface(), nil
 	}
-	valueStr, err := prompt.Run()
+	return n, nil
+}
+
+func parseHex(input string) ([]byte, error) {
+	input = strings.TrimPrefix(strings.TrimSpace(input), "0x")
+	b, err := hex.DecodeString(input)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex %q", input)
+	}
+	return b, nil
+}
+
+// MustInputUint prompts the user for an unsigned integer, e.g. the nonce of an offline transaction
+func MustInputUint(label, defaultValue string) uint64 {
+	s := mustInput(InputPrompt{
+		Label:   label,
+		Default: defaultValue,
+		Validate: func(s string) error {
+			if _, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64); err != nil {
+				return fmt.Errorf("invalid number %q", s)
+			}
+			return nil
+		},
+	})
+	n, err := strconv.ParseUint(strings.TrimSpace(s), 10, 64)
 	if err != nil {
 		panic(err)
 	}
+	return n
+}
+
+// MustInputWei prompts the user for an amount in wei, e.g. the gas price of an offline transaction
+func MustInputWei(label, defaultValue string) *big.Int {
+	s := mustInput(InputPrompt{
+		Label:    label,
+		Default:  defaultValue,
+		Validate: validation.ValidateInt,
+	})
+	wei, _ := new(big.Int).SetString(s, 10)
+	return wei
+}
+
+// calldata output formats of the build only mode
+const (
+	CalldataHex  = "calldata"
+	CalldataJSON = "json transaction"
+)
+
+// MustSelectCalldataFormat prompts the user to print the built calldata as hex or as a json transaction object
+func MustSelectCalldataFormat() string {
+	formats := []string{CalldataHex, CalldataJSON}
+	idx := mustSelect(SelectPrompt{
+		Label: "Print the calldata as",
+		Items: formats,
+	})
+	return formats[idx]
+}
+
+// MustInputValue prompts the user for the value of the call in wei, prefilled with the default if not empty
+func MustInputValue(defaultValue string) *big.Int {
+	valueStr := mustInput(InputPrompt{
+		Label:    "Enter the value to be sent with the contract call (in wei)",
+		Default:  defaultValue,
+		Validate: validation.ValidateInt,
+	})
 	value := new(big.Int)
 	value.SetString(valueStr, 10)
 	return value
@@ -299,6 +750,18 @@ func shouldSupportSearchMode(listLen int) bool {
 	return listLen > SelectableListSize
 }
 
+// MustConfirm asks the user a yes or no question which defaults to no
+func MustConfirm(label string) bool {
+	return YesSelected(mustInput(InputPrompt{
+		Label: label + " [y/N]",
+	}))
+}
+
+func YesSelected(s string) bool {
+	s = strings.ToLower(strings.TrimSpace(s))
+	return s == "y" || s == "yes"
+}
+
 func NoSelected(s string) bool {
 	return strings.ToLower(s) == "n"
 }
@@ -0,0 +1,51 @@
+package prompt
+
+// SelectPrompt asks the user to pick one of the items
+type SelectPrompt struct {
+	Label string
+	Items []string
+	// Search allows searching the items by a case-insensitive substring
+	Search bool
+}
+
+// InputPrompt asks the user to enter a value
+type InputPrompt struct {
+	Label string
+	// Default is prefilled and editable
+	Default string
+	// Mask hides the entered characters, e.g. for private keys
+	Mask     rune
+	Validate func(string) error
+}
+
+// Prompter asks the user the questions of the Must* functions
+type Prompter interface {
+	// Select returns the index of the selected item
+	Select(p SelectPrompt) (int, error)
+	// Input returns the validated input
+	Input(p InputPrompt) (string, error)
+}
+
+// current is the prompter used by the Must* functions
+var current Prompter = Promptui{}
+
+// SetPrompter replaces the prompter used by the Must* functions, e.g. with a Script
+func SetPrompter(p Prompter) {
+	current = p
+}
+
+func mustSelect(p SelectPrompt) int {
+	idx, err := current.Select(p)
+	if err != nil {
+		panic(err)
+	}
+	return idx
+}
+
+func mustInput(p InputPrompt) string {
+	input, err := current.Input(p)
+	if err != nil {
+		panic(err)
+	}
+	return input
+}
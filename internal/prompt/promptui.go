@@ -0,0 +1,60 @@
+package prompt
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/chzyer/readline"
+	"github.com/zsystm/promptui"
+
+	"github.com/zsystm/solizard/internal/log"
+)
+
+// errors returned by the prompts when the user presses Ctrl-C or Ctrl-D
+var (
+	ErrInterrupt = promptui.ErrInterrupt
+	ErrEOF       = promptui.ErrEOF
+)
+
+// Promptui asks the user in the terminal
+type Promptui struct{}
+
+func (Promptui) Select(p SelectPrompt) (int, error) {
+	prompt := promptui.Select{
+		Label:  p.Label,
+		Items:  p.Items,
+		Size:   DefaultPromptListSize,
+		Stdout: terminal(),
+	}
+	if p.Search {
+		prompt.Searcher = func(input string, index int) bool {
+			return strings.Contains(strings.ToLower(p.Items[index]), strings.ToLower(input))
+		}
+		prompt.StartInSearchMode = shouldSupportSearchMode(len(p.Items))
+	}
+	idx, _, err := prompt.Run()
+	return idx, err
+}
+
+func (Promptui) Input(p InputPrompt) (string, error) {
+	prompt := promptui.Prompt{
+		Label:     p.Label,
+		Default:   p.Default,
+		AllowEdit: p.Default != "",
+		Mask:      p.Mask,
+		Validate:  p.Validate,
+	}
+	// promptui.Prompt doesn't expose its output, it draws on the default output of readline
+	readline.Stdout = terminal()
+	return prompt.Run()
+}
+
+// terminal returns the output the prompts are drawn on,
+// stderr in json mode so stdout only has the json records
+func terminal() io.WriteCloser {
+	if log.IsJSON() {
+		return os.Stderr
+	}
+	return os.Stdout
+}
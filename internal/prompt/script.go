@@ -0,0 +1,214 @@
+package prompt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/zsystm/solizard/internal/log"
+)
+
+// SecretEnv is the prefix of the environment variables masked inputs (private keys, passwords) are recorded as,
+// so recorded scripts never contain secrets. The n-th masked input is recorded as ${SOLIZARD_SECRET_n}.
+const SecretEnv = "SOLIZARD_SECRET"
+
+// ErrScriptEnded is returned when a script without fallback has no more answers
+var ErrScriptEnded = errors.New("script ended")
+
+// Script replays the answers of a script, one answer per line.
+// Lines starting with # are comments, empty lines are empty answers.
+// Selections are answered with the item (or a unique item prefix),
+// a line of the form ${NAME} is read from the environment variable NAME.
+// A line starting with \ is the answer after the backslash, taken literally,
+// e.g. \#1 answers "#1" and \${NAME} answers "${NAME}".
+// When the answers run out, the fallback prompter is asked if set.
+type Script struct {
+	answers  []string
+	lines    []int
+	next     int
+	fallback Prompter
+}
+
+// NewScript reads the script's answers
+func NewScript(r io.Reader, fallback Prompter) (*Script, error) {
+	s := &Script{fallback: fallback}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if strings.HasPrefix(text, "#") {
+			continue
+		}
+		s.answers = append(s.answers, text)
+		s.lines = append(s.lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// LoadScript reads the script file
+func LoadScript(path string, fallback Prompter) (*Script, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewScript(f, fallback)
+}
+
+// Done returns true if all answers were replayed
+func (s *Script) Done() bool {
+	return s.next >= len(s.answers)
+}
+
+func (s *Script) Select(p SelectPrompt) (int, error) {
+	if s.Done() {
+		if s.fallback == nil {
+			return 0, fmt.Errorf("%w (question: %s)", ErrScriptEnded, p.Label)
+		}
+		return s.fallback.Select(p)
+	}
+	answer, line, err := s.pop()
+	if err != nil {
+		return 0, err
+	}
+	idx := indexOf(p.Items, answer)
+	if idx == -1 && answer != "" {
+		for i, item := range p.Items {
+			if !strings.HasPrefix(item, answer) {
+				continue
+			}
+			if idx != -1 {
+				return 0, fmt.Errorf("script line %d: %q matches several items of %q", line, answer, p.Label)
+			}
+			idx = i
+		}
+	}
+	if idx == -1 {
+		return 0, fmt.Errorf("script line %d: %q is not an item of %q (items: %s)", line, answer, p.Label, strings.Join(p.Items, ", "))
+	}
+	echo(p.Label, p.Items[idx])
+	return idx, nil
+}
+
+func (s *Script) Input(p InputPrompt) (string, error) {
+	if s.Done() {
+		if s.fallback == nil {
+			return "", fmt.Errorf("%w (question: %s)", ErrScriptEnded, p.Label)
+		}
+		return s.fallback.Input(p)
+	}
+	answer, line, err := s.pop()
+	if err != nil {
+		return "", err
+	}
+	if answer == "" {
+		answer = p.Default
+	}
+	if p.Validate != nil {
+		if err = p.Validate(answer); err != nil {
+			return "", fmt.Errorf("script line %d: invalid answer to %q: %v", line, p.Label, err)
+		}
+	}
+	shown := answer
+	if p.Mask != 0 {
+		shown = strings.Repeat(string(p.Mask), len(answer))
+	}
+	echo(p.Label, shown)
+	return answer, nil
+}
+
+func indexOf(items []string, item string) int {
+	for i, it := range items {
+		if it == item {
+			return i
+		}
+	}
+	return -1
+}
+
+// echo prints the replayed answer like the terminal prompt shows it
+func echo(label, answer string) {
+	if !log.IsJSON() {
+		fmt.Printf("%s: %s\n", label, answer)
+	}
+}
+
+// pop returns the next answer with its line number, expanding environment variables and unescaping literal answers
+func (s *Script) pop() (string, int, error) {
+	answer, line := s.answers[s.next], s.lines[s.next]
+	s.next++
+	if strings.HasPrefix(answer, `\`) {
+		return answer[1:], line, nil
+	}
+	if isEnvRef(answer) {
+		name := answer[2 : len(answer)-1]
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", line, fmt.Errorf("script line %d: environment variable %s is not set", line, name)
+		}
+		answer = value
+	}
+	return answer, line, nil
+}
+
+// isEnvRef returns true if the script line is read from an environment variable
+func isEnvRef(line string) bool {
+	return strings.HasPrefix(line, "${") && strings.HasSuffix(line, "}")
+}
+
+// Recorder records the answers of another prompter as a script which can be replayed with Script.
+// Masked inputs are recorded as ${SOLIZARD_SECRET_1}, ${SOLIZARD_SECRET_2}, ... in the order they are asked.
+type Recorder struct {
+	prompter Prompter
+	w        io.Writer
+	// secrets is the number of masked inputs recorded
+	secrets int
+}
+
+func NewRecorder(prompter Prompter, w io.Writer) *Recorder {
+	return &Recorder{prompter: prompter, w: w}
+}
+
+func (r *Recorder) Select(p SelectPrompt) (int, error) {
+	idx, err := r.prompter.Select(p)
+	if err != nil {
+		return idx, err
+	}
+	return idx, r.record(p.Label, escape(p.Items[idx]))
+}
+
+func (r *Recorder) Input(p InputPrompt) (string, error) {
+	input, err := r.prompter.Input(p)
+	if err != nil {
+		return input, err
+	}
+	answer := escape(input)
+	if p.Mask != 0 {
+		r.secrets++
+		answer = fmt.Sprintf("${%s_%d}", SecretEnv, r.secrets)
+	}
+	return input, r.record(p.Label, answer)
+}
+
+// escape returns the script line answering the input literally
+func escape(input string) string {
+	if strings.HasPrefix(input, "#") || strings.HasPrefix(input, `\`) || isEnvRef(input) {
+		return `\` + input
+	}
+	return input
+}
+
+func (r *Recorder) record(label, answer string) error {
+	if strings.ContainsAny(answer, "\r\n") {
+		return fmt.Errorf("can't record multi-line answer to %q", label)
+	}
+	if _, err := fmt.Fprintf(r.w, "# %s\n%s\n", label, answer); err != nil {
+		return fmt.Errorf("failed to record answer (reason: %v)", err)
+	}
+	return nil
+}
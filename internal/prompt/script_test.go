@@ -0,0 +1,134 @@
+package prompt
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// answers is a prompter answering with the given inputs and selected items in order
+type answers []string
+
+func (a *answers) pop() (string, error) {
+	if len(*a) == 0 {
+		return "", errors.New("no more answers")
+	}
+	answer := (*a)[0]
+	*a = (*a)[1:]
+	return answer, nil
+}
+
+func (a *answers) Select(p SelectPrompt) (int, error) {
+	answer, err := a.pop()
+	if err != nil {
+		return 0, err
+	}
+	if idx := indexOf(p.Items, answer); idx != -1 {
+		return idx, nil
+	}
+	return 0, errors.New("unknown item " + answer)
+}
+
+func (a *answers) Input(InputPrompt) (string, error) {
+	return a.pop()
+}
+
+// session asks the questions of a session signing with a keystore, then with a private key
+func session(p Prompter) ([]string, error) {
+	var got []string
+	questions := []interface{}{
+		SelectPrompt{Label: "Select the method", Items: []string{"#mint", "transfer", `\burn`}},
+		InputPrompt{Label: "Enter the keystore password", Mask: '*'},
+		InputPrompt{Label: "Enter the memo"},
+		InputPrompt{Label: "Enter the private key", Mask: '*'},
+		InputPrompt{Label: "Enter the template"},
+		InputPrompt{Label: "Enter the path"},
+		InputPrompt{Label: "Enter the amount"},
+	}
+	for _, q := range questions {
+		switch q := q.(type) {
+		case SelectPrompt:
+			idx, err := p.Select(q)
+			if err != nil {
+				return got, err
+			}
+			got = append(got, q.Items[idx])
+		case InputPrompt:
+			input, err := p.Input(q)
+			if err != nil {
+				return got, err
+			}
+			got = append(got, input)
+		}
+	}
+	return got, nil
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	inputs := []string{"#mint", "hunter2", "# not a comment", "0xabc", "${HOME}", `\\server\share`, ""}
+	prompter := answers(append([]string(nil), inputs...))
+	var recorded strings.Builder
+	if _, err := session(NewRecorder(&prompter, &recorded)); err != nil {
+		t.Fatal(err)
+	}
+	script := recorded.String()
+	for _, secret := range []string{"hunter2", "0xabc"} {
+		if strings.Contains(script, secret) {
+			t.Errorf("the secret %s is recorded:\n%s", secret, script)
+		}
+	}
+	// each secret is recorded as its own variable
+	if !strings.Contains(script, "${SOLIZARD_SECRET_1}\n") || !strings.Contains(script, "${SOLIZARD_SECRET_2}\n") {
+		t.Fatalf("the secrets aren't recorded as indexed variables:\n%s", script)
+	}
+
+	t.Setenv("SOLIZARD_SECRET_1", "hunter2")
+	t.Setenv("SOLIZARD_SECRET_2", "0xabc")
+	replay, err := NewScript(strings.NewReader(script), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := session(replay)
+	if err != nil {
+		t.Fatalf("replay failed: %v\nscript:\n%s", err, script)
+	}
+	if strings.Join(got, "|") != strings.Join(inputs, "|") {
+		t.Errorf("replayed %q, want %q\nscript:\n%s", got, inputs, script)
+	}
+	if !replay.Done() {
+		t.Error("answers left after the replay")
+	}
+}
+
+func TestScriptLines(t *testing.T) {
+	t.Setenv("SOLIZARD_TEST_ANSWER", "from env")
+	tests := []struct {
+		name    string
+		line    string
+		want    string
+		wantErr bool
+	}{
+		{name: "plain", line: "transfer", want: "transfer"},
+		{name: "environment variable", line: "${SOLIZARD_TEST_ANSWER}", want: "from env"},
+		{name: "unset environment variable", line: "${SOLIZARD_TEST_UNSET}", wantErr: true},
+		{name: "escaped comment", line: `\# 1`, want: "# 1"},
+		{name: "escaped environment variable", line: `\${SOLIZARD_TEST_ANSWER}`, want: "${SOLIZARD_TEST_ANSWER}"},
+		{name: "escaped backslash", line: `\\x`, want: `\x`},
+		{name: "empty takes the default", line: "", want: "default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script, err := NewScript(strings.NewReader("# a comment\n"+tt.line+"\n"), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := script.Input(InputPrompt{Label: "answer", Default: "default"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Input() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Input() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package safe
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	internalabi "github.com/zsystm/solizard/internal/abi"
+)
+
+// BatchVersion is the version of the Safe Transaction Builder file format
+const BatchVersion = "1.0"
+
+// Batch is a Safe Transaction Builder batch file, it's imported in the Transaction Builder app of the Safe
+// which proposes the transactions as a single multisend transaction
+type Batch struct {
+	Version      string        `json:"version"`
+	ChainId      string        `json:"chainId"`
+	CreatedAt    int64         `json:"createdAt"`
+	Meta         Meta          `json:"meta"`
+	Transactions []Transaction `json:"transactions"`
+}
+
+type Meta struct {
+	Name                   string `json:"name"`
+	Description            string `json:"description"`
+	TxBuilderVersion       string `json:"txBuilderVersion,omitempty"`
+	CreatedFromSafeAddress string `json:"createdFromSafeAddress,omitempty"`
+}
+
+// Transaction is a call of the batch. The Transaction Builder encodes it from the method and the input values,
+// Data is only set if the method can't be described, e.g. it has unnamed inputs.
+type Transaction struct {
+	To                   string            `json:"to"`
+	Value                string            `json:"value"`
+	Data                 *string           `json:"data"`
+	ContractMethod       *ContractMethod   `json:"contractMethod"`
+	ContractInputsValues map[string]string `json:"contractInputsValues"`
+}
+
+type ContractMethod struct {
+	Inputs  []Input `json:"inputs"`
+	Name    string  `json:"name"`
+	Payable bool    `json:"payable"`
+}
+
+type Input struct {
+	InternalType string  `json:"internalType"`
+	Name         string  `json:"name"`
+	Type         string  `json:"type"`
+	Components   []Input `json:"components,omitempty"`
+}
+
+// NewBatch returns an empty batch of the chain
+func NewBatch(chainId uint64) *Batch {
+	return &Batch{
+		Version:      BatchVersion,
+		ChainId:      fmt.Sprintf("%d", chainId),
+		Meta:         Meta{Name: "Transactions Batch"},
+		Transactions: make([]Transaction, 0),
+	}
+}
+
+// Add adds the call of the method with the calldata to the batch
+func (b *Batch) Add(to common.Address, value *big.Int, method abi.Method, calldata []byte) error {
+	tx := Transaction{To: to.Hex(), Value: value.String()}
+	values, err := method.Inputs.Unpack(calldata[4:])
+	if err != nil {
+		return fmt.Errorf("failed to unpack the arguments of %s: %v", method.Name, err)
+	}
+	if hasUnnamedInputs(method.Inputs) {
+		data := hexutil.Encode(calldata)
+		tx.Data = &data
+	} else {
+		tx.ContractMethod = &ContractMethod{Inputs: inputs(method.Inputs), Name: method.Name, Payable: method.IsPayable()}
+		tx.ContractInputsValues = make(map[string]string, len(values))
+		for i, v := range values {
+			if tx.ContractInputsValues[method.Inputs[i].Name], err = inputValue(v); err != nil {
+				return err
+			}
+		}
+	}
+	b.Transactions = append(b.Transactions, tx)
+	return nil
+}
+
+// Write writes the batch to the file, createdAt is set to the current time
+func (b *Batch) Write(path string) error {
+	b.CreatedAt = time.Now().UnixMilli()
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func hasUnnamedInputs(args abi.Arguments) bool {
+	for _, arg := range args {
+		if arg.Name == "" {
+			return true
+		}
+	}
+	return false
+}
+
+func inputs(args abi.Arguments) []Input {
+	out := make([]Input, len(args))
+	for i, arg := range args {
+		out[i] = Input{InternalType: typeName(arg.Type), Name: arg.Name, Type: typeName(arg.Type), Components: components(arg.Type)}
+	}
+	return out
+}
+
+// typeName returns the name of the type in a json abi, tuples are named tuple and described by their components,
+// e.g. tuple[] instead of (address,uint256)[]
+func typeName(typ abi.Type) string {
+	switch typ.T {
+	case abi.TupleTy:
+		return "tuple"
+	case abi.SliceTy:
+		return typeName(*typ.Elem) + "[]"
+	case abi.ArrayTy:
+		return fmt.Sprintf("%s[%d]", typeName(*typ.Elem), typ.Size)
+	}
+	return typ.String()
+}
+
+// components returns the fields of the tuple type, or of the tuple elements of an array type
+func components(typ abi.Type) []Input {
+	for typ.T == abi.SliceTy || typ.T == abi.ArrayTy {
+		typ = *typ.Elem
+	}
+	if typ.T != abi.TupleTy {
+		return nil
+	}
+	out := make([]Input, len(typ.TupleElems))
+	for i, elem := range typ.TupleElems {
+		out[i] = Input{InternalType: typeName(*elem), Name: typ.TupleRawNames[i], Type: typeName(*elem), Components: components(*elem)}
+	}
+	return out
+}
+
+// inputValue formats the value as the Transaction Builder expects it,
+// lists and tuples are json arrays whose numbers are decimal strings
+func inputValue(v interface{}) (string, error) {
+	switch val := builderValue(v).(type) {
+	case string:
+		return val, nil
+	case []interface{}:
+		data, err := json.Marshal(val)
+		return string(data), err
+	default:
+		return fmt.Sprintf("%v", val), nil
+	}
+}
+
+// builderValue converts the unpacked value like JSONValue, except tuples are arrays of their fields in order
+func builderValue(v interface{}) interface{} {
+	rv := reflect.ValueOf(v)
+	switch {
+	case rv.Kind() == reflect.Struct:
+		out := make([]interface{}, rv.NumField())
+		for i := range out {
+			out[i] = builderValue(rv.Field(i).Interface())
+		}
+		return out
+	case rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8,
+		rv.Kind() == reflect.Array && rv.Type().Elem().Kind() != reflect.Uint8:
+		out := make([]interface{}, rv.Len())
+		for i := range out {
+			out[i] = builderValue(rv.Index(i).Interface())
+		}
+		return out
+	case rv.CanInt() || rv.CanUint():
+		// integers of up to 64 bits are unpacked as go integers instead of big.Int
+		return fmt.Sprint(v)
+	}
+	return internalabi.JSONValue(v)
+}
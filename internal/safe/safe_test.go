@@ -0,0 +1,73 @@
+package safe
+
+import (
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const ordersABI = `[{"type": "function", "name": "fill", "stateMutability": "nonpayable", "outputs": [], "inputs": [
+	{"name": "orders", "type": "tuple[]", "internalType": "struct Order[]", "components": [
+		{"name": "maker", "type": "address"},
+		{"name": "amount", "type": "uint256"},
+		{"name": "legs", "type": "tuple[2]", "internalType": "struct Leg[2]", "components": [{"name": "id", "type": "uint8"}]}
+	]},
+	{"name": "recipient", "type": "address"}
+]}]`
+
+type leg struct {
+	Id uint8
+}
+
+type order struct {
+	Maker  common.Address
+	Amount *big.Int
+	Legs   [2]leg
+}
+
+func TestAddTupleSlice(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(ordersABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	maker := common.HexToAddress("0x000000000000000000000000000000000000dEaD")
+	recipient := common.HexToAddress("0x00000000000000000000000000000000000000Aa")
+	calldata, err := parsed.Pack("fill", []order{{Maker: maker, Amount: big.NewInt(5), Legs: [2]leg{{Id: 1}, {Id: 2}}}}, recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	batch := NewBatch(1)
+	if err = batch.Add(recipient, big.NewInt(0), parsed.Methods["fill"], calldata); err != nil {
+		t.Fatal(err)
+	}
+	tx := batch.Transactions[0]
+	if tx.Data != nil || tx.ContractMethod == nil {
+		t.Fatalf("the method isn't described: %+v", tx)
+	}
+	// tuples are described by their components, as in a json abi
+	want := []Input{
+		{InternalType: "tuple[]", Name: "orders", Type: "tuple[]", Components: []Input{
+			{InternalType: "address", Name: "maker", Type: "address"},
+			{InternalType: "uint256", Name: "amount", Type: "uint256"},
+			{InternalType: "tuple[2]", Name: "legs", Type: "tuple[2]", Components: []Input{
+				{InternalType: "uint8", Name: "id", Type: "uint8"},
+			}},
+		}},
+		{InternalType: "address", Name: "recipient", Type: "address"},
+	}
+	if !reflect.DeepEqual(tx.ContractMethod.Inputs, want) {
+		t.Errorf("inputs = %+v, want %+v", tx.ContractMethod.Inputs, want)
+	}
+	wantValues := map[string]string{
+		"orders":    `[["0x000000000000000000000000000000000000dEaD","5",[["1"],["2"]]]]`,
+		"recipient": recipient.Hex(),
+	}
+	if !reflect.DeepEqual(tx.ContractInputsValues, wantValues) {
+		t.Errorf("values = %v, want %v", tx.ContractInputsValues, wantValues)
+	}
+}
@@ -0,0 +1,54 @@
+package step
+
+import internalabi "github.com/zsystm/solizard/internal/abi"
+
+// Action is what the session does with the method selected at StepSelectMethod,
+// it's saved with the recent calls so they are run again the same way
+type Action string
+
+const (
+	ActionRead  Action = "read"
+	ActionWrite Action = "write"
+	// ActionQueue sends a write without waiting for its receipt, so several writes can be sent back to back
+	ActionQueue Action = "queue"
+	// ActionBuild builds the calldata of any method without calling or sending it
+	ActionBuild Action = "build"
+	// ActionBatch adds a call of a write method to the Safe batch instead of sending it
+	ActionBatch Action = "batch"
+)
+
+// Actions are the actions offered after selecting a contract, in the order of the picker
+var Actions = []Action{ActionRead, ActionWrite, ActionQueue, ActionBuild, ActionBatch}
+
+var actionLabels = map[Action]string{
+	ActionRead:  "Read",
+	ActionWrite: "Write",
+	ActionQueue: "Write without waiting",
+	ActionBuild: "Build calldata",
+	ActionBatch: "Add to Safe batch",
+}
+
+// Label returns the label of the action in the picker, e.g. "Write without waiting"
+func (a Action) Label() string {
+	if label, ok := actionLabels[a]; ok {
+		return label
+	}
+	return string(a)
+}
+
+// MethodType returns the type of the methods the action applies to
+func (a Action) MethodType() internalabi.MethodType {
+	switch a {
+	case ActionRead:
+		return internalabi.ReadMethod
+	case ActionBuild:
+		return internalabi.AllMethod
+	default:
+		return internalabi.WriteMethod
+	}
+}
+
+// Sends returns true if the action sends the call as a transaction
+func (a Action) Sends() bool {
+	return a == ActionWrite || a == ActionQueue
+}
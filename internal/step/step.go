@@ -1,10 +1,64 @@
 package step
 
+import "fmt"
+
 type Step string
 
 const (
 	StepChangeContract        Step = "change_contract"
 	StepChangeContractAddress Step = "change_contract_address"
 	StepSelectMethod          Step = "select_method"
+	StepRepeatCall            Step = "repeat_last_call"
+	StepEditCall              Step = "edit_and_rerun"
+	StepEvents                Step = "events"
+	StepWatch                 Step = "watch"
+	StepSwitchNetwork         Step = "switch_network"
+	StepAddressBook           Step = "address_book"
+	StepSafeBatch             Step = "safe_batch"
+	StepHistory               Step = "history"
+	StepSetNonce              Step = "set_nonce"
 	StepExit                  Step = "exit"
+
+	// steps which are not offered to the user, they are only reached through transitions
+
+	StepInputRpcUrl Step = "input_rpc_url"
+	StepSelectStep  Step = "select_step"
 )
+
+// Handler runs a step and returns the next step.
+// An error stops the machine, recoverable failures should return the step to retry instead.
+type Handler func() (Step, error)
+
+// Machine runs the handlers of the steps until StepExit is reached
+type Machine struct {
+	handlers map[Step]Handler
+}
+
+func NewMachine() *Machine {
+	return &Machine{handlers: make(map[Step]Handler)}
+}
+
+// Handle registers the handler of the step, replacing the existing one
+func (m *Machine) Handle(s Step, h Handler) {
+	m.handlers[s] = h
+}
+
+// Next runs the handler of the step and returns the next step
+func (m *Machine) Next(s Step) (Step, error) {
+	h, ok := m.handlers[s]
+	if !ok {
+		return "", fmt.Errorf("no handler for step %q", s)
+	}
+	return h()
+}
+
+// Run runs the machine from the start step until StepExit is reached or a handler fails
+func (m *Machine) Run(start Step) error {
+	for s := start; s != StepExit; {
+		var err error
+		if s, err = m.Next(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package step
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	internalabi "github.com/zsystm/solizard/internal/abi"
+)
+
+// result is what a handler returns when it's run
+type result struct {
+	next Step
+	err  error
+}
+
+// scriptedMachine returns a machine whose handlers return the results of their step in order,
+// the steps which are run are recorded in visited
+func scriptedMachine(t *testing.T, results map[Step][]result, visited *[]Step) *Machine {
+	t.Helper()
+	m := NewMachine()
+	for s := range results {
+		s := s
+		m.Handle(s, func() (Step, error) {
+			*visited = append(*visited, s)
+			if len(results[s]) == 0 {
+				t.Fatalf("step %s run more often than scripted", s)
+			}
+			r := results[s][0]
+			results[s] = results[s][1:]
+			return r.next, r.err
+		})
+	}
+	return m
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		start   Step
+		results map[Step][]result
+		want    []Step
+		wantErr string
+	}{
+		{
+			name:  "exit right away",
+			start: StepExit,
+			want:  nil,
+		},
+		{
+			name:  "select a contract and call a method",
+			start: StepChangeContract,
+			results: map[Step][]result{
+				StepChangeContract:        {{next: StepInputRpcUrl}},
+				StepInputRpcUrl:           {{next: StepChangeContractAddress}},
+				StepChangeContractAddress: {{next: StepSelectMethod}},
+				StepSelectMethod:          {{next: StepSelectStep}},
+				StepSelectStep:            {{next: StepExit}},
+			},
+			want: []Step{StepChangeContract, StepInputRpcUrl, StepChangeContractAddress, StepSelectMethod, StepSelectStep},
+		},
+		{
+			// a failed call returns to the method selection, an invalid address is asked again
+			name:  "recover from failures",
+			start: StepChangeContractAddress,
+			results: map[Step][]result{
+				StepChangeContractAddress: {{next: StepChangeContractAddress}, {next: StepSelectMethod}},
+				StepSelectMethod:          {{next: StepSelectMethod}, {next: StepSelectStep}},
+				StepSelectStep:            {{next: StepExit}},
+			},
+			want: []Step{StepChangeContractAddress, StepChangeContractAddress, StepSelectMethod, StepSelectMethod, StepSelectStep},
+		},
+		{
+			// an interrupted request returns to the step picker, which goes back to the step
+			name:  "interrupted and back",
+			start: StepEvents,
+			results: map[Step][]result{
+				StepEvents:     {{next: StepSelectStep}, {next: StepSelectStep}},
+				StepSelectStep: {{next: StepEvents}, {next: StepExit}},
+			},
+			want: []Step{StepEvents, StepSelectStep, StepEvents, StepSelectStep},
+		},
+		{
+			name:  "switch network after a failed chain id query",
+			start: StepInputRpcUrl,
+			results: map[Step][]result{
+				StepInputRpcUrl:   {{next: StepSwitchNetwork}, {next: StepExit}},
+				StepSwitchNetwork: {{next: StepInputRpcUrl}},
+			},
+			want: []Step{StepInputRpcUrl, StepSwitchNetwork, StepInputRpcUrl},
+		},
+		{
+			name:  "a handler error stops the machine",
+			start: StepSelectStep,
+			results: map[Step][]result{
+				StepSelectStep: {{next: StepSetNonce}},
+				StepSetNonce:   {{next: StepSelectStep, err: errors.New("connection refused")}},
+			},
+			want:    []Step{StepSelectStep, StepSetNonce},
+			wantErr: "connection refused",
+		},
+		{
+			name:  "step without a handler",
+			start: StepSelectStep,
+			results: map[Step][]result{
+				StepSelectStep: {{next: StepWatch}},
+			},
+			want:    []Step{StepSelectStep},
+			wantErr: `no handler for step "watch"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var visited []Step
+			err := scriptedMachine(t, tt.results, &visited).Run(tt.start)
+			if (err == nil) != (tt.wantErr == "") || (err != nil && err.Error() != tt.wantErr) {
+				t.Errorf("Run() error = %v, want %q", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(visited, tt.want) {
+				t.Errorf("visited %v, want %v", visited, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleReplaces(t *testing.T) {
+	m := NewMachine()
+	m.Handle(StepSelectMethod, func() (Step, error) { return StepSelectMethod, nil })
+	m.Handle(StepSelectMethod, func() (Step, error) { return StepExit, nil })
+	next, err := m.Next(StepSelectMethod)
+	if err != nil || next != StepExit {
+		t.Errorf("Next() = %q, %v, want the step of the last handler", next, err)
+	}
+}
+
+func TestActions(t *testing.T) {
+	tests := []struct {
+		action Action
+		label  string
+		rw     internalabi.MethodType
+		sends  bool
+	}{
+		{action: ActionRead, label: "Read", rw: internalabi.ReadMethod},
+		{action: ActionWrite, label: "Write", rw: internalabi.WriteMethod, sends: true},
+		{action: ActionQueue, label: "Write without waiting", rw: internalabi.WriteMethod, sends: true},
+		{action: ActionBuild, label: "Build calldata", rw: internalabi.AllMethod},
+		{action: ActionBatch, label: "Add to Safe batch", rw: internalabi.WriteMethod},
+	}
+	if len(tests) != len(Actions) {
+		t.Fatalf("got %d actions, want %d", len(Actions), len(tests))
+	}
+	for i, tt := range tests {
+		a := Actions[i]
+		if a != tt.action || a.Label() != tt.label || a.MethodType() != tt.rw || a.Sends() != tt.sends {
+			t.Errorf("action %d = %q (label %q, methods %q, sends %v), want %q (label %q, methods %q, sends %v)",
+				i, a, a.Label(), a.MethodType(), a.Sends(), tt.action, tt.label, tt.rw, tt.sends)
+		}
+	}
+}
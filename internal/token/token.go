@@ -0,0 +1,111 @@
+package token
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+
+	internalabi "github.com/zsystm/solizard/internal/abi"
+	"github.com/zsystm/solizard/lib"
+)
+
+const (
+	decimalsMethod = "decimals"
+	symbolMethod   = "symbol"
+)
+
+// Metadata is the token information used to format token amounts
+type Metadata struct {
+	Symbol   string `json:"symbol"`
+	Decimals int    `json:"decimals"`
+}
+
+// Format formats the amount in the smallest unit of the token, e.g. "1,234.56 USDT"
+func (m Metadata) Format(amount *big.Int) string {
+	return lib.FormatUnits(amount, m.Decimals) + " " + m.Symbol
+}
+
+// Annotate sets the formatted token amount of every unsigned integer value
+func (m Metadata) Annotate(values []internalabi.NamedValue) {
+	for i, v := range values {
+		if amount, ok := v.Value.(*big.Int); ok && strings.HasPrefix(v.Type, "uint") {
+			values[i].Formatted = m.Format(amount)
+		}
+	}
+}
+
+// IsToken returns true if the contract exposes decimals() and symbol()
+func IsToken(contractABI abi.ABI) bool {
+	for _, name := range []string{decimalsMethod, symbolMethod} {
+		method, ok := contractABI.Methods[name]
+		if !ok || !method.IsConstant() || len(method.Inputs) != 0 || len(method.Outputs) != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// IsDecimalsMethod returns true if the method is the token's decimals() method,
+// its output must not be formatted as a token amount.
+func IsDecimalsMethod(method abi.Method) bool {
+	return method.Name == decimalsMethod && len(method.Inputs) == 0
+}
+
+// FetchMetadata queries decimals() and symbol() of the token contract
+func FetchMetadata(ctx context.Context, cli ethereum.ContractCaller, contractABI abi.ABI, addr common.Address) (*Metadata, error) {
+	if !IsToken(contractABI) {
+		return nil, fmt.Errorf("contract does not expose %s() and %s()", decimalsMethod, symbolMethod)
+	}
+	decimals, err := call(ctx, cli, contractABI, addr, decimalsMethod)
+	if err != nil {
+		return nil, err
+	}
+	symbol, err := call(ctx, cli, contractABI, addr, symbolMethod)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Metadata{}
+	switch v := decimals.(type) {
+	case uint8:
+		m.Decimals = int(v)
+	case *big.Int:
+		if !v.IsInt64() || v.Int64() > 77 {
+			return nil, fmt.Errorf("unexpected decimals: %v", v)
+		}
+		m.Decimals = int(v.Int64())
+	default:
+		return nil, fmt.Errorf("unexpected decimals type: %T", decimals)
+	}
+	switch v := symbol.(type) {
+	case string:
+		m.Symbol = v
+	case [32]byte:
+		// some old tokens (e.g. MKR) return symbol as bytes32
+		m.Symbol = string(common.TrimRightZeroes(v[:]))
+	default:
+		return nil, fmt.Errorf("unexpected symbol type: %T", symbol)
+	}
+	return m, nil
+}
+
+func call(ctx context.Context, cli ethereum.ContractCaller, contractABI abi.ABI, addr common.Address, method string) (interface{}, error) {
+	input, err := contractABI.Pack(method)
+	if err != nil {
+		return nil, err
+	}
+	output, err := cli.CallContract(ctx, ethereum.CallMsg{To: &addr, Data: input}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call %s(): %v", method, err)
+	}
+	res, err := contractABI.Unpack(method, output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unpack %s(): %v", method, err)
+	}
+	return res[0], nil
+}
@@ -10,16 +10,16 @@ import (
 
 // ValidateContractAddress validates the given string is a valid contract address
 // and sets the contract address in the ctx
-func ValidateContractAddress(ctx *ctx.Context, s string) error {
+func ValidateContractAddress(reqCtx context.Context, ctx *ctx.Context, s string) error {
 	if err := ValidateAddress(s); err != nil {
 		return err
 	}
 
 	cAddr := common.HexToAddress(s)
 	// check if the contract exists on the chain
-	code, err := ctx.EthClient().CodeAt(context.TODO(), cAddr, nil)
+	code, err := ctx.EthClient().CodeAt(reqCtx, cAddr, nil)
 	if err != nil {
-		return fmt.Errorf("failed to get contract code: %v", err)
+		return fmt.Errorf("failed to get contract code: %w", err)
 	}
 	// check if the contract address is a contract address
 	if len(code) == 0 {
@@ -28,3 +28,19 @@ func ValidateContractAddress(ctx *ctx.Context, s string) error {
 	ctx.SetContractAddress(&cAddr)
 	return nil
 }
+
+// ValidateSignerChainId checks the node serves the chain id the transaction is signed for,
+// so a transaction is never signed for a different chain than the one it's sent to
+func ValidateSignerChainId(reqCtx context.Context, ctx *ctx.Context) error {
+	if ctx.ChainId().Sign() == 0 {
+		return fmt.Errorf("signer chain id is not set")
+	}
+	chainID, err := ctx.EthClient().ChainID(reqCtx)
+	if err != nil {
+		return fmt.Errorf("failed to get chain id from the node: %w", err)
+	}
+	if chainID.Cmp(ctx.ChainId()) != 0 {
+		return fmt.Errorf("signer chain id %d differs from the chain id %d of the node %s, check the chain id of the profile or switch network", ctx.ChainId(), chainID, ctx.RpcURL())
+	}
+	return nil
+}
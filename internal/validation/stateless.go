@@ -3,21 +3,20 @@ package validation
 import (
 	"fmt"
 	"math/big"
-	"net/url"
 	"os"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/zsystm/solizard/lib"
 )
 
 func ValidateRpcURL(s string) error {
 	if len(s) == 0 {
 		return fmt.Errorf("input cannot be empty")
 	}
-	if _, err := url.ParseRequestURI(s); err != nil {
-		return fmt.Errorf("invalid rpc url: %v", err)
-	}
-	return nil
+	// the url can hold ${VAR} placeholders of environment variables
+	return lib.ValidateRPC(s)
 }
 
 func ValidateAddress(s string) error {
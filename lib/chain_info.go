@@ -3,7 +3,9 @@ package lib
 import (
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"os"
+	"sort"
 
 	"github.com/zsystm/solizard/internal/log"
 )
@@ -15,6 +17,46 @@ type NativeCurrency struct {
 	Decimals int    `json:"decimals"`
 }
 
+// Format formats an amount given in the smallest unit of the currency, e.g. "1.5 ETH"
+func (n NativeCurrency) Format(amount *big.Int) string {
+	return FormatUnits(amount, n.Decimals) + " " + n.Symbol
+}
+
+// Explorer represents a block explorer of the chain
+type Explorer struct {
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Standard string `json:"standard"`
+}
+
+// RPCs is the list of rpc urls of a chain.
+// Besides plain urls, it accepts the {"url": "..."} objects of the chainlist.org format.
+type RPCs []string
+
+func (r *RPCs) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	urls := make([]string, 0, len(raw))
+	for _, item := range raw {
+		var url string
+		if err := json.Unmarshal(item, &url); err == nil {
+			urls = append(urls, url)
+			continue
+		}
+		var obj struct {
+			URL string `json:"url"`
+		}
+		if err := json.Unmarshal(item, &obj); err != nil {
+			return fmt.Errorf("invalid rpc entry %s", string(item))
+		}
+		urls = append(urls, obj.URL)
+	}
+	*r = urls
+	return nil
+}
+
 // ChainInfo represents the structure of each chain in the JSON
 type ChainInfo struct {
 	Name           string         `json:"name"`
@@ -22,16 +64,92 @@ type ChainInfo struct {
 	ShortName      string         `json:"shortName"`
 	NetworkID      uint64         `json:"networkId"`
 	NativeCurrency NativeCurrency `json:"nativeCurrency"`
-	RPC            []string       `json:"rpc"`
+	RPC            RPCs           `json:"rpc"`
 	Faucets        []string       `json:"faucets"`
 	InfoURL        string         `json:"infoURL"`
+	Explorers      []Explorer     `json:"explorers,omitempty"`
+}
+
+// String returns the chain formatted for the chain picker, e.g. "Ethereum Mainnet (eth, chain id: 1)"
+func (c *ChainInfo) String() string {
+	if c.ShortName == "" {
+		return fmt.Sprintf("%s (chain id: %d)", c.Name, c.ChainID)
+	}
+	return fmt.Sprintf("%s (%s, chain id: %d)", c.Name, c.ShortName, c.ChainID)
+}
+
+// ExplorerURL returns the url of the first block explorer of the chain, or an empty string if there is none
+func (c *ChainInfo) ExplorerURL() string {
+	if len(c.Explorers) == 0 {
+		return ""
+	}
+	return c.Explorers[0].URL
+}
+
+func (c *ChainInfo) Validate() error {
+	if c.ChainID == 0 {
+		return fmt.Errorf("chain id is empty")
+	}
+	if c.Name == "" {
+		return fmt.Errorf("chain %d has no name", c.ChainID)
+	}
+	return nil
+}
+
+// ChainRegistry is the list of known chains indexed by chain id
+type ChainRegistry struct {
+	chains []*ChainInfo
+	// index maps the chain id to the position in chains
+	index map[uint64]int
+}
+
+// NewChainRegistry creates a registry of the chains, a later chain replaces an earlier one with the same chain id
+func NewChainRegistry(chains []*ChainInfo) *ChainRegistry {
+	r := &ChainRegistry{index: make(map[uint64]int, len(chains))}
+	r.Merge(chains)
+	return r
+}
+
+// Merge adds the chains to the registry, replacing the chains with the same chain id.
+// It returns the number of added and replaced chains.
+func (r *ChainRegistry) Merge(chains []*ChainInfo) (added int, replaced int) {
+	for _, c := range chains {
+		if c == nil {
+			continue
+		}
+		if i, ok := r.index[c.ChainID]; ok {
+			r.chains[i] = c
+			replaced++
+		} else {
+			r.index[c.ChainID] = len(r.chains)
+			r.chains = append(r.chains, c)
+			added++
+		}
+	}
+	return added, replaced
+}
+
+// Chains returns all chains of the registry ordered by chain id
+func (r *ChainRegistry) Chains() []*ChainInfo {
+	chains := append([]*ChainInfo{}, r.chains...)
+	sort.SliceStable(chains, func(i, j int) bool { return chains[i].ChainID < chains[j].ChainID })
+	return chains
 }
 
-func ParseChainsJSON(path string) ([]*ChainInfo, error) {
+func (r *ChainRegistry) GetChainInfoByID(chainID uint64) (*ChainInfo, error) {
+	if r != nil {
+		if i, ok := r.index[chainID]; ok {
+			return r.chains[i], nil
+		}
+	}
+	return nil, fmt.Errorf("chain ID %d not found", chainID)
+}
+
+// ReadChainsJSON reads a chainlist format JSON file
+func ReadChainsJSON(path string) ([]*ChainInfo, error) {
 	// Read the JSON file
 	jsonData, err := os.ReadFile(path)
 	if err != nil {
-		log.Error(fmt.Sprintf("failed to read chain infos (reason: %v)\n", err))
 		return nil, err
 	}
 
@@ -39,20 +157,68 @@ func ParseChainsJSON(path string) ([]*ChainInfo, error) {
 	var chainInfos []*ChainInfo
 
 	// Parse JSON into the slice
-	err = json.Unmarshal(jsonData, &chainInfos)
+	if err = json.Unmarshal(jsonData, &chainInfos); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON file %s: %v", path, err)
+	}
+	return chainInfos, nil
+}
+
+// WriteChainsJSON writes the chains to a chainlist format JSON file
+func WriteChainsJSON(path string, chainInfos []*ChainInfo) error {
+	data, err := json.MarshalIndent(chainInfos, "", "  ")
 	if err != nil {
-		log.Error(fmt.Sprintf("failed to parse JSON file: %v\n", err))
-		return nil, err
+		return err
 	}
+	return os.WriteFile(path, data, 0644)
+}
 
-	return chainInfos, nil
+// SanitizeChains returns the valid chains and why each of the other chains is invalid
+func SanitizeChains(chains []*ChainInfo) ([]*ChainInfo, []error) {
+	valid := make([]*ChainInfo, 0, len(chains))
+	var errs []error
+	for _, c := range chains {
+		if c == nil {
+			continue
+		}
+		if err := c.Validate(); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		valid = append(valid, c)
+	}
+	return valid, errs
 }
 
-func GetChainInfoByID(chainInfos []*ChainInfo, chainID uint64) (*ChainInfo, error) {
-	for _, chainInfo := range chainInfos {
-		if chainInfo.ChainID == chainID {
-			return chainInfo, nil
+// ParseChainsJSON reads the chain registry at path and merges the chains of the custom files into it.
+// Custom files which don't exist are skipped, their chains replace the registry's chains with the same chain id.
+// Invalid chains and custom files which can't be read are reported and skipped.
+func ParseChainsJSON(path string, customPaths ...string) (*ChainRegistry, error) {
+	chainInfos, err := ReadChainsJSON(path)
+	if err != nil {
+		log.Error(fmt.Sprintf("failed to read chain infos (reason: %v)\n", err))
+		return nil, err
+	}
+	registry := NewChainRegistry(validChains(path, chainInfos))
+
+	for _, customPath := range customPaths {
+		if _, err = os.Stat(customPath); os.IsNotExist(err) {
+			continue
 		}
+		custom, err := ReadChainsJSON(customPath)
+		if err != nil {
+			log.Error(fmt.Sprintf("skipping custom chain infos (reason: %v)\n", err))
+			continue
+		}
+		registry.Merge(validChains(customPath, custom))
 	}
-	return nil, fmt.Errorf("chain ID %d not found", chainID)
+	return registry, nil
+}
+
+// validChains returns the valid chains of the file, the invalid ones are reported
+func validChains(path string, chains []*ChainInfo) []*ChainInfo {
+	valid, errs := SanitizeChains(chains)
+	for _, err := range errs {
+		log.Error(fmt.Sprintf("skipping invalid chain in %s (reason: %v)\n", path, err))
+	}
+	return valid
 }
@@ -0,0 +1,132 @@
+package lib
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeChains(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseChainsJSON(t *testing.T) {
+	dir := t.TempDir()
+	embedded := filepath.Join(dir, "chains_mini.json")
+	writeChains(t, embedded, `[
+		{"name": "Ethereum Mainnet", "chainId": 1, "shortName": "eth", "nativeCurrency": {"name": "Ether", "symbol": "ETH", "decimals": 18},
+		 "rpc": ["https://eth.example", {"url": "wss://eth.example"}]},
+		{"name": "Sepolia", "chainId": 11155111, "shortName": "sep", "rpc": []}
+	]`)
+	custom := filepath.Join(dir, "custom_chains.json")
+	writeChains(t, custom, `[
+		{"name": "Ethereum via my node", "chainId": 1, "shortName": "eth", "rpc": ["http://node:8545"]},
+		{"name": "Devnet", "chainId": 31337, "nativeCurrency": {"name": "Dev", "symbol": "DEV", "decimals": 18}, "rpc": []}
+	]`)
+
+	registry, err := ParseChainsJSON(embedded, filepath.Join(dir, "missing.json"), custom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the user defined chain replaces the embedded one with the same chain id
+	mainnet, err := registry.GetChainInfoByID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mainnet.Name != "Ethereum via my node" || len(mainnet.RPC) != 1 || mainnet.RPC[0] != "http://node:8545" {
+		t.Errorf("unexpected chain 1: %+v", mainnet)
+	}
+	chains := registry.Chains()
+	if len(chains) != 3 || chains[0].ChainID != 1 || chains[1].ChainID != 31337 || chains[2].ChainID != 11155111 {
+		t.Fatalf("unexpected chains: %v", chains)
+	}
+	if got := chains[1].String(); got != "Devnet (chain id: 31337)" {
+		t.Errorf("String() = %s", got)
+	}
+	if got := chains[2].String(); got != "Sepolia (sep, chain id: 11155111)" {
+		t.Errorf("String() = %s", got)
+	}
+
+	if _, err = registry.GetChainInfoByID(5); err == nil {
+		t.Error("expected an error for an unknown chain id")
+	}
+	var empty *ChainRegistry
+	if _, err = empty.GetChainInfoByID(1); err == nil {
+		t.Error("expected an error for a missing registry")
+	}
+
+	if _, err = ParseChainsJSON(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("expected an error for a missing chain registry")
+	}
+}
+
+func TestParseInvalidCustomChains(t *testing.T) {
+	dir := t.TempDir()
+	embedded := filepath.Join(dir, "chains_mini.json")
+	writeChains(t, embedded, `[{"name": "Ethereum Mainnet", "chainId": 1}, {"name": "", "chainId": 2}]`)
+
+	tests := []struct {
+		name   string
+		custom string
+		want   []uint64
+	}{
+		{name: "not a list", custom: `{"name": "not a list"}`, want: []uint64{1}},
+		{name: "not json", custom: `[{"name": "Devnet",`, want: []uint64{1}},
+		{
+			name:   "invalid entries",
+			custom: `[{"name": "Devnet", "chainId": 31337}, {"name": "no chain id"}, null, {"chainId": 5}]`,
+			want:   []uint64{1, 31337},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			custom := filepath.Join(t.TempDir(), "custom_chains.json")
+			writeChains(t, custom, tt.custom)
+			// the invalid custom chains are skipped rather than failing every command
+			registry, err := ParseChainsJSON(embedded, custom)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []uint64
+			for _, c := range registry.Chains() {
+				got = append(got, c.ChainID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("chain ids = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSanitizeChains(t *testing.T) {
+	valid, errs := SanitizeChains([]*ChainInfo{{Name: "a", ChainID: 1}, nil, {ChainID: 2}, {Name: "c"}})
+	if len(valid) != 1 || valid[0].ChainID != 1 || len(errs) != 2 {
+		t.Errorf("SanitizeChains() = %v, %v", valid, errs)
+	}
+}
+
+func TestChainRegistryMerge(t *testing.T) {
+	registry := NewChainRegistry([]*ChainInfo{{Name: "a", ChainID: 1}, {Name: "b", ChainID: 2}, {Name: "a2", ChainID: 1}})
+	if chains := registry.Chains(); len(chains) != 2 || chains[0].Name != "a2" {
+		t.Errorf("a later chain doesn't replace an earlier one: %v", chains)
+	}
+	added, replaced := registry.Merge([]*ChainInfo{{Name: "b2", ChainID: 2}, nil, {Name: "c", ChainID: 3}})
+	if added != 1 || replaced != 1 {
+		t.Errorf("Merge() = %d added, %d replaced, want 1 and 1", added, replaced)
+	}
+	if c, _ := registry.GetChainInfoByID(2); c.Name != "b2" {
+		t.Errorf("unexpected chain 2: %+v", c)
+	}
+}
+
+func TestReadRPCs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chains.json")
+	writeChains(t, path, `[{"name": "x", "chainId": 1, "rpc": [1]}]`)
+	if _, err := ReadChainsJSON(path); err == nil {
+		t.Error("expected an error for an invalid rpc entry")
+	}
+}
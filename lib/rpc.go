@@ -0,0 +1,133 @@
+package lib
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// ExpandRPC replaces ${VAR} placeholders of the rpc url with the environment variables,
+// e.g. https://mainnet.infura.io/v3/${INFURA_API_KEY}.
+// It returns an error if one of the variables is not set.
+func ExpandRPC(rpcURL string) (string, error) {
+	var missing []string
+	expanded := os.Expand(rpcURL, func(name string) string {
+		v, ok := os.LookupEnv(name)
+		if !ok || v == "" {
+			missing = append(missing, name)
+		}
+		return v
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("environment variables not set: %s", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+// ValidateRPC checks the rpc url is a valid url once its placeholders are expanded,
+// the error doesn't contain the expanded url
+func ValidateRPC(rpcURL string) error {
+	expanded, err := ExpandRPC(rpcURL)
+	if err != nil {
+		return err
+	}
+	if _, err = url.ParseRequestURI(expanded); err != nil {
+		if uerr, ok := err.(*url.Error); ok {
+			err = uerr.Err
+		}
+		return fmt.Errorf("invalid rpc url: %v", err)
+	}
+	return nil
+}
+
+// secretMask replaces the secrets of an rpc url
+const secretMask = "****"
+
+// RedactRPC hides the secrets of an rpc url so it can be printed: the credentials, the query values
+// and the path segments which look like api keys, e.g. https://mainnet.infura.io/v3/****.
+// The ${VAR} placeholders are kept since they don't contain the secrets.
+func RedactRPC(rpcURL string) string {
+	rest, fragment, hasFragment := strings.Cut(rpcURL, "#")
+	rest, query, hasQuery := strings.Cut(rest, "?")
+	scheme, rest, hasScheme := strings.Cut(rest, "://")
+	if !hasScheme {
+		rest, scheme = scheme, ""
+	}
+	host, path, hasPath := strings.Cut(rest, "/")
+	if userinfo, hostname, ok := strings.Cut(host, "@"); ok && !isPlaceholder(userinfo) {
+		host = secretMask + "@" + hostname
+	}
+
+	redacted := host
+	if hasScheme {
+		redacted = scheme + "://" + host
+	}
+	if hasPath {
+		segments := strings.Split(path, "/")
+		for i, segment := range segments {
+			if looksLikeAPIKey(segment) {
+				segments[i] = secretMask
+			}
+		}
+		redacted += "/" + strings.Join(segments, "/")
+	}
+	if hasQuery {
+		params := strings.Split(query, "&")
+		for i, param := range params {
+			if key, value, ok := strings.Cut(param, "="); ok && value != "" && !isPlaceholder(value) {
+				params[i] = key + "=" + secretMask
+			}
+		}
+		redacted += "?" + strings.Join(params, "&")
+	}
+	if hasFragment {
+		redacted += "#" + fragment
+	}
+	return redacted
+}
+
+// isPlaceholder returns true if s is a ${VAR} placeholder
+func isPlaceholder(s string) bool {
+	return strings.HasPrefix(s, "${") && strings.HasSuffix(s, "}")
+}
+
+// looksLikeAPIKey returns true if the path segment looks like an api key: a long word of letters and digits
+func looksLikeAPIKey(segment string) bool {
+	if len(segment) < 20 {
+		return false
+	}
+	hasDigit := false
+	for _, r := range segment {
+		switch {
+		case r >= '0' && r <= '9':
+			hasDigit = true
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '-', r == '_':
+		default:
+			return false
+		}
+	}
+	return hasDigit
+}
+
+// IsHTTPRPC returns true if the rpc url uses http or https
+func IsHTTPRPC(rpcURL string) bool {
+	return strings.HasPrefix(rpcURL, "http://") || strings.HasPrefix(rpcURL, "https://")
+}
+
+// UsableRPCs returns the http rpc urls of the chain whose environment variables are set.
+// The ${VAR} placeholders are kept so api keys aren't shown, logged or saved, they are expanded when dialing.
+// Websocket urls and urls with unset environment variables are skipped.
+func (c *ChainInfo) UsableRPCs() []string {
+	var urls []string
+	for _, rpc := range c.RPC {
+		if !IsHTTPRPC(rpc) {
+			continue
+		}
+		if _, err := ExpandRPC(rpc); err != nil {
+			continue
+		}
+		urls = append(urls, rpc)
+	}
+	return urls
+}
@@ -0,0 +1,40 @@
+package lib
+
+import (
+	"math/big"
+	"strings"
+)
+
+// FormatUnits formats an amount given in the smallest unit with the given decimals.
+// The integer part is grouped by thousands and trailing zeros of the fraction are dropped,
+// e.g. FormatUnits(1234560000, 6) returns "1,234.56".
+func FormatUnits(amount *big.Int, decimals int) string {
+	if amount == nil {
+		return ""
+	}
+	if decimals < 0 {
+		decimals = 0
+	}
+	digits := new(big.Int).Abs(amount).String()
+	if len(digits) <= decimals {
+		digits = strings.Repeat("0", decimals-len(digits)+1) + digits
+	}
+	intPart := digits[:len(digits)-decimals]
+	fracPart := strings.TrimRight(digits[len(digits)-decimals:], "0")
+
+	var sb strings.Builder
+	if amount.Sign() < 0 {
+		sb.WriteByte('-')
+	}
+	for i, c := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteRune(c)
+	}
+	if fracPart != "" {
+		sb.WriteByte('.')
+		sb.WriteString(fracPart)
+	}
+	return sb.String()
+}